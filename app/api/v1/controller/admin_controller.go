@@ -0,0 +1,288 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/beego/beego/validation"
+	"gorm.io/gorm"
+
+	"funny-project-be/domain/entity"
+	"funny-project-be/domain/pubsub"
+	"funny-project-be/domain/repo"
+	"funny-project-be/infra/constant"
+	"funny-project-be/infra/options"
+	"funny-project-be/infra/status"
+)
+
+// defaultUsersLimit is the page size of ListUsers when no limit is given.
+const defaultUsersLimit = 50
+
+// AdminController exposes the moderation apis. The routes require the
+// moderation permissions, see router.InitRouters.
+type AdminController struct {
+	BaseController
+
+	URepo  repo.UserRepo
+	VRepo  repo.VideoRepo
+	RTRepo repo.RefreshTokenRepo
+	Broker pubsub.Broker
+
+	Opts options.Options
+}
+
+// ListUsersRequest represents a request for listing users.
+type ListUsersRequest struct {
+	Page  int `form:"page" valid:"Min(1)"`
+	Limit int `form:"limit" valid:"Range(1, 200)"`
+}
+
+// ListUsersResponse is the response of ListUsers.
+type ListUsersResponse struct {
+	RangeResponse
+	Items []*User `json:"_items"`
+}
+
+// ListUsers API. Users are listed oldest first, banned ones included.
+func (c *AdminController) ListUsers() {
+	var req ListUsersRequest
+	var resp ListUsersResponse
+	resp.Code = status.OK
+	resp.Items = []*User{}
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	if err := c.ParseForm(&req); err != nil {
+		resp.Code = status.BadRequest
+		resp.SetError(err)
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultUsersLimit
+	}
+
+	var validator validation.Validation
+	valid, err := validator.Valid(&req)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("ListUsers", err)
+		return
+	}
+	if !valid {
+		resp.Code = status.BadRequest
+		resp.SetValidationErrors(validator.Errors)
+		return
+	}
+
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+	users, err := c.URepo.GetRange(ctx, req.Limit, req.Page)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("ListUsers", err)
+		return
+	}
+	total, err := c.URepo.Count(ctx)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("ListUsers", err)
+		return
+	}
+
+	for _, user := range users {
+		resp.Items = append(resp.Items, NewUserFromEntity(user))
+	}
+	resp.Total = total
+	resp.Page = req.Page
+	resp.Limit = req.Limit
+}
+
+// BanUserResponse is the response of BanUser and UnbanUser.
+type BanUserResponse struct {
+	Response
+	User *User `json:"user,omitempty"`
+}
+
+// BanUser API. Banned users can not log in nor use their access tokens, and
+// their refresh tokens are revoked.
+func (c *AdminController) BanUser() {
+	var resp BanUserResponse
+	resp.Code = status.OK
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	user := c.getUser(&resp.Response)
+	if user == nil {
+		return
+	}
+
+	uid := c.Ctx.Input.GetData(constant.ContextUID).(uint)
+	if user.ID == uid {
+		resp.Code = status.BadRequest
+		resp.Message = `you can not ban yourself`
+		return
+	}
+
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+	if user.BannedAt == nil {
+		now := time.Now()
+		user.BannedAt = &now
+		if err := c.URepo.Update(ctx, user); err != nil {
+			resp.Code = status.InternalServerError
+			resp.SetError(err)
+			c.logError("BanUser", err)
+			return
+		}
+	}
+	if err := c.RTRepo.RevokeByUser(ctx, user.ID); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("BanUser", err)
+		return
+	}
+
+	resp.User = NewUserFromEntity(user)
+}
+
+// UnbanUser API.
+func (c *AdminController) UnbanUser() {
+	var resp BanUserResponse
+	resp.Code = status.OK
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	user := c.getUser(&resp.Response)
+	if user == nil {
+		return
+	}
+
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+	if user.BannedAt != nil {
+		user.BannedAt = nil
+		if err := c.URepo.Update(ctx, user); err != nil {
+			resp.Code = status.InternalServerError
+			resp.SetError(err)
+			c.logError("UnbanUser", err)
+			return
+		}
+	}
+
+	resp.User = NewUserFromEntity(user)
+}
+
+// HideVideoResponse is the response of HideVideo and UnhideVideo.
+type HideVideoResponse struct {
+	Response
+	Video *Video `json:"video,omitempty"`
+}
+
+// HideVideo API. Hidden videos are left out of the lists and searches, and only
+// moderators can get them.
+func (c *AdminController) HideVideo() {
+	c.setVideoHidden("HideVideo", true)
+}
+
+// UnhideVideo API.
+func (c *AdminController) UnhideVideo() {
+	c.setVideoHidden("UnhideVideo", false)
+}
+
+// setVideoHidden hides or unhides the video of the route.
+func (c *AdminController) setVideoHidden(name string, hidden bool) {
+	var resp HideVideoResponse
+	resp.Code = status.OK
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	id, err := strconv.ParseUint(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		resp.Code = status.BadRequest
+		resp.Message = "id is invalid"
+		return
+	}
+
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+	video, err := c.VRepo.Get(ctx, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			resp.Code = status.NotFound
+			resp.Message = `video not found`
+			return
+		}
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError(name, err)
+		return
+	}
+
+	if (video.HiddenAt != nil) != hidden {
+		video.HiddenAt = nil
+		if hidden {
+			now := time.Now()
+			video.HiddenAt = &now
+		}
+		if err := c.VRepo.Update(ctx, video); err != nil {
+			resp.Code = status.InternalServerError
+			resp.SetError(err)
+			c.logError(name, err)
+			return
+		}
+		// Open feeds drop a hidden video like a deleted one. An unhidden video
+		// shows up once feeds are reloaded.
+		if hidden {
+			c.publishEvent(ctx, c.Broker, EventVideoDeleted, &VideoDeleted{ID: video.ID}, videoAudience(video.ID))
+		}
+	}
+
+	resp.Video = NewVideoFromEntity(video)
+}
+
+// getUser finds the user of the route.
+// It returns nil after setting the error to resp otherwise.
+func (c *AdminController) getUser(resp *Response) *entity.User {
+	id, err := strconv.ParseUint(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		resp.Code = status.BadRequest
+		resp.Message = "id is invalid"
+		return nil
+	}
+
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+	user, err := c.URepo.Get(ctx, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			resp.Code = status.NotFound
+			resp.Message = `user not found`
+			return nil
+		}
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("getUser", err)
+		return nil
+	}
+
+	return user
+}
@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	beegoctx "github.com/beego/beego/context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"funny-project-be/domain/entity"
+	"funny-project-be/domain/repo"
+	"funny-project-be/infra/constant"
+	"funny-project-be/infra/pubsub/pubsubimpl"
+	"funny-project-be/infra/status"
+	"funny-project-be/infra/ws"
+)
+
+func (c *AdminController) ServeJSON() {}
+
+func newAdminController(t *testing.T, uRepo *memUserRepo, vRepo *MockVideoRepo, rtRepo *MockRefreshTokenRepo, id string) *AdminController {
+	req, err := http.NewRequest("PUT", "/admin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	controller := &AdminController{
+		URepo:  uRepo,
+		VRepo:  vRepo,
+		RTRepo: rtRepo,
+		Broker: pubsubimpl.NewMemoryBroker(),
+	}
+	controller.Ctx = &beegoctx.Context{
+		Input:          beegoctx.NewInput(),
+		Output:         beegoctx.NewOutput(),
+		Request:        req,
+		ResponseWriter: &beegoctx.Response{},
+	}
+	controller.Ctx.Input.Context = controller.Ctx
+	controller.Ctx.Output.Context = controller.Ctx
+	controller.Data = make(map[interface{}]interface{})
+	controller.Ctx.Input.SetParam(":id", id)
+	controller.Ctx.Input.SetData(constant.ContextUID, uint(1))
+	controller.Ctx.Input.SetData(constant.ContextRole, entity.RoleAdmin)
+	controller.Ctx.Input.SetData(constant.ContextCtx, context.Background())
+
+	return controller
+}
+
+func TestAdminController_ListUsers(t *testing.T) {
+	uRepo := &memUserRepo{}
+	uRepo.Add(context.Background(),
+		&entity.User{Email: "admin@example.com", Role: entity.RoleAdmin},
+		&entity.User{Email: "a@example.com", Role: entity.RoleUser},
+		&entity.User{Email: "b@example.com", Role: entity.RoleUser},
+	)
+
+	controller := newAdminController(t, uRepo, &MockVideoRepo{}, &MockRefreshTokenRepo{}, "")
+	controller.Ctx.Request.Form = map[string][]string{"page": {"2"}, "limit": {"2"}}
+	controller.ListUsers()
+
+	resp := controller.Data["json"].(*ListUsersResponse)
+	assert.Equal(t, status.OK, resp.Code)
+	assert.Equal(t, int64(3), resp.Total)
+	require.Len(t, resp.Items, 1)
+	assert.Equal(t, "b@example.com", resp.Items[0].Email)
+	assert.Equal(t, entity.RoleUser, resp.Items[0].Role)
+
+	controller = newAdminController(t, uRepo, &MockVideoRepo{}, &MockRefreshTokenRepo{}, "")
+	controller.Ctx.Request.Form = map[string][]string{"limit": {"500"}}
+	controller.ListUsers()
+	assert.Equal(t, status.BadRequest, controller.Data["json"].(*ListUsersResponse).Code)
+}
+
+func TestAdminController_BanUser(t *testing.T) {
+	uRepo := &memUserRepo{}
+	uRepo.Add(context.Background(), &entity.User{Email: "admin@example.com"}, &entity.User{Email: "a@example.com"})
+	rtRepo := &MockRefreshTokenRepo{}
+	rtRepo.Add(context.Background(),
+		&entity.RefreshToken{UserID: 2, FamilyID: "a"},
+		&entity.RefreshToken{UserID: 1, FamilyID: "b"},
+	)
+
+	controller := newAdminController(t, uRepo, &MockVideoRepo{}, rtRepo, "2")
+	controller.BanUser()
+
+	resp := controller.Data["json"].(*BanUserResponse)
+	assert.Equal(t, status.OK, resp.Code)
+	assert.NotNil(t, resp.User.BannedAt)
+	assert.NotNil(t, uRepo.users[1].BannedAt)
+	assert.NotNil(t, rtRepo.tokens[0].RevokedAt)
+	assert.Nil(t, rtRepo.tokens[1].RevokedAt)
+
+	controller = newAdminController(t, uRepo, &MockVideoRepo{}, rtRepo, "2")
+	controller.UnbanUser()
+	assert.Equal(t, status.OK, controller.Data["json"].(*BanUserResponse).Code)
+	assert.Nil(t, uRepo.users[1].BannedAt)
+
+	// Admins can not lock themselves out.
+	controller = newAdminController(t, uRepo, &MockVideoRepo{}, rtRepo, "1")
+	controller.BanUser()
+	assert.Equal(t, status.BadRequest, controller.Data["json"].(*BanUserResponse).Code)
+
+	controller = newAdminController(t, uRepo, &MockVideoRepo{}, rtRepo, "9")
+	controller.BanUser()
+	assert.Equal(t, status.NotFound, controller.Data["json"].(*BanUserResponse).Code)
+}
+
+func TestAdminController_HideVideo(t *testing.T) {
+	vRepo := &MockVideoRepo{}
+	vRepo.Add(context.Background(), &entity.Video{}, &entity.Video{})
+
+	controller := newAdminController(t, &memUserRepo{}, vRepo, &MockRefreshTokenRepo{}, "1")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := controller.Broker.Subscribe(ctx, ws.BroadcastChannel)
+	require.NoError(t, err)
+	controller.HideVideo()
+
+	resp := controller.Data["json"].(*HideVideoResponse)
+	assert.Equal(t, status.OK, resp.Code)
+	assert.NotNil(t, resp.Video.HiddenAt)
+
+	// Open feeds drop the hidden video.
+	select {
+	case payload := <-ch:
+		var msg ws.Message
+		require.NoError(t, json.Unmarshal(payload, &msg))
+		assert.Equal(t, EventVideoDeleted, msg.Type)
+		assert.JSONEq(t, `{"id":1}`, string(msg.Payload))
+		assert.Equal(t, []string{"videos", "video:1"}, msg.Topics)
+	case <-time.After(time.Second):
+		t.Fatal("hidden video not published")
+	}
+	count, err := vRepo.Count(context.Background(), repo.VideoFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	controller = newAdminController(t, &memUserRepo{}, vRepo, &MockRefreshTokenRepo{}, "1")
+	controller.UnhideVideo()
+	assert.Nil(t, controller.Data["json"].(*HideVideoResponse).Video.HiddenAt)
+	count, err = vRepo.Count(context.Background(), repo.VideoFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	controller = newAdminController(t, &memUserRepo{}, vRepo, &MockRefreshTokenRepo{}, "x")
+	controller.HideVideo()
+	assert.Equal(t, status.BadRequest, controller.Data["json"].(*HideVideoResponse).Code)
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"funny-project-be/domain/entity"
+)
+
+// JWTClaim is the JWT custom claims.
+type JWTClaim struct {
+	Email string `json:"email,omitempty"`
+	Role  string `json:"role,omitempty"`
+	jwt.StandardClaims
+}
+
+// newTokenID returns a random identifier for jti claims and refresh token families.
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// hashRefreshToken returns the hex encoded SHA-256 of a refresh token.
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// issueAccessToken signs a new access token for user.
+func (c *UserController) issueAccessToken(user *entity.User) (string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := JWTClaim{
+		Role: user.Role,
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(c.Opts.AccessTokenExpiresIn).Unix(),
+			Subject:   strconv.Itoa(int(user.ID)),
+		},
+	}
+
+	return c.Keys.Sign(claims)
+}
+
+// issueRefreshToken creates a refresh token of familyID for user and stores its hash.
+func (c *UserController) issueRefreshToken(ctx context.Context, user *entity.User, familyID string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	if err := c.RTRepo.Add(ctx, &entity.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashRefreshToken(token),
+		ExpiresAt: time.Now().Add(c.Opts.RefreshTokenExpiresIn),
+	}); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
@@ -1,19 +1,54 @@
 package controller
 
 import (
+	"context"
+	"log/slog"
+
 	"github.com/beego/beego"
 	"github.com/beego/beego/validation"
+
+	"funny-project-be/infra/constant"
 )
 
 // BaseController creates BaseController.
 type BaseController struct {
 	beego.Controller
+
+	// Log is the logger of the controller, slog.Default() when it is nil.
+	Log *slog.Logger
 }
 
 // Prepare runs before controller.
 func (c *BaseController) Prepare() {
 }
 
+// context returns the context of the request set by the filters, which carries
+// the request ID and the authenticated user.
+func (c *BaseController) context() context.Context {
+	if ctx, ok := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context); ok {
+		return ctx
+	}
+	if c.Ctx.Request != nil {
+		return c.Ctx.Request.Context()
+	}
+
+	return context.Background()
+}
+
+// logger returns the logger of the controller.
+func (c *BaseController) logger() *slog.Logger {
+	if c.Log == nil {
+		return slog.Default()
+	}
+
+	return c.Log
+}
+
+// logError logs err with the request ID and the user of the request.
+func (c *BaseController) logError(msg string, err error) {
+	c.logger().ErrorContext(c.context(), msg, "err", err)
+}
+
 // Response represents a base response for controller.
 type Response struct {
 	Code    int    `json:"code,omitempty"`
@@ -28,6 +63,13 @@ type RangeResponse struct {
 	Limit int   `json:"limit"`
 }
 
+// CursorResponse represents a base response for controller paginated by cursor.
+type CursorResponse struct {
+	Response
+	NextCursor string `json:"nextCursor,omitempty"`
+	Limit      int    `json:"limit"`
+}
+
 // SetError sets error to response.
 func (r *Response) SetError(err error) {
 	r.Message = err.Error()
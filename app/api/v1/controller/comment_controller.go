@@ -0,0 +1,417 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/beego/beego/validation"
+	"gorm.io/gorm"
+
+	"funny-project-be/domain/entity"
+	"funny-project-be/domain/pubsub"
+	"funny-project-be/domain/repo"
+	"funny-project-be/infra/constant"
+	"funny-project-be/infra/options"
+	"funny-project-be/infra/status"
+	"funny-project-be/infra/ws"
+)
+
+// defaultCommentLimit is the page size of ListComments when no limit is given.
+const defaultCommentLimit = 20
+
+// CommentController exposes apis of Comment resource.
+type CommentController struct {
+	BaseController
+
+	CRepo repo.CommentRepo
+	VRepo repo.VideoRepo
+	NRepo repo.NotificationRepo
+
+	Broker pubsub.Broker
+
+	Opts options.Options
+}
+
+// Comment info.
+type Comment struct {
+	ID        uint      `json:"id,omitempty"`
+	VideoID   uint      `json:"videoId,omitempty"`
+	ParentID  *uint     `json:"parentId,omitempty"`
+	UserID    uint      `json:"userId,omitempty"`
+	Body      string    `json:"body,omitempty"`
+	CreatedAt time.Time `json:"createdAt,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt,omitempty"`
+}
+
+// NewCommentFromEntity creates Comment from entity.
+func NewCommentFromEntity(e *entity.Comment) *Comment {
+	if e == nil {
+		return nil
+	}
+
+	return &Comment{
+		ID:        e.ID,
+		VideoID:   e.VideoID,
+		ParentID:  e.ParentID,
+		UserID:    e.UserID,
+		Body:      e.Body,
+		CreatedAt: e.CreatedAt,
+		UpdatedAt: e.UpdatedAt,
+	}
+}
+
+// commentCursor is the position encoded in the cursors of ListComments.
+type commentCursor struct {
+	ID uint `json:"id"`
+}
+
+// CreateCommentRequest is a request of CreateComment.
+type CreateCommentRequest struct {
+	Body     string `json:"body,omitempty" valid:"Required;MaxSize(2000)"`
+	ParentID *uint  `json:"parentId,omitempty"`
+}
+
+// Valid checks that the body fits in the comment.created event.
+func (r *CreateCommentRequest) Valid(v *validation.Validation) {
+	validEventText(v, "body", r.Body)
+}
+
+// CommentResponse is a response of CreateComment and UpdateComment.
+type CommentResponse struct {
+	Response
+	Comment *Comment `json:"comment,omitempty"`
+}
+
+// CreateComment API.
+func (c *CommentController) CreateComment() {
+	var req CreateCommentRequest
+	var resp CommentResponse
+	resp.Code = status.OK
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	videoID, err := strconv.ParseUint(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		resp.Code = status.BadRequest
+		resp.Message = "id is invalid"
+		return
+	}
+
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		resp.Code = status.BadRequest
+		resp.SetError(err)
+		return
+	}
+
+	var validator validation.Validation
+	valid, err := validator.Valid(&req)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("CreateComment", err)
+		return
+	}
+	if !valid {
+		resp.Code = status.BadRequest
+		resp.SetValidationErrors(validator.Errors)
+		return
+	}
+
+	uid := c.Ctx.Input.GetData(constant.ContextUID).(uint)
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+
+	video := c.getVisibleVideo(ctx, c.VRepo, uint(videoID), &resp.Response, "CreateComment")
+	if video == nil {
+		return
+	}
+
+	var parent *entity.Comment
+	if req.ParentID != nil {
+		parent, err = c.CRepo.Get(ctx, *req.ParentID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			resp.Code = status.InternalServerError
+			resp.SetError(err)
+			c.logError("CreateComment", err)
+			return
+		}
+		if parent == nil || parent.VideoID != video.ID {
+			resp.Code = status.BadRequest
+			resp.Message = `parent comment not found`
+			return
+		}
+	}
+
+	comment := &entity.Comment{
+		VideoID:  video.ID,
+		UserID:   uid,
+		ParentID: req.ParentID,
+		Body:     req.Body,
+	}
+	if err := c.CRepo.Add(ctx, comment); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("CreateComment", err)
+		return
+	}
+
+	resp.Code = status.Created
+	resp.Comment = NewCommentFromEntity(comment)
+
+	c.notifySharer(ctx, video, resp.Comment, uid)
+	c.notifyComment(ctx, video, parent, comment)
+}
+
+// ListCommentsRequest represents a request for listing comments.
+type ListCommentsRequest struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" valid:"Range(1, 200)"`
+}
+
+// ListCommentsResponse is the response of ListComments.
+type ListCommentsResponse struct {
+	CursorResponse
+	Items []*Comment `json:"_items"`
+}
+
+// ListComments API. Comments are listed oldest first, so a reply always comes after its parent.
+func (c *CommentController) ListComments() {
+	var req ListCommentsRequest
+	var resp ListCommentsResponse
+	resp.Code = status.OK
+	resp.Items = []*Comment{}
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	videoID, err := strconv.ParseUint(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		resp.Code = status.BadRequest
+		resp.Message = "id is invalid"
+		return
+	}
+
+	if err := c.ParseForm(&req); err != nil {
+		resp.Code = status.BadRequest
+		resp.SetError(err)
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultCommentLimit
+	}
+
+	var validator validation.Validation
+	valid, err := validator.Valid(&req)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("ListComments", err)
+		return
+	}
+	if !valid {
+		resp.Code = status.BadRequest
+		resp.SetValidationErrors(validator.Errors)
+		return
+	}
+
+	var cursor commentCursor
+	if req.Cursor != "" {
+		if err := decodeCursor(req.Cursor, &cursor); err != nil {
+			resp.Code = status.BadRequest
+			resp.SetError(err)
+			return
+		}
+	}
+
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+	if c.getVisibleVideo(ctx, c.VRepo, uint(videoID), &resp.Response, "ListComments") == nil {
+		return
+	}
+
+	// Fetch one more comment to know whether there is a next page.
+	comments, err := c.CRepo.GetRangeByVideo(ctx, uint(videoID), cursor.ID, req.Limit+1)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("ListComments", err)
+		return
+	}
+	if len(comments) > req.Limit {
+		comments = comments[:req.Limit]
+		resp.NextCursor = encodeCursor(commentCursor{ID: comments[len(comments)-1].ID})
+	}
+
+	resp.Limit = req.Limit
+	for _, e := range comments {
+		resp.Items = append(resp.Items, NewCommentFromEntity(e))
+	}
+}
+
+// UpdateCommentRequest is a request of UpdateComment.
+type UpdateCommentRequest struct {
+	Body string `json:"body,omitempty" valid:"Required;MaxSize(2000)"`
+}
+
+// Valid checks the body like the one of CreateCommentRequest.
+func (r *UpdateCommentRequest) Valid(v *validation.Validation) {
+	validEventText(v, "body", r.Body)
+}
+
+// UpdateComment API. Only the author can edit a comment.
+func (c *CommentController) UpdateComment() {
+	var req UpdateCommentRequest
+	var resp CommentResponse
+	resp.Code = status.OK
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		resp.Code = status.BadRequest
+		resp.SetError(err)
+		return
+	}
+
+	var validator validation.Validation
+	valid, err := validator.Valid(&req)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("UpdateComment", err)
+		return
+	}
+	if !valid {
+		resp.Code = status.BadRequest
+		resp.SetValidationErrors(validator.Errors)
+		return
+	}
+
+	comment := c.getOwnComment(&resp.Response)
+	if comment == nil {
+		return
+	}
+
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+	comment.Body = req.Body
+	if err := c.CRepo.Update(ctx, comment); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("UpdateComment", err)
+		return
+	}
+
+	resp.Comment = NewCommentFromEntity(comment)
+}
+
+// DeleteComment API. Only the author can delete a comment, its replies are kept.
+func (c *CommentController) DeleteComment() {
+	var resp Response
+	resp.Code = status.OK
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	comment := c.getOwnComment(&resp)
+	if comment == nil {
+		return
+	}
+
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+	if err := c.CRepo.Remove(ctx, comment); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("DeleteComment", err)
+		return
+	}
+}
+
+// getOwnComment finds the comment of the route and checks that the caller wrote it.
+// It returns nil after setting the error to resp otherwise.
+func (c *CommentController) getOwnComment(resp *Response) *entity.Comment {
+	videoID, err := strconv.ParseUint(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		resp.Code = status.BadRequest
+		resp.Message = "id is invalid"
+		return nil
+	}
+	commentID, err := strconv.ParseUint(c.Ctx.Input.Param(":commentId"), 10, 64)
+	if err != nil {
+		resp.Code = status.BadRequest
+		resp.Message = "commentId is invalid"
+		return nil
+	}
+
+	uid := c.Ctx.Input.GetData(constant.ContextUID).(uint)
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+
+	comment, err := c.CRepo.Get(ctx, uint(commentID))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("getOwnComment", err)
+		return nil
+	}
+	if comment == nil || comment.VideoID != uint(videoID) {
+		resp.Code = status.NotFound
+		resp.Message = `comment not found`
+		return nil
+	}
+	if comment.UserID != uid {
+		resp.Code = status.Forbidden
+		resp.Message = `comment belongs to another user`
+		return nil
+	}
+
+	return comment
+}
+
+// notifySharer pushes a new comment to the user who shared the video, unless they
+// wrote it, and to the subscribers of the video.
+func (c *CommentController) notifySharer(ctx context.Context, video *entity.Video, comment *Comment, uid uint) {
+	audience := ws.Audience{Topics: []string{ws.TopicVideo(video.ID)}}
+	if video.UserID != nil && *video.UserID != uid {
+		audience.To = []uint{*video.UserID}
+	}
+
+	c.publishEvent(ctx, c.Broker, EventCommentCreated, comment, audience)
+}
+
+// notifyComment adds a notification of a new comment to the inbox of the user who
+// shared the video and, for a reply, of the author of the parent comment.
+// Nobody is notified of their own comments.
+func (c *CommentController) notifyComment(ctx context.Context, video *entity.Video, parent *entity.Comment, comment *entity.Comment) {
+	var recipients []uint
+	if video.UserID != nil && *video.UserID != comment.UserID {
+		recipients = append(recipients, *video.UserID)
+	}
+	if parent != nil && parent.UserID != comment.UserID && (video.UserID == nil || parent.UserID != *video.UserID) {
+		recipients = append(recipients, parent.UserID)
+	}
+
+	notifications := make([]*entity.Notification, 0, len(recipients))
+	for _, recipient := range recipients {
+		notifications = append(notifications, &entity.Notification{
+			UserID:    recipient,
+			ActorID:   comment.UserID,
+			Kind:      entity.NotificationCommentCreated,
+			VideoID:   &comment.VideoID,
+			CommentID: &comment.ID,
+		})
+	}
+	c.notify(ctx, c.NRepo, c.Broker, notifications...)
+}
@@ -0,0 +1,249 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	beegoctx "github.com/beego/beego/context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"funny-project-be/domain/entity"
+	"funny-project-be/infra/constant"
+	"funny-project-be/infra/pubsub/pubsubimpl"
+	"funny-project-be/infra/status"
+	"funny-project-be/infra/ws"
+)
+
+type MockCommentRepo struct {
+	mu       sync.Mutex
+	comments []*entity.Comment
+}
+
+func (m *MockCommentRepo) Get(ctx context.Context, id uint) (*entity.Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.comments {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+func (m *MockCommentRepo) GetRangeByVideo(ctx context.Context, videoID uint, afterID uint, limit int) ([]*entity.Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var comments []*entity.Comment
+	for _, c := range m.comments {
+		if c.VideoID == videoID && c.ID > afterID && len(comments) < limit {
+			comments = append(comments, c)
+		}
+	}
+	return comments, nil
+}
+func (m *MockCommentRepo) Add(ctx context.Context, comments ...*entity.Comment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range comments {
+		c.ID = uint(len(m.comments) + 1)
+		m.comments = append(m.comments, c)
+	}
+	return nil
+}
+func (m *MockCommentRepo) Remove(ctx context.Context, comments ...*entity.Comment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, comment := range comments {
+		for i, c := range m.comments {
+			if c.ID == comment.ID {
+				m.comments = append(m.comments[:i], m.comments[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
+func (m *MockCommentRepo) Update(ctx context.Context, comments ...*entity.Comment) error {
+	return nil
+}
+
+func (c *CommentController) ServeJSON() {}
+
+func newCommentController(t *testing.T, cRepo *MockCommentRepo, uid uint, params map[string]string, body string) *CommentController {
+	req, err := http.NewRequest("POST", "/videos/1/comments", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vRepo := &MockVideoRepo{}
+	sharerID := uint(2)
+	vRepo.Add(context.Background(), &entity.Video{YouTubeID: "dQw4w9WgXcQ", UserID: &sharerID})
+
+	controller := &CommentController{
+		CRepo:  cRepo,
+		VRepo:  vRepo,
+		NRepo:  &MockNotificationRepo{},
+		Broker: pubsubimpl.NewMemoryBroker(),
+	}
+	controller.Ctx = &beegoctx.Context{
+		Input:          beegoctx.NewInput(),
+		Output:         beegoctx.NewOutput(),
+		Request:        req,
+		ResponseWriter: &beegoctx.Response{},
+	}
+	controller.Ctx.Input.Context = controller.Ctx
+	controller.Ctx.Input.RequestBody = []byte(body)
+	controller.Ctx.Input.SetParam(":id", "1")
+	for k, v := range params {
+		controller.Ctx.Input.SetParam(k, v)
+	}
+	controller.Ctx.Output.Context = controller.Ctx
+	controller.Data = make(map[interface{}]interface{})
+	controller.Ctx.Input.SetData(constant.ContextUID, uid)
+	controller.Ctx.Input.SetData(constant.ContextCtx, context.Background())
+
+	return controller
+}
+
+func TestCommentController_CreateComment(t *testing.T) {
+	cRepo := &MockCommentRepo{}
+
+	controller := newCommentController(t, cRepo, 1, nil, `{"body":"first!"}`)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := controller.Broker.Subscribe(ctx, ws.BroadcastChannel)
+	require.NoError(t, err)
+
+	controller.CreateComment()
+
+	resp := controller.Data["json"].(*CommentResponse)
+	assert.Equal(t, status.Created, resp.Code)
+	assert.Equal(t, uint(1), resp.Comment.UserID)
+	assert.Equal(t, "first!", resp.Comment.Body)
+
+	// The sharer is notified.
+	select {
+	case payload := <-ch:
+		var msg ws.Message
+		require.NoError(t, json.Unmarshal(payload, &msg))
+		assert.Equal(t, []uint{2}, msg.To)
+		assert.Equal(t, []string{"video:1"}, msg.Topics)
+		assert.Equal(t, EventCommentCreated, msg.Type)
+		var comment Comment
+		require.NoError(t, json.Unmarshal(msg.Payload, &comment))
+		assert.Equal(t, "first!", comment.Body)
+	case <-time.After(time.Second):
+		t.Fatal("comment event not published")
+	}
+
+	// Reply to it.
+	controller = newCommentController(t, cRepo, 3, nil, `{"body":"second","parentId":1}`)
+	controller.CreateComment()
+	resp = controller.Data["json"].(*CommentResponse)
+	assert.Equal(t, status.Created, resp.Code)
+	assert.Equal(t, uint(1), *resp.Comment.ParentID)
+
+	// Reply to an unknown comment.
+	controller = newCommentController(t, cRepo, 3, nil, `{"body":"third","parentId":42}`)
+	controller.CreateComment()
+	assert.Equal(t, status.BadRequest, controller.Data["json"].(*CommentResponse).Code)
+}
+
+func TestCommentController_CreateCommentEncodedSize(t *testing.T) {
+	// 2000 characters, escaped as \u003c by json.Marshal, would not fit in the event.
+	controller := newCommentController(t, &MockCommentRepo{}, 1, nil, `{"body":`+strconv.Quote(strings.Repeat("<", 2000))+`}`)
+	controller.CreateComment()
+	assert.Equal(t, status.BadRequest, controller.Data["json"].(*CommentResponse).Code)
+
+	controller = newCommentController(t, &MockCommentRepo{}, 1, nil, `{"body":`+strconv.Quote(strings.Repeat("<", 600))+`}`)
+	controller.CreateComment()
+	assert.Equal(t, status.Created, controller.Data["json"].(*CommentResponse).Code)
+}
+
+func TestCommentController_ListComments(t *testing.T) {
+	cRepo := &MockCommentRepo{}
+	for i := 0; i < 5; i++ {
+		cRepo.Add(context.Background(), &entity.Comment{VideoID: 1, UserID: 1, Body: "hi"})
+	}
+
+	var ids []uint
+	cursor := ""
+	for page := 0; page < 3; page++ {
+		controller := newCommentController(t, cRepo, 1, nil, ``)
+		controller.Ctx.Request.Form = map[string][]string{"limit": {"2"}, "cursor": {cursor}}
+		controller.ListComments()
+
+		resp := controller.Data["json"].(*ListCommentsResponse)
+		require.Equal(t, status.OK, resp.Code)
+		for _, c := range resp.Items {
+			ids = append(ids, c.ID)
+		}
+		cursor = resp.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+	assert.Equal(t, []uint{1, 2, 3, 4, 5}, ids)
+	assert.Empty(t, cursor)
+
+	controller := newCommentController(t, cRepo, 1, nil, ``)
+	controller.Ctx.Request.Form = map[string][]string{"cursor": {"not-a-cursor"}}
+	controller.ListComments()
+	assert.Equal(t, status.BadRequest, controller.Data["json"].(*ListCommentsResponse).Code)
+}
+
+func TestCommentController_HiddenVideo(t *testing.T) {
+	cRepo := &MockCommentRepo{}
+	cRepo.Add(context.Background(), &entity.Comment{VideoID: 1, UserID: 1, Body: "hi"})
+	now := time.Now()
+
+	controller := newCommentController(t, cRepo, 1, nil, `{"body":"first!"}`)
+	controller.VRepo.(*MockVideoRepo).videos[0].HiddenAt = &now
+	controller.CreateComment()
+	assert.Equal(t, status.NotFound, controller.Data["json"].(*CommentResponse).Code)
+	assert.Len(t, cRepo.comments, 1)
+
+	controller = newCommentController(t, cRepo, 1, nil, ``)
+	controller.VRepo.(*MockVideoRepo).videos[0].HiddenAt = &now
+	controller.ListComments()
+	resp := controller.Data["json"].(*ListCommentsResponse)
+	assert.Equal(t, status.NotFound, resp.Code)
+	assert.Empty(t, resp.Items)
+}
+
+func TestCommentController_UpdateAndDeleteOwnComment(t *testing.T) {
+	cRepo := &MockCommentRepo{}
+	cRepo.Add(context.Background(), &entity.Comment{VideoID: 1, UserID: 1, Body: "hi"})
+	params := map[string]string{":commentId": "1"}
+
+	// Another user can neither edit nor delete it.
+	controller := newCommentController(t, cRepo, 2, params, `{"body":"hacked"}`)
+	controller.UpdateComment()
+	assert.Equal(t, status.Forbidden, controller.Data["json"].(*CommentResponse).Code)
+
+	controller = newCommentController(t, cRepo, 2, params, ``)
+	controller.DeleteComment()
+	assert.Equal(t, status.Forbidden, controller.Data["json"].(*Response).Code)
+
+	controller = newCommentController(t, cRepo, 1, params, `{"body":"edited"}`)
+	controller.UpdateComment()
+	resp := controller.Data["json"].(*CommentResponse)
+	assert.Equal(t, status.OK, resp.Code)
+	assert.Equal(t, "edited", resp.Comment.Body)
+
+	controller = newCommentController(t, cRepo, 1, params, ``)
+	controller.DeleteComment()
+	assert.Equal(t, status.OK, controller.Data["json"].(*Response).Code)
+	assert.Empty(t, cRepo.comments)
+
+	controller = newCommentController(t, cRepo, 1, params, ``)
+	controller.DeleteComment()
+	assert.Equal(t, status.NotFound, controller.Data["json"].(*Response).Code)
+}
@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// errInvalidCursor is returned when a client sends a cursor it did not receive from us.
+var errInvalidCursor = errors.New("cursor is invalid")
+
+// encodeCursor encodes the position v into an opaque cursor.
+func encodeCursor(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor decodes an opaque cursor into the position v.
+func decodeCursor(cursor string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return errInvalidCursor
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errInvalidCursor
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/beego/beego/validation"
+
+	"funny-project-be/domain/pubsub"
+	"funny-project-be/infra/ws"
+)
+
+// Event types pushed over the WebSocket channel. New videos are still pushed
+// as a bare Video to the legacy clients which predate typed events.
+const (
+	// EventVideoCreated is pushed to the followers of the sharer of a new video, its payload is the Video.
+	EventVideoCreated = "video.created"
+	// EventVideoUpdated is pushed when a video is edited, its payload is the Video.
+	EventVideoUpdated = "video.updated"
+	// EventVideoDeleted is pushed when a video is deleted, its payload is a VideoDeleted.
+	EventVideoDeleted = "video.deleted"
+	// EventReactionUpdated is pushed when a user reacts to a video, its payload is a ReactionEvent.
+	EventReactionUpdated = "reaction.updated"
+	// EventCommentCreated is pushed to the sharer when a video is commented on, its payload is the Comment.
+	EventCommentCreated = "comment.created"
+	// EventNotification is pushed to the user of a new Notification, its payload.
+	EventNotification = "notification"
+)
+
+// maxEventTextSize is the largest JSON encoding of a text written by users and
+// pushed in events, e.g. a comment body or a video description, so that these
+// events fit in pubsub.MaxPayloadSize along with their other fields.
+const maxEventTextSize = 4000
+
+// validEventText sets an error of key to v when the JSON encoding of text is larger
+// than maxEventTextSize. MaxSize counts characters, but json.Marshal writes up to
+// 6 bytes for each of them, e.g. \u003c for <.
+func validEventText(v *validation.Validation, key string, text string) {
+	data, err := json.Marshal(text)
+	if err != nil || len(data)-2 > maxEventTextSize {
+		v.SetError(key, fmt.Sprintf("Maximum encoded size is %d bytes", maxEventTextSize))
+	}
+}
+
+// VideoDeleted is the payload of EventVideoDeleted.
+type VideoDeleted struct {
+	ID uint `json:"id"`
+}
+
+// videoAudience returns the audience of the changes of video id.
+func videoAudience(id uint) ws.Audience {
+	return ws.Audience{Topics: []string{ws.TopicVideos, ws.TopicVideo(id)}}
+}
+
+// publishEvent pushes an event to the WebSocket clients of audience on every replica.
+func (c *BaseController) publishEvent(ctx context.Context, b pubsub.Broker, eventType string, payload interface{}, audience ws.Audience) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		c.logger().ErrorContext(ctx, "Fail to marshal event", "type", eventType, "err", err)
+		return
+	}
+
+	if err := ws.Publish(ctx, b, ws.Message{Audience: audience, Type: eventType, Payload: data}); err != nil {
+		c.logger().ErrorContext(ctx, "Fail to publish event", "type", eventType, "err", err)
+	}
+}
@@ -0,0 +1,19 @@
+package controller
+
+import (
+	"funny-project-be/infra/beego/plugin/authn"
+)
+
+// KeyController exposes the public keys verifying access tokens.
+type KeyController struct {
+	BaseController
+
+	Keys *authn.KeySet
+}
+
+// GetJWKS API. It publishes the public signing keys as a JSON Web Key Set.
+func (c *KeyController) GetJWKS() {
+	c.Ctx.Output.Header("Cache-Control", "public, max-age=300")
+	c.Data["json"] = c.Keys.JWKS()
+	c.ServeJSON()
+}
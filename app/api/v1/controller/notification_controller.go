@@ -0,0 +1,296 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/beego/beego/validation"
+
+	"funny-project-be/domain/entity"
+	"funny-project-be/domain/pubsub"
+	"funny-project-be/domain/repo"
+	"funny-project-be/infra/constant"
+	"funny-project-be/infra/options"
+	"funny-project-be/infra/status"
+	"funny-project-be/infra/ws"
+)
+
+// defaultNotificationLimit is the page size of ListNotifications when no limit is given.
+const defaultNotificationLimit = 20
+
+// replayLimit is the number of missed notifications replayed to a WebSocket,
+// below the send queue of its client. Older ones are listed by ListNotifications.
+const replayLimit = 20
+
+// NotificationController exposes apis of Notification resource.
+type NotificationController struct {
+	BaseController
+
+	NRepo repo.NotificationRepo
+
+	Opts options.Options
+}
+
+// Notification info.
+type Notification struct {
+	// ID is omitted from the events of new shares, pushed to every follower at once.
+	ID           uint       `json:"id,omitempty"`
+	Kind         string     `json:"kind"`
+	Actor        *Author    `json:"actor,omitempty"`
+	VideoID      *uint      `json:"videoId,omitempty"`
+	CommentID    *uint      `json:"commentId,omitempty"`
+	ReactionKind string     `json:"reactionKind,omitempty"`
+	ReadAt       *time.Time `json:"readAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt,omitempty"`
+}
+
+// NewNotificationFromEntity creates Notification from entity.
+func NewNotificationFromEntity(e *entity.Notification) *Notification {
+	if e == nil {
+		return nil
+	}
+
+	actor := NewAuthorFromEntity(e.Actor)
+	if actor == nil {
+		actor = &Author{ID: e.ActorID}
+	}
+
+	return &Notification{
+		ID:           e.ID,
+		Kind:         e.Kind,
+		Actor:        actor,
+		VideoID:      e.VideoID,
+		CommentID:    e.CommentID,
+		ReactionKind: e.ReactionKind,
+		ReadAt:       e.ReadAt,
+		CreatedAt:    e.CreatedAt,
+	}
+}
+
+// ListNotificationsRequest represents a request for listing notifications.
+type ListNotificationsRequest struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" valid:"Range(1, 200)"`
+}
+
+// ListNotificationsResponse is the response of ListNotifications.
+type ListNotificationsResponse struct {
+	CursorResponse
+	UnreadCount int64           `json:"unreadCount"`
+	Items       []*Notification `json:"_items"`
+}
+
+// notificationCursor is the position encoded in the cursors of ListNotifications.
+type notificationCursor struct {
+	ID uint `json:"id"`
+}
+
+// ListNotifications API. Notifications are listed newest first, read ones included.
+func (c *NotificationController) ListNotifications() {
+	var req ListNotificationsRequest
+	var resp ListNotificationsResponse
+	resp.Code = status.OK
+	resp.Items = []*Notification{}
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	if err := c.ParseForm(&req); err != nil {
+		resp.Code = status.BadRequest
+		resp.SetError(err)
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultNotificationLimit
+	}
+
+	var validator validation.Validation
+	valid, err := validator.Valid(&req)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("ListNotifications", err)
+		return
+	}
+	if !valid {
+		resp.Code = status.BadRequest
+		resp.SetValidationErrors(validator.Errors)
+		return
+	}
+
+	var before notificationCursor
+	if req.Cursor != "" {
+		if err := decodeCursor(req.Cursor, &before); err != nil {
+			resp.Code = status.BadRequest
+			resp.SetError(err)
+			return
+		}
+	}
+
+	uid := c.Ctx.Input.GetData(constant.ContextUID).(uint)
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+
+	// Fetch one more notification to know whether there is a next page.
+	notifications, err := c.NRepo.GetRangeByUser(ctx, uid, before.ID, 0, req.Limit+1)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("ListNotifications", err)
+		return
+	}
+	if len(notifications) > req.Limit {
+		notifications = notifications[:req.Limit]
+		resp.NextCursor = encodeCursor(notificationCursor{ID: notifications[len(notifications)-1].ID})
+	}
+
+	if resp.UnreadCount, err = c.NRepo.CountUnread(ctx, uid); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("ListNotifications", err)
+		return
+	}
+
+	resp.Limit = req.Limit
+	for _, n := range notifications {
+		resp.Items = append(resp.Items, NewNotificationFromEntity(n))
+	}
+}
+
+// MarkNotificationsReadRequest represents a request marking notifications as read.
+// Either IDs or All must be given.
+type MarkNotificationsReadRequest struct {
+	IDs []uint `json:"ids"`
+	All bool   `json:"all"`
+}
+
+// Valid checks that the request selects some notifications.
+func (r *MarkNotificationsReadRequest) Valid(v *validation.Validation) {
+	if len(r.IDs) == 0 && !r.All {
+		v.SetError("ids", "Required unless all is set")
+	}
+	if len(r.IDs) > 0 && r.All {
+		v.SetError("all", "Can not be used with ids")
+	}
+	if len(r.IDs) > 200 {
+		v.SetError("ids", "Maximum size is 200")
+	}
+}
+
+// MarkNotificationsReadResponse is the response of MarkNotificationsRead.
+type MarkNotificationsReadResponse struct {
+	Response
+	UnreadCount int64 `json:"unreadCount"`
+}
+
+// MarkNotificationsRead API. Ids of other users' notifications are ignored.
+func (c *NotificationController) MarkNotificationsRead() {
+	var req MarkNotificationsReadRequest
+	var resp MarkNotificationsReadResponse
+	resp.Code = status.OK
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		resp.Code = status.BadRequest
+		resp.SetError(err)
+		return
+	}
+
+	var validator validation.Validation
+	valid, err := validator.Valid(&req)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("MarkNotificationsRead", err)
+		return
+	}
+	if !valid {
+		resp.Code = status.BadRequest
+		resp.SetValidationErrors(validator.Errors)
+		return
+	}
+
+	uid := c.Ctx.Input.GetData(constant.ContextUID).(uint)
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+
+	if err := c.NRepo.MarkRead(ctx, uid, req.IDs); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("MarkNotificationsRead", err)
+		return
+	}
+	if resp.UnreadCount, err = c.NRepo.CountUnread(ctx, uid); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("MarkNotificationsRead", err)
+		return
+	}
+}
+
+// notify stores notifications in the inbox of their users and pushes each of
+// them to its user. Notifying is best effort, errors are only logged.
+func (c *BaseController) notify(ctx context.Context, nRepo repo.NotificationRepo, b pubsub.Broker, notifications ...*entity.Notification) {
+	if len(notifications) == 0 {
+		return
+	}
+
+	if err := nRepo.Add(ctx, notifications...); err != nil {
+		c.logger().ErrorContext(ctx, "Fail to add notifications", "err", err)
+		return
+	}
+
+	for _, n := range notifications {
+		msg, err := newNotificationEvent(n)
+		if err != nil {
+			c.logger().ErrorContext(ctx, "Fail to marshal event", "type", EventNotification, "err", err)
+			continue
+		}
+		msg.To = []uint{n.UserID}
+		if err := ws.Publish(ctx, b, *msg); err != nil {
+			c.logger().ErrorContext(ctx, "Fail to publish event", "type", EventNotification, "err", err)
+		}
+	}
+}
+
+// newNotificationEvent returns the EventNotification of n. Its id is the id of n,
+// so that event streams resume from the last notification they received.
+func newNotificationEvent(n *entity.Notification) (*ws.Message, error) {
+	data, err := json.Marshal(NewNotificationFromEntity(n))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ws.Message{Type: EventNotification, Payload: data, ID: n.ID}, nil
+}
+
+// replayNotifications sends the notifications of user uid newer than lastSeenID to
+// the WebSocket or event stream client, oldest first. Only the newest replayLimit ones are sent.
+// A notification created while the client registered may be sent twice, clients
+// drop the ids they have seen.
+func replayNotifications(ctx context.Context, nRepo repo.NotificationRepo, hub *ws.Hub, client *ws.Client, uid uint, lastSeenID uint) error {
+	notifications, err := nRepo.GetRangeByUser(ctx, uid, 0, lastSeenID, replayLimit)
+	if err != nil {
+		return err
+	}
+
+	for i := len(notifications) - 1; i >= 0; i-- {
+		msg, err := newNotificationEvent(notifications[i])
+		if err != nil {
+			return err
+		}
+		if !hub.SendEvent(client, msg) {
+			return errors.New("client is gone or its queue is full")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,340 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	beegoctx "github.com/beego/beego/context"
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"funny-project-be/domain/entity"
+	"funny-project-be/infra/beego/plugin/authn"
+	"funny-project-be/infra/constant"
+	"funny-project-be/infra/options"
+	"funny-project-be/infra/status"
+	"funny-project-be/infra/ws"
+)
+
+type MockNotificationRepo struct {
+	mu            sync.Mutex
+	notifications []*entity.Notification
+}
+
+func (m *MockNotificationRepo) GetRangeByUser(ctx context.Context, userID uint, beforeID uint, afterID uint, limit int) ([]*entity.Notification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var notifications []*entity.Notification
+	for _, n := range m.notifications {
+		if n.UserID == userID && (beforeID == 0 || n.ID < beforeID) && n.ID > afterID {
+			notifications = append(notifications, n)
+		}
+	}
+	sort.Slice(notifications, func(i, j int) bool { return notifications[i].ID > notifications[j].ID })
+	if len(notifications) > limit {
+		notifications = notifications[:limit]
+	}
+	return notifications, nil
+}
+func (m *MockNotificationRepo) CountUnread(ctx context.Context, userID uint) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var count int64
+	for _, n := range m.notifications {
+		if n.UserID == userID && n.ReadAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+func (m *MockNotificationRepo) MarkRead(ctx context.Context, userID uint, ids []uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, n := range m.notifications {
+		if n.UserID != userID || n.ReadAt != nil {
+			continue
+		}
+		for _, id := range ids {
+			if n.ID == id {
+				n.ReadAt = &now
+			}
+		}
+		if len(ids) == 0 {
+			n.ReadAt = &now
+		}
+	}
+	return nil
+}
+func (m *MockNotificationRepo) Add(ctx context.Context, notifications ...*entity.Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, n := range notifications {
+		n.ID = uint(len(m.notifications) + 1)
+		m.notifications = append(m.notifications, n)
+	}
+	return nil
+}
+
+// kinds returns the kinds of the notifications of user uid, oldest first.
+func (m *MockNotificationRepo) kinds(uid uint) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var kinds []string
+	for _, n := range m.notifications {
+		if n.UserID == uid {
+			kinds = append(kinds, n.Kind)
+		}
+	}
+	return kinds
+}
+
+func (c *NotificationController) ServeJSON() {}
+
+func newNotificationController(t *testing.T, nRepo *MockNotificationRepo, body string) *NotificationController {
+	req, err := http.NewRequest("GET", "/notifications", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	controller := &NotificationController{
+		NRepo: nRepo,
+	}
+	controller.Ctx = &beegoctx.Context{
+		Input:          beegoctx.NewInput(),
+		Output:         beegoctx.NewOutput(),
+		Request:        req,
+		ResponseWriter: &beegoctx.Response{},
+	}
+	controller.Ctx.Input.Context = controller.Ctx
+	controller.Ctx.Input.RequestBody = []byte(body)
+	controller.Ctx.Output.Context = controller.Ctx
+	controller.Data = make(map[interface{}]interface{})
+	controller.Ctx.Input.SetData(constant.ContextUID, uint(1))
+	controller.Ctx.Input.SetData(constant.ContextCtx, context.Background())
+
+	return controller
+}
+
+func TestNotificationController_ListNotifications(t *testing.T) {
+	nRepo := &MockNotificationRepo{}
+	for i := 0; i < 3; i++ {
+		nRepo.Add(context.Background(),
+			&entity.Notification{UserID: 1, ActorID: 2, Kind: entity.NotificationVideoShared},
+			&entity.Notification{UserID: 2, ActorID: 1, Kind: entity.NotificationVideoShared},
+		)
+	}
+	list := func(form url.Values) *ListNotificationsResponse {
+		controller := newNotificationController(t, nRepo, ``)
+		controller.Ctx.Request.Form = form
+		controller.ListNotifications()
+		return controller.Data["json"].(*ListNotificationsResponse)
+	}
+
+	resp := list(url.Values{"limit": {"2"}})
+	require.Equal(t, status.OK, resp.Code)
+	assert.Equal(t, int64(3), resp.UnreadCount)
+	require.Len(t, resp.Items, 2)
+	assert.Equal(t, uint(5), resp.Items[0].ID)
+	assert.Equal(t, uint(3), resp.Items[1].ID)
+	assert.Equal(t, uint(2), resp.Items[0].Actor.ID)
+	require.NotEmpty(t, resp.NextCursor)
+
+	resp = list(url.Values{"limit": {"2"}, "cursor": {resp.NextCursor}})
+	require.Equal(t, status.OK, resp.Code)
+	require.Len(t, resp.Items, 1)
+	assert.Equal(t, uint(1), resp.Items[0].ID)
+	assert.Empty(t, resp.NextCursor)
+
+	resp = list(url.Values{})
+	assert.Equal(t, defaultNotificationLimit, resp.Limit)
+	assert.Equal(t, status.BadRequest, list(url.Values{"cursor": {"bad"}}).Code)
+}
+
+func TestNotificationController_MarkNotificationsRead(t *testing.T) {
+	nRepo := &MockNotificationRepo{}
+	nRepo.Add(context.Background(),
+		&entity.Notification{UserID: 1},
+		&entity.Notification{UserID: 1},
+		&entity.Notification{UserID: 2},
+		&entity.Notification{UserID: 1},
+	)
+	markRead := func(body string) *MarkNotificationsReadResponse {
+		controller := newNotificationController(t, nRepo, body)
+		controller.MarkNotificationsRead()
+		return controller.Data["json"].(*MarkNotificationsReadResponse)
+	}
+
+	// Ids of other users are ignored.
+	resp := markRead(`{"ids":[1,3]}`)
+	require.Equal(t, status.OK, resp.Code)
+	assert.Equal(t, int64(2), resp.UnreadCount)
+	assert.NotNil(t, nRepo.notifications[0].ReadAt)
+	assert.Nil(t, nRepo.notifications[2].ReadAt)
+
+	resp = markRead(`{"all":true}`)
+	require.Equal(t, status.OK, resp.Code)
+	assert.Equal(t, int64(0), resp.UnreadCount)
+	assert.Nil(t, nRepo.notifications[2].ReadAt)
+
+	for _, body := range []string{`{}`, `{"ids":[1],"all":true}`, `{"ids":"1"}`} {
+		assert.Equal(t, status.BadRequest, markRead(body).Code, body)
+	}
+}
+
+func TestNotifications_Written(t *testing.T) {
+	nRepo := &MockNotificationRepo{}
+
+	// Followers are notified of shares.
+	controller := newVideoController(t, "POST", `{"url":"https://www.youtube.com/watch?v=dQw4w9WgXcQ"}`)
+	controller.NRepo = nRepo
+	controller.FRepo.(*MockFollowRepo).Add(context.Background(), &entity.Follow{FollowerID: 3, FolloweeID: 1})
+	controller.CreateVideo()
+	require.Equal(t, status.Created, controller.Data["json"].(*CreateVideoResponse).Code)
+	// The inboxes of the followers are filled off the request.
+	require.Eventually(t, func() bool { return len(nRepo.kinds(3)) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{entity.NotificationVideoShared}, nRepo.kinds(3))
+
+	// The sharer of video 1, user 2, is notified of comments and the author of a comment of replies.
+	comment := newCommentController(t, &MockCommentRepo{}, 3, nil, `{"body":"first!"}`)
+	comment.NRepo = nRepo
+	comment.CreateComment()
+	require.Equal(t, status.Created, comment.Data["json"].(*CommentResponse).Code)
+	reply := newCommentController(t, comment.CRepo.(*MockCommentRepo), 4, nil, `{"body":"second!","parentId":1}`)
+	reply.NRepo = nRepo
+	reply.CreateComment()
+	require.Equal(t, status.Created, reply.Data["json"].(*CommentResponse).Code)
+	assert.Equal(t, []string{entity.NotificationCommentCreated, entity.NotificationCommentCreated}, nRepo.kinds(2))
+	assert.Equal(t, []string{entity.NotificationVideoShared, entity.NotificationCommentCreated}, nRepo.kinds(3))
+	assert.Empty(t, nRepo.kinds(4))
+
+	body, err := json.Marshal(NewNotificationFromEntity(nRepo.notifications[len(nRepo.notifications)-1]))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":4,"kind":"comment.created","actor":{"id":4},"videoId":1,"commentId":2,"createdAt":"0001-01-01T00:00:00Z"}`, string(body))
+}
+
+func TestNotifications_WrittenForReactions(t *testing.T) {
+	nRepo := &MockNotificationRepo{}
+	rRepo := &MockReactionRepo{}
+	vRepo := &MockVideoRepo{}
+	vRepo.Add(context.Background(), &entity.Video{UserID: userID(2)})
+	react := func(uid uint, kind string) {
+		controller := newReactionController(t, rRepo, uid, "1", `{"kind":"`+kind+`"}`)
+		controller.VRepo = vRepo
+		controller.NRepo = nRepo
+		controller.PutReaction()
+		require.Equal(t, status.OK, controller.Data["json"].(*ReactionResponse).Code)
+	}
+
+	// The same reaction put again and the sharer's own reactions are not notified.
+	react(1, entity.ReactionLike)
+	react(1, entity.ReactionLike)
+	react(1, entity.ReactionDislike)
+	react(2, entity.ReactionLike)
+
+	require.Equal(t, []string{entity.NotificationReactionCreated, entity.NotificationReactionCreated}, nRepo.kinds(2))
+	assert.Equal(t, entity.ReactionLike, nRepo.notifications[0].ReactionKind)
+	assert.Equal(t, entity.ReactionDislike, nRepo.notifications[1].ReactionKind)
+}
+
+func TestReplayNotifications(t *testing.T) {
+	nRepo := &MockNotificationRepo{}
+	for i := 0; i < replayLimit+3; i++ {
+		nRepo.Add(context.Background(), &entity.Notification{UserID: 1, Kind: entity.NotificationVideoShared})
+	}
+	nRepo.Add(context.Background(), &entity.Notification{UserID: 2})
+
+	hub := ws.NewHub(slog.Default())
+	clients := make(chan *ws.Client, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		clients <- hub.Register(1, r.RemoteAddr, conn)
+	}))
+	defer srv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Only the newest notifications are replayed, oldest first.
+	require.NoError(t, replayNotifications(context.Background(), nRepo, hub, <-clients, 1, 1))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for id := uint(4); id <= replayLimit+3; id++ {
+		_, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		var event struct {
+			Type    string
+			Payload Notification
+		}
+		require.NoError(t, json.Unmarshal(data, &event))
+		assert.Equal(t, EventNotification, event.Type)
+		assert.Equal(t, id, event.Payload.ID)
+	}
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _, err = conn.ReadMessage()
+	assert.Error(t, err)
+}
+
+func TestWSAuthenticator(t *testing.T) {
+	keys, err := authn.NewKeySet(options.Options{AccessTokenSecret: "secret"})
+	require.NoError(t, err)
+	token, err := keys.Sign(jwt.MapClaims{"sub": "1", "exp": time.Now().Add(time.Hour).Unix()})
+	require.NoError(t, err)
+
+	nRepo := &MockNotificationRepo{}
+	for i := 0; i < 3; i++ {
+		nRepo.Add(context.Background(), &entity.Notification{UserID: 1, Kind: entity.NotificationVideoShared})
+	}
+
+	hub := ws.NewHub(slog.Default())
+	auth := &wsAuthenticator{Keys: keys, RevRepo: &MockRevokedTokenRepo{}, NRepo: nRepo, Hub: hub, Log: slog.Default()}
+
+	// The Bearer prefix is optional.
+	for _, tok := range []string{token, "Bearer " + token} {
+		uid, err := auth.Authenticate(context.Background(), tok)
+		require.NoError(t, err)
+		assert.Equal(t, uint(1), uid)
+	}
+	_, err = auth.Authenticate(context.Background(), "Bearer nope")
+	assert.Error(t, err)
+
+	upgrader := websocket.Upgrader{Subprotocols: []string{ws.Subprotocol}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		hub.Serve(r.RemoteAddr, conn, auth)
+	}))
+	defer srv.Close()
+	dialer := websocket.Dialer{Subprotocols: []string{ws.Subprotocol}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// The notifications newer than lastSeenId follow the ack of auth.
+	cmd, err := json.Marshal(ws.Envelope{Type: ws.CommandAuth, Version: ws.Version1, ID: "1", Payload: json.RawMessage(`{"token":"` + token + `","lastSeenId":1}`)})
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, cmd))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for _, want := range []string{ws.FrameAck, EventNotification, EventNotification} {
+		var env ws.Envelope
+		require.NoError(t, conn.ReadJSON(&env))
+		assert.Equal(t, want, env.Type)
+		assert.Equal(t, ws.Version1, env.Version)
+	}
+}
@@ -0,0 +1,231 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/beego/beego/validation"
+	"gorm.io/gorm"
+
+	"funny-project-be/domain/entity"
+	"funny-project-be/domain/pubsub"
+	"funny-project-be/domain/repo"
+	"funny-project-be/infra/constant"
+	"funny-project-be/infra/options"
+	"funny-project-be/infra/status"
+)
+
+// ReactionController exposes apis of Reaction resource.
+type ReactionController struct {
+	BaseController
+
+	RRepo repo.ReactionRepo
+	VRepo repo.VideoRepo
+	NRepo repo.NotificationRepo
+
+	Broker pubsub.Broker
+
+	Opts options.Options
+}
+
+// PutReactionRequest is a request of PutReaction.
+type PutReactionRequest struct {
+	Kind string `json:"kind,omitempty" valid:"Required"`
+}
+
+// ReactionResponse is the response of PutReaction and DeleteReaction.
+type ReactionResponse struct {
+	Response
+	VideoID    uint   `json:"videoId,omitempty"`
+	Likes      int64  `json:"likes"`
+	Dislikes   int64  `json:"dislikes"`
+	MyReaction string `json:"myReaction,omitempty"`
+}
+
+// ReactionEvent is the payload of the reaction.updated event.
+type ReactionEvent struct {
+	VideoID  uint   `json:"videoId"`
+	UserID   uint   `json:"userId"`
+	Reaction string `json:"reaction,omitempty"`
+	Likes    int64  `json:"likes"`
+	Dislikes int64  `json:"dislikes"`
+}
+
+// PutReaction API.
+func (c *ReactionController) PutReaction() {
+	var req PutReactionRequest
+	var resp ReactionResponse
+	resp.Code = status.OK
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	videoID, err := strconv.ParseUint(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		resp.Code = status.BadRequest
+		resp.Message = "id is invalid"
+		return
+	}
+
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		resp.Code = status.BadRequest
+		resp.SetError(err)
+		return
+	}
+
+	var validator validation.Validation
+	valid, err := validator.Valid(&req)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("PutReaction", err)
+		return
+	}
+	if !valid {
+		resp.Code = status.BadRequest
+		resp.SetValidationErrors(validator.Errors)
+		return
+	}
+	if req.Kind != entity.ReactionLike && req.Kind != entity.ReactionDislike {
+		resp.Code = status.BadRequest
+		resp.Message = `kind must be like or dislike`
+		return
+	}
+
+	uid := c.Ctx.Input.GetData(constant.ContextUID).(uint)
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+
+	video := c.getVisibleVideo(ctx, c.VRepo, uint(videoID), &resp.Response, "PutReaction")
+	if video == nil {
+		return
+	}
+
+	// The sharer is only notified of new reactions, not of the same one put again.
+	var previousKind string
+	previous, err := c.RRepo.Get(ctx, uid, video.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("PutReaction", err)
+		return
+	}
+	if previous != nil {
+		previousKind = previous.Kind
+	}
+
+	reaction := &entity.Reaction{
+		UserID:  uid,
+		VideoID: uint(videoID),
+		Kind:    req.Kind,
+	}
+	if err := c.RRepo.Upsert(ctx, reaction); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("PutReaction", err)
+		return
+	}
+
+	if err := c.setCounts(ctx, &resp, uint(videoID)); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("PutReaction", err)
+		return
+	}
+	resp.MyReaction = req.Kind
+
+	c.publishReaction(ctx, &resp, uid)
+	if previousKind != req.Kind {
+		c.notifySharer(ctx, video, reaction)
+	}
+}
+
+// DeleteReaction API.
+func (c *ReactionController) DeleteReaction() {
+	var resp ReactionResponse
+	resp.Code = status.OK
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	videoID, err := strconv.ParseUint(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		resp.Code = status.BadRequest
+		resp.Message = "id is invalid"
+		return
+	}
+
+	uid := c.Ctx.Input.GetData(constant.ContextUID).(uint)
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+
+	if c.getVisibleVideo(ctx, c.VRepo, uint(videoID), &resp.Response, "DeleteReaction") == nil {
+		return
+	}
+
+	if err := c.RRepo.Remove(ctx, &entity.Reaction{UserID: uid, VideoID: uint(videoID)}); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("DeleteReaction", err)
+		return
+	}
+
+	if err := c.setCounts(ctx, &resp, uint(videoID)); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("DeleteReaction", err)
+		return
+	}
+
+	c.publishReaction(ctx, &resp, uid)
+}
+
+// setCounts sets the like and dislike counters of the video to resp.
+func (c *ReactionController) setCounts(ctx context.Context, resp *ReactionResponse, videoID uint) error {
+	counts, err := c.RRepo.CountByVideoIDs(ctx, []uint{videoID})
+	if err != nil {
+		return err
+	}
+
+	resp.VideoID = videoID
+	if count, ok := counts[videoID]; ok {
+		resp.Likes = count.Likes
+		resp.Dislikes = count.Dislikes
+	}
+
+	return nil
+}
+
+// publishReaction pushes the new counters to the WebSocket subscribers of the
+// video, including the other sessions of the reacting user.
+func (c *ReactionController) publishReaction(ctx context.Context, resp *ReactionResponse, uid uint) {
+	c.publishEvent(ctx, c.Broker, EventReactionUpdated, &ReactionEvent{
+		VideoID:  resp.VideoID,
+		UserID:   uid,
+		Reaction: resp.MyReaction,
+		Likes:    resp.Likes,
+		Dislikes: resp.Dislikes,
+	}, videoAudience(resp.VideoID))
+}
+
+// notifySharer adds a notification of a reaction to the inbox of the user who
+// shared the video, unless they reacted to their own video.
+func (c *ReactionController) notifySharer(ctx context.Context, video *entity.Video, reaction *entity.Reaction) {
+	if video.UserID == nil || *video.UserID == reaction.UserID {
+		return
+	}
+
+	c.notify(ctx, c.NRepo, c.Broker, &entity.Notification{
+		UserID:       *video.UserID,
+		ActorID:      reaction.UserID,
+		Kind:         entity.NotificationReactionCreated,
+		VideoID:      &video.ID,
+		ReactionKind: reaction.Kind,
+	})
+}
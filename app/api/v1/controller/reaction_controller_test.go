@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	beegoctx "github.com/beego/beego/context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"funny-project-be/domain/entity"
+	"funny-project-be/infra/constant"
+	"funny-project-be/infra/pubsub/pubsubimpl"
+	"funny-project-be/infra/status"
+	"funny-project-be/infra/ws"
+)
+
+type MockReactionRepo struct {
+	mu        sync.Mutex
+	reactions []*entity.Reaction
+}
+
+func (m *MockReactionRepo) Get(ctx context.Context, userID uint, videoID uint) (*entity.Reaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.reactions {
+		if r.UserID == userID && r.VideoID == videoID {
+			return r, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+func (m *MockReactionRepo) GetByVideoIDs(ctx context.Context, userID uint, videoIDs []uint) ([]*entity.Reaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var reactions []*entity.Reaction
+	for _, r := range m.reactions {
+		for _, id := range videoIDs {
+			if r.UserID == userID && r.VideoID == id {
+				reactions = append(reactions, r)
+			}
+		}
+	}
+	return reactions, nil
+}
+func (m *MockReactionRepo) CountByVideoIDs(ctx context.Context, videoIDs []uint) (map[uint]*entity.ReactionCount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := map[uint]*entity.ReactionCount{}
+	for _, r := range m.reactions {
+		for _, id := range videoIDs {
+			if r.VideoID != id {
+				continue
+			}
+			if counts[id] == nil {
+				counts[id] = &entity.ReactionCount{VideoID: id}
+			}
+			if r.Kind == entity.ReactionLike {
+				counts[id].Likes++
+			} else {
+				counts[id].Dislikes++
+			}
+		}
+	}
+	return counts, nil
+}
+func (m *MockReactionRepo) Upsert(ctx context.Context, reaction *entity.Reaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.reactions {
+		if r.UserID == reaction.UserID && r.VideoID == reaction.VideoID {
+			r.Kind = reaction.Kind
+			return nil
+		}
+	}
+	m.reactions = append(m.reactions, reaction)
+	return nil
+}
+func (m *MockReactionRepo) Remove(ctx context.Context, reactions ...*entity.Reaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, reaction := range reactions {
+		for i, r := range m.reactions {
+			if r.UserID == reaction.UserID && r.VideoID == reaction.VideoID {
+				m.reactions = append(m.reactions[:i], m.reactions[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (c *ReactionController) ServeJSON() {}
+
+func newReactionController(t *testing.T, rRepo *MockReactionRepo, uid uint, videoID string, body string) *ReactionController {
+	req, err := http.NewRequest("PUT", "/videos/"+videoID+"/reaction", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vRepo := &MockVideoRepo{}
+	vRepo.Add(context.Background(), &entity.Video{YouTubeID: "dQw4w9WgXcQ"})
+
+	controller := &ReactionController{
+		RRepo:  rRepo,
+		VRepo:  vRepo,
+		NRepo:  &MockNotificationRepo{},
+		Broker: pubsubimpl.NewMemoryBroker(),
+	}
+	controller.Ctx = &beegoctx.Context{
+		Input:          beegoctx.NewInput(),
+		Output:         beegoctx.NewOutput(),
+		Request:        req,
+		ResponseWriter: &beegoctx.Response{},
+	}
+	controller.Ctx.Input.Context = controller.Ctx
+	controller.Ctx.Input.RequestBody = []byte(body)
+	controller.Ctx.Input.SetParam(":id", videoID)
+	controller.Ctx.Output.Context = controller.Ctx
+	controller.Data = make(map[interface{}]interface{})
+	controller.Ctx.Input.SetData(constant.ContextUID, uid)
+	controller.Ctx.Input.SetData(constant.ContextCtx, context.Background())
+
+	return controller
+}
+
+func TestReactionController_PutReaction(t *testing.T) {
+	rRepo := &MockReactionRepo{}
+
+	controller := newReactionController(t, rRepo, 1, "1", `{"kind":"like"}`)
+	controller.PutReaction()
+	resp := controller.Data["json"].(*ReactionResponse)
+	assert.Equal(t, status.OK, resp.Code)
+	assert.Equal(t, int64(1), resp.Likes)
+	assert.Equal(t, int64(0), resp.Dislikes)
+	assert.Equal(t, entity.ReactionLike, resp.MyReaction)
+
+	// Another user dislikes.
+	controller = newReactionController(t, rRepo, 2, "1", `{"kind":"dislike"}`)
+	controller.PutReaction()
+	resp = controller.Data["json"].(*ReactionResponse)
+	assert.Equal(t, int64(1), resp.Likes)
+	assert.Equal(t, int64(1), resp.Dislikes)
+
+	// The first user changes their mind, there is still one reaction per user.
+	controller = newReactionController(t, rRepo, 1, "1", `{"kind":"dislike"}`)
+	controller.PutReaction()
+	resp = controller.Data["json"].(*ReactionResponse)
+	assert.Equal(t, int64(0), resp.Likes)
+	assert.Equal(t, int64(2), resp.Dislikes)
+	assert.Len(t, rRepo.reactions, 2)
+}
+
+func TestReactionController_PutReactionInvalid(t *testing.T) {
+	controller := newReactionController(t, &MockReactionRepo{}, 1, "1", `{"kind":"love"}`)
+	controller.PutReaction()
+	assert.Equal(t, status.BadRequest, controller.Data["json"].(*ReactionResponse).Code)
+
+	controller = newReactionController(t, &MockReactionRepo{}, 1, "2", `{"kind":"like"}`)
+	controller.PutReaction()
+	assert.Equal(t, status.NotFound, controller.Data["json"].(*ReactionResponse).Code)
+}
+
+func TestReactionController_DeleteReaction(t *testing.T) {
+	rRepo := &MockReactionRepo{}
+	rRepo.Upsert(context.Background(), &entity.Reaction{UserID: 1, VideoID: 1, Kind: entity.ReactionLike})
+	rRepo.Upsert(context.Background(), &entity.Reaction{UserID: 2, VideoID: 1, Kind: entity.ReactionLike})
+
+	controller := newReactionController(t, rRepo, 1, "1", ``)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := controller.Broker.Subscribe(ctx, ws.BroadcastChannel)
+	require.NoError(t, err)
+
+	controller.DeleteReaction()
+
+	resp := controller.Data["json"].(*ReactionResponse)
+	assert.Equal(t, status.OK, resp.Code)
+	assert.Equal(t, int64(1), resp.Likes)
+	assert.Empty(t, resp.MyReaction)
+
+	select {
+	case payload := <-ch:
+		var msg ws.Message
+		require.NoError(t, json.Unmarshal(payload, &msg))
+		assert.Equal(t, EventReactionUpdated, msg.Type)
+		assert.Equal(t, []string{ws.TopicVideos, "video:1"}, msg.Topics)
+		assert.JSONEq(t, `{"videoId":1,"userId":1,"likes":1,"dislikes":0}`, string(msg.Payload))
+	case <-time.After(time.Second):
+		t.Fatal("reaction event not published")
+	}
+}
+
+func TestReactionController_HiddenVideo(t *testing.T) {
+	now := time.Now()
+
+	controller := newReactionController(t, &MockReactionRepo{}, 1, "1", `{"kind":"like"}`)
+	controller.VRepo.(*MockVideoRepo).videos[0].HiddenAt = &now
+	controller.PutReaction()
+	assert.Equal(t, status.NotFound, controller.Data["json"].(*ReactionResponse).Code)
+
+	controller = newReactionController(t, &MockReactionRepo{}, 1, "1", ``)
+	controller.VRepo.(*MockVideoRepo).videos[0].HiddenAt = &now
+	controller.DeleteReaction()
+	assert.Equal(t, status.NotFound, controller.Data["json"].(*ReactionResponse).Code)
+
+	// Moderators still see the hidden video.
+	controller = newReactionController(t, &MockReactionRepo{}, 1, "1", `{"kind":"like"}`)
+	controller.VRepo.(*MockVideoRepo).videos[0].HiddenAt = &now
+	controller.Ctx.Input.SetData(constant.ContextRole, entity.RoleAdmin)
+	controller.PutReaction()
+	assert.Equal(t, status.OK, controller.Data["json"].(*ReactionResponse).Code)
+}
@@ -5,19 +5,21 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
-	"github.com/beego/beego"
 	"github.com/beego/beego/validation"
-	"github.com/dgrijalva/jwt-go"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 	"gorm.io/gorm"
 
 	"funny-project-be/domain/entity"
+	"funny-project-be/domain/oauth"
 	"funny-project-be/domain/repo"
+	"funny-project-be/infra/beego/plugin/authn"
+	"funny-project-be/infra/beego/plugin/authz"
 	"funny-project-be/infra/constant"
 	"funny-project-be/infra/options"
 	"funny-project-be/infra/status"
@@ -27,18 +29,31 @@ import (
 type UserController struct {
 	BaseController
 
-	URepo repo.UserRepo
+	URepo   repo.UserRepo
+	UIRepo  repo.UserIdentityRepo
+	VRepo   repo.VideoRepo
+	FRepo   repo.FollowRepo
+	ASRepo  repo.AuthStateRepo
+	RTRepo  repo.RefreshTokenRepo
+	RevRepo repo.RevokedTokenRepo
+
+	Providers map[string]oauth.IdentityProvider
+	Keys      *authn.KeySet
 
 	Opts options.Options
 }
 
 // User info.
 type User struct {
-	ID        uint      `json:"id,omitempty"`
-	Name      string    `json:"name,omitempty"`
-	Email     string    `json:"email,omitempty"`
-	CreatedAt time.Time `json:"createdAt,omitempty"`
-	UpdatedAt time.Time `json:"updatedAt,omitempty"`
+	ID        uint       `json:"id,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	Email     string     `json:"email,omitempty"`
+	Avatar    string     `json:"avatar,omitempty"`
+	Bio       string     `json:"bio,omitempty"`
+	Role      string     `json:"role,omitempty"`
+	BannedAt  *time.Time `json:"bannedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt,omitempty"`
+	UpdatedAt time.Time  `json:"updatedAt,omitempty"`
 }
 
 // NewUserFromEntity creates User from entity.
@@ -51,39 +66,166 @@ func NewUserFromEntity(e *entity.User) *User {
 		ID:        e.ID,
 		Name:      e.Name,
 		Email:     e.Email,
+		Avatar:    e.Avatar,
+		Bio:       e.Bio,
+		Role:      e.Role,
+		BannedAt:  e.BannedAt,
 		CreatedAt: e.CreatedAt,
 		UpdatedAt: e.UpdatedAt,
 	}
 }
 
+// StartLoginRequest is a struct contains a request starting a login.
+type StartLoginRequest struct {
+	// Provider is one of google (default), github or oidc.
+	Provider    string `json:"provider"`
+	RedirectURL string `json:"redirectURL" valid:"Required"`
+}
+
+// StartLoginResponse is a struct contains the response of StartLogin.
+type StartLoginResponse struct {
+	Response
+	AuthURL             string `json:"authURL,omitempty"`
+	State               string `json:"state,omitempty"`
+	CodeChallenge       string `json:"codeChallenge,omitempty"`
+	CodeChallengeMethod string `json:"codeChallengeMethod,omitempty"`
+}
+
+// StartLogin API. It issues the state, nonce and PKCE verifier of a login and
+// returns the consent page URL of the provider. The verifier never leaves the
+// server, Login completes the login with the state.
+func (c *UserController) StartLogin() {
+	var req StartLoginRequest
+	var resp StartLoginResponse
+	resp.Code = status.OK
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		resp.Code = status.BadRequest
+		resp.SetError(err)
+		return
+	}
+
+	var validator validation.Validation
+	valid, err := validator.Valid(&req)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("StartLogin", err)
+		return
+	}
+	if !valid {
+		resp.Code = status.BadRequest
+		resp.SetValidationErrors(validator.Errors)
+		return
+	}
+
+	if req.Provider == "" {
+		req.Provider = oauth.ProviderGoogle
+	}
+	provider, ok := c.Providers[req.Provider]
+	if !ok {
+		resp.Code = status.BadRequest
+		resp.Message = fmt.Sprintf(`provider %q is not supported`, req.Provider)
+		return
+	}
+	if !c.isAllowedRedirectURL(req.RedirectURL) {
+		resp.Code = status.BadRequestInvalidRedirectURL
+		resp.Message = `redirectURL is not allowed`
+		return
+	}
+
+	state, err := newTokenID()
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		return
+	}
+	nonce, err := newTokenID()
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		return
+	}
+	authState := &entity.AuthState{
+		State:        state,
+		Provider:     req.Provider,
+		RedirectURL:  req.RedirectURL,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(c.Opts.AuthStateExpiresIn),
+	}
+
+	ctx := c.context()
+	authURL, err := provider.AuthCodeURL(ctx, state, req.RedirectURL,
+		oauth2.S256ChallengeOption(authState.CodeVerifier),
+		oauth2.SetAuthURLParam("nonce", nonce),
+	)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("StartLogin", err)
+		return
+	}
+
+	// Abandoned logins are cleaned up by the next ones.
+	if err := c.ASRepo.RemoveExpired(ctx, time.Now()); err != nil {
+		c.logError("StartLogin", err)
+	}
+	if err := c.ASRepo.Add(ctx, authState); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("StartLogin", err)
+		return
+	}
+
+	resp.AuthURL = authURL
+	resp.State = state
+	resp.CodeChallenge = oauth2.S256ChallengeFromVerifier(authState.CodeVerifier)
+	resp.CodeChallengeMethod = "S256"
+}
+
+// isAllowedRedirectURL reports whether redirectURL is whitelisted.
+func (c *UserController) isAllowedRedirectURL(redirectURL string) bool {
+	for _, allowed := range c.Opts.AuthRedirectURLs {
+		if redirectURL == strings.TrimSpace(allowed) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // LoginRequest is a struct contains a login request.
 type LoginRequest struct {
+	// Provider is one of google (default), github or oidc.
+	Provider    string `json:"provider"`
 	Code        string `json:"code" valid:"Required"`
 	RedirectURL string `json:"redirectURL" valid:"Required"`
+	// State is the state issued by StartLogin.
+	State string `json:"state" valid:"Required"`
 }
 
 // LoginResponse is a struct contains a login reponse.
 type LoginResponse struct {
 	Response
-	Token  string `json:"token,omitempty"`
-	Name   string `json:"name,omitempty"`
-	Avatar string `json:"avatar,omitempty"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Avatar       string `json:"avatar,omitempty"`
 }
 
-// GoogleUser represents for google user infomation.
-type GoogleUser struct {
-	Name    string `json:"name"`
-	Email   string `json:"email"`
-	Picture string `json:"picture"`
-}
-
-// JWTClaim is the JWT custom claims.
-type JWTClaim struct {
-	Email string `json:"email,omitempty"`
-	jwt.StandardClaims
-}
+var (
+	errEmailRequired = errors.New("identity provider did not return an email")
+	errEmailTaken    = errors.New("email belongs to another account and is not verified by the identity provider")
+)
 
-// Login API.
+// Login API. It completes a login started with StartLogin.
 func (c *UserController) Login() {
 	var req LoginRequest
 	var resp LoginResponse
@@ -106,7 +248,7 @@ func (c *UserController) Login() {
 	if err != nil {
 		resp.Code = status.InternalServerError
 		resp.SetError(err)
-		beego.Error("Login ", err)
+		c.logError("Login", err)
 		return
 	}
 	if !valid {
@@ -115,99 +257,376 @@ func (c *UserController) Login() {
 		return
 	}
 
-	conf := &oauth2.Config{
-		ClientID:     c.Opts.GAuthClientID,
-		ClientSecret: c.Opts.GAuthClientSecret,
-		RedirectURL:  req.RedirectURL,
-		Endpoint:     google.Endpoint,
+	if req.Provider == "" {
+		req.Provider = oauth.ProviderGoogle
+	}
+	provider, ok := c.Providers[req.Provider]
+	if !ok {
+		resp.Code = status.BadRequest
+		resp.Message = fmt.Sprintf(`provider %q is not supported`, req.Provider)
+		return
 	}
 
-	// exchange auth_code to token including refresh_token
-	token, err := conf.Exchange(context.Background(), req.Code)
+	ctx := c.context()
+	authState, err := c.ASRepo.Take(ctx, req.State)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			resp.Code = status.Unauthorized
+			resp.Message = `state is invalid`
+			return
+		}
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("Login", err)
+		return
+	}
+	if authState.ExpiresAt.Before(time.Now()) {
 		resp.Code = status.Unauthorized
-		resp.Message = fmt.Sprintf(`exchange google token failed %s`, err.Error())
+		resp.Message = `state is expired`
 		return
 	}
-
-	client := conf.Client(context.Background(), token)
-	response, err := client.Get(c.Opts.GAuthProfileURL)
-	if err != nil {
+	if authState.Provider != req.Provider || authState.RedirectURL != req.RedirectURL {
 		resp.Code = status.Unauthorized
-		resp.Message = fmt.Sprintf(`get google user failed %s`, err.Error())
+		resp.Message = `state was issued for another provider or redirectURL`
+		return
+	}
+	if !c.isAllowedRedirectURL(req.RedirectURL) {
+		resp.Code = status.BadRequestInvalidRedirectURL
+		resp.Message = `redirectURL is not allowed`
 		return
 	}
-	defer response.Body.Close()
 
-	contents, err := io.ReadAll(response.Body)
+	token, err := provider.Exchange(ctx, req.Code, req.RedirectURL, oauth2.VerifierOption(authState.CodeVerifier))
 	if err != nil {
-		resp.Message = fmt.Sprintf(`get google user failed %s`, err.Error())
 		resp.Code = status.Unauthorized
+		resp.Message = fmt.Sprintf(`exchange %s token failed %s`, req.Provider, err.Error())
 		return
 	}
 
-	gUser := GoogleUser{}
-	if err = json.Unmarshal(contents, &gUser); err != nil {
-		resp.Message = fmt.Sprintf(`parse google user failed %s`, err.Error())
+	profile, err := provider.Profile(ctx, token, authState.Nonce)
+	if err != nil {
+		if errors.Is(err, oauth.ErrEmailNotVerified) {
+			resp.Code = status.Forbidden
+			resp.SetError(err)
+			return
+		}
 		resp.Code = status.Unauthorized
+		resp.Message = fmt.Sprintf(`get %s user failed %s`, req.Provider, err.Error())
 		return
 	}
 
-	ctx := context.Background()
-	user, err := c.URepo.GetOneByEmail(ctx, gUser.Email)
+	user, err := c.loginUser(ctx, req.Provider, profile)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			user = &entity.User{
-				Email: gUser.Email,
-				Name:  gUser.Name,
-			}
-			if err := c.URepo.Add(ctx, user); err != nil {
-				resp.Code = status.InternalServerError
-				resp.SetError(err)
-				beego.Error("Login ", err)
-				return
-			}
-			user, err = c.URepo.GetOneByEmail(ctx, gUser.Email)
-			if err != nil {
-				resp.Code = status.InternalServerError
-				resp.SetError(err)
-				beego.Error("Login ", err)
-				return
-			}
-		} else {
+		switch {
+		case errors.Is(err, errEmailRequired):
+			resp.Code = status.Unauthorized
+			resp.SetError(err)
+		case errors.Is(err, errEmailTaken):
+			resp.Code = status.Conflict
+			resp.SetError(err)
+		default:
+			resp.Code = status.InternalServerError
+			resp.SetError(err)
+			c.logError("Login", err)
+		}
+		return
+	}
+	if user.BannedAt != nil {
+		resp.Code = status.ForbiddenUserBanned
+		resp.Message = `user is banned`
+		return
+	}
+	// The picture of the provider is only a default, users may change their avatar.
+	promote := profile.EmailVerified && c.isAdminEmail(user.Email) && user.Role != entity.RoleAdmin
+	if promote || (profile.Picture != "" && user.Avatar == "") {
+		if promote {
+			user.Role = entity.RoleAdmin
+		}
+		if user.Avatar == "" {
+			user.Avatar = profile.Picture
+		}
+		if err := c.URepo.Update(ctx, user); err != nil {
 			resp.Code = status.InternalServerError
 			resp.SetError(err)
-			beego.Error("Login ", err)
+			c.logError("Login", err)
 			return
 		}
 	}
 
-	expiredAt := time.Now().Unix() + int64(c.Opts.AccessTokenExpiresIn.Seconds())
-	claims := JWTClaim{
-		StandardClaims: jwt.StandardClaims{
-			IssuedAt:  time.Now().Unix(),
-			ExpiresAt: expiredAt,
-			Subject:   strconv.Itoa(int(user.ID)),
-		},
+	signedStr, err := c.issueAccessToken(user)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		return
 	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
 
-	signedStr, err := accessToken.SignedString([]byte(c.Opts.AccessTokenSecret))
+	familyID, err := newTokenID()
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		return
+	}
+	refreshToken, err := c.issueRefreshToken(ctx, user, familyID)
 	if err != nil {
 		resp.Code = status.InternalServerError
 		resp.SetError(err)
+		c.logError("Login", err)
 		return
 	}
 
 	resp.Token = signedStr
-	resp.Name = gUser.Name
-	resp.Avatar = gUser.Picture
+	resp.RefreshToken = refreshToken
+	resp.Name = user.Name
+	resp.Avatar = user.Avatar
+}
+
+// loginUser returns the user linked to the identity of profile at provider.
+// An identity seen for the first time is linked to the user with the same
+// verified email, or to a new user.
+func (c *UserController) loginUser(ctx context.Context, provider string, profile *oauth.Profile) (*entity.User, error) {
+	identity, err := c.UIRepo.GetOneBySubject(ctx, provider, profile.Subject)
+	if err == nil {
+		return c.URepo.Get(ctx, identity.UserID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if profile.Email == "" {
+		return nil, errEmailRequired
+	}
+
+	user, err := c.URepo.GetOneByEmail(ctx, profile.Email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if user != nil && !profile.EmailVerified {
+		// Only the owner of the email may take over its account.
+		return nil, errEmailTaken
+	}
+	if user == nil {
+		user = &entity.User{
+			Email:  profile.Email,
+			Name:   profile.Name,
+			Role:   entity.RoleUser,
+			Avatar: profile.Picture,
+		}
+		if err := c.URepo.Add(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.UIRepo.Add(ctx, &entity.UserIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  profile.Subject,
+		Email:    profile.Email,
+	}); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// isAdminEmail reports whether email is listed in AdminEmails.
+func (c *UserController) isAdminEmail(email string) bool {
+	for _, admin := range c.Opts.AdminEmails {
+		if strings.EqualFold(email, strings.TrimSpace(admin)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RefreshRequest is a struct contains a refresh request.
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" valid:"Required"`
+}
+
+// RefreshResponse is a struct contains a refresh response.
+type RefreshResponse struct {
+	Response
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+}
+
+// Refresh API. It exchanges a refresh token for a new access token and a new
+// refresh token. A refresh token can only be used once: using it again revokes
+// every token descending from the same login.
+func (c *UserController) Refresh() {
+	var req RefreshRequest
+	var resp RefreshResponse
+	resp.Code = status.OK
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		resp.Code = status.BadRequest
+		resp.SetError(err)
+		return
+	}
+
+	var validator validation.Validation
+	valid, err := validator.Valid(&req)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("Refresh", err)
+		return
+	}
+	if !valid {
+		resp.Code = status.BadRequest
+		resp.SetValidationErrors(validator.Errors)
+		return
+	}
+
+	ctx := c.context()
+	token, err := c.RTRepo.GetOneByHash(ctx, hashRefreshToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			resp.Code = status.Unauthorized
+			resp.Message = `refresh token is invalid`
+			return
+		}
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("Refresh", err)
+		return
+	}
+
+	if token.RevokedAt == nil && token.ExpiresAt.Before(time.Now()) {
+		resp.Code = status.Unauthorized
+		resp.Message = `refresh token is expired`
+		return
+	}
+
+	// Revoke fails when the token was revoked already, including by a concurrent refresh.
+	revoked := false
+	if token.RevokedAt == nil {
+		if revoked, err = c.RTRepo.Revoke(ctx, token.ID); err != nil {
+			resp.Code = status.InternalServerError
+			resp.SetError(err)
+			c.logError("Refresh", err)
+			return
+		}
+	}
+	if !revoked {
+		if err := c.RTRepo.RevokeFamily(ctx, token.FamilyID); err != nil {
+			c.logError("Refresh", err)
+		}
+		resp.Code = status.Unauthorized
+		resp.Message = `refresh token has already been used`
+		return
+	}
+
+	user, err := c.URepo.Get(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			resp.Code = status.Unauthorized
+			resp.Message = `user not found`
+			return
+		}
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("Refresh", err)
+		return
+	}
+	if user.BannedAt != nil {
+		resp.Code = status.ForbiddenUserBanned
+		resp.Message = `user is banned`
+		return
+	}
+
+	if resp.Token, err = c.issueAccessToken(user); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		return
+	}
+	if resp.RefreshToken, err = c.issueRefreshToken(ctx, user, token.FamilyID); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("Refresh", err)
+		return
+	}
+}
+
+// LogoutRequest is a struct contains a logout request.
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// Logout API. It revokes the access token of the request and, when given,
+// the refresh token and every token descending from the same login.
+func (c *UserController) Logout() {
+	var req LogoutRequest
+	var resp Response
+	resp.Code = status.OK
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	if len(c.Ctx.Input.RequestBody) > 0 {
+		if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+			resp.Code = status.BadRequest
+			resp.SetError(err)
+			return
+		}
+	}
+
+	uid := c.Ctx.Input.GetData(constant.ContextUID).(uint)
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+
+	if jti, ok := c.Ctx.Input.GetData(constant.ContextJTI).(string); ok && jti != "" {
+		// The access token expires at the latest AccessTokenExpiresIn from now.
+		if err := c.RevRepo.Add(ctx, &entity.RevokedToken{
+			JTI:       jti,
+			ExpiresAt: time.Now().Add(c.Opts.AccessTokenExpiresIn),
+		}); err != nil {
+			resp.Code = status.InternalServerError
+			resp.SetError(err)
+			c.logError("Logout", err)
+			return
+		}
+	}
+
+	if req.RefreshToken == "" {
+		return
+	}
+	token, err := c.RTRepo.GetOneByHash(ctx, hashRefreshToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return
+		}
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("Logout", err)
+		return
+	}
+	if token.UserID != uid {
+		return
+	}
+	if err := c.RTRepo.RevokeFamily(ctx, token.FamilyID); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("Logout", err)
+		return
+	}
 }
 
 // GetUserResponse is a response of GetUser API.
 type GetUserResponse struct {
 	Response
 	Email string `json:"email,omitempty"`
+	Role  string `json:"role,omitempty"`
+	User  *User  `json:"user,omitempty"`
 }
 
 // GetUser API.
@@ -232,8 +651,321 @@ func (c *UserController) GetUser() {
 		}
 		resp.Code = status.InternalServerError
 		resp.SetError(err)
-		beego.Error("GetUser ", err)
+		c.logError("GetUser", err)
+		return
+	}
+	resp.Email = user.Email
+	resp.Role = user.Role
+	resp.User = NewUserFromEntity(user)
+}
+
+// UpdateUserRequest is a struct contains a request updating the profile of
+// the user. Omitted fields are left unchanged, an empty avatar or bio clears it.
+type UpdateUserRequest struct {
+	Name   *string `json:"name"`
+	Avatar *string `json:"avatar"`
+	Bio    *string `json:"bio"`
+}
+
+// userProfile is the profile validated by UpdateUser.
+type userProfile struct {
+	// Name is only validated when the request sets it, since the users of the
+	// providers which return no name have none.
+	Name   *string
+	Avatar string `valid:"MaxSize(255)"`
+	Bio    string `valid:"MaxSize(500)"`
+}
+
+// Valid checks the name of the profile when it is set.
+func (p *userProfile) Valid(v *validation.Validation) {
+	if p.Name == nil {
+		return
+	}
+	if *p.Name == "" {
+		v.SetError("Name", "Can not be empty")
+	}
+	if utf8.RuneCountInString(*p.Name) > 100 {
+		v.SetError("Name", "Maximum size is 100")
+	}
+}
+
+// UpdateUser API.
+func (c *UserController) UpdateUser() {
+	var req UpdateUserRequest
+	var resp GetUserResponse
+	resp.Code = status.OK
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		resp.Code = status.BadRequest
+		resp.SetError(err)
+		return
+	}
+
+	uid := c.Ctx.Input.GetData(constant.ContextUID).(uint)
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+	user, err := c.URepo.Get(ctx, uid)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			resp.Code = status.NotFound
+			resp.Message = `user not found`
+			return
+		}
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("UpdateUser", err)
 		return
 	}
+
+	profile := userProfile{Avatar: user.Avatar, Bio: user.Bio}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		profile.Name = &name
+	}
+	if req.Avatar != nil {
+		profile.Avatar = strings.TrimSpace(*req.Avatar)
+	}
+	if req.Bio != nil {
+		profile.Bio = strings.TrimSpace(*req.Bio)
+	}
+
+	var validator validation.Validation
+	valid, err := validator.Valid(&profile)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("UpdateUser", err)
+		return
+	}
+	if !valid {
+		resp.Code = status.BadRequest
+		resp.SetValidationErrors(validator.Errors)
+		return
+	}
+	if profile.Avatar != "" && !isHTTPURL(profile.Avatar) {
+		resp.Code = status.BadRequest
+		resp.Message = `avatar must be an http or https url`
+		return
+	}
+
+	if profile.Name != nil {
+		user.Name = *profile.Name
+	}
+	user.Avatar = profile.Avatar
+	user.Bio = profile.Bio
+	if err := c.URepo.Update(ctx, user); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("UpdateUser", err)
+		return
+	}
+
 	resp.Email = user.Email
+	resp.Role = user.Role
+	resp.User = NewUserFromEntity(user)
+}
+
+// isHTTPURL reports whether s is an absolute http or https url.
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// recentSharesLimit is the number of videos of a Profile.
+const recentSharesLimit = 5
+
+// Profile is the public profile of a user.
+type Profile struct {
+	Author
+	Bio            string `json:"bio,omitempty"`
+	ShareCount     int64  `json:"shareCount"`
+	FollowerCount  int64  `json:"followerCount"`
+	FollowingCount int64  `json:"followingCount"`
+	// Following tells whether the user of the request follows this user.
+	Following    bool      `json:"following"`
+	RecentShares []*Video  `json:"recentShares"`
+	CreatedAt    time.Time `json:"createdAt,omitempty"`
+}
+
+// GetProfileResponse is a response of GetProfile API.
+type GetProfileResponse struct {
+	Response
+	Profile *Profile `json:"profile,omitempty"`
+}
+
+// GetProfile API. It returns the public profile of any user, with their
+// newest shares. Banned users have no profile but for moderators.
+func (c *UserController) GetProfile() {
+	var resp GetProfileResponse
+	resp.Code = status.OK
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	user := c.getProfileUser(&resp.Response)
+	if user == nil {
+		return
+	}
+
+	uid := c.Ctx.Input.GetData(constant.ContextUID).(uint)
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+	filter := repo.VideoFilter{UserID: user.ID}
+	count, err := c.VRepo.Count(ctx, filter)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("GetProfile", err)
+		return
+	}
+	videos, err := c.VRepo.GetRangeByCursor(ctx, filter, time.Time{}, 0, recentSharesLimit)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("GetProfile", err)
+		return
+	}
+	followers, err := c.FRepo.CountFollowers(ctx, user.ID)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("GetProfile", err)
+		return
+	}
+	following, err := c.FRepo.CountFollowing(ctx, user.ID)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("GetProfile", err)
+		return
+	}
+	isFollowing := true
+	if _, err := c.FRepo.Get(ctx, uid, user.ID); err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			resp.Code = status.InternalServerError
+			resp.SetError(err)
+			c.logError("GetProfile", err)
+			return
+		}
+		isFollowing = false
+	}
+
+	resp.Profile = &Profile{
+		Author:         *NewAuthorFromEntity(user),
+		Bio:            user.Bio,
+		ShareCount:     count,
+		FollowerCount:  followers,
+		FollowingCount: following,
+		Following:      isFollowing,
+		RecentShares:   make([]*Video, 0, len(videos)),
+		CreatedAt:      user.CreatedAt,
+	}
+	for _, video := range videos {
+		resp.Profile.RecentShares = append(resp.Profile.RecentShares, NewVideoFromEntity(video))
+	}
+}
+
+// FollowResponse is a response of Follow and Unfollow APIs.
+type FollowResponse struct {
+	Response
+	Following     bool  `json:"following"`
+	FollowerCount int64 `json:"followerCount"`
+}
+
+// Follow API. The user is notified of the videos the followed user shares,
+// which also make up the feed. Following a user twice is not an error.
+func (c *UserController) Follow() {
+	c.setFollowing("Follow", true)
+}
+
+// Unfollow API.
+func (c *UserController) Unfollow() {
+	c.setFollowing("Unfollow", false)
+}
+
+// setFollowing follows or unfollows the user of the route.
+func (c *UserController) setFollowing(name string, following bool) {
+	var resp FollowResponse
+	resp.Code = status.OK
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	user := c.getProfileUser(&resp.Response)
+	if user == nil {
+		return
+	}
+
+	uid := c.Ctx.Input.GetData(constant.ContextUID).(uint)
+	if user.ID == uid {
+		resp.Code = status.BadRequest
+		resp.Message = `you can not follow yourself`
+		return
+	}
+
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+	follow := &entity.Follow{FollowerID: uid, FolloweeID: user.ID}
+	var err error
+	if following {
+		err = c.FRepo.Add(ctx, follow)
+	} else {
+		err = c.FRepo.Remove(ctx, follow)
+	}
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError(name, err)
+		return
+	}
+
+	count, err := c.FRepo.CountFollowers(ctx, user.ID)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError(name, err)
+		return
+	}
+	resp.Following = following
+	resp.FollowerCount = count
+}
+
+// getProfileUser finds the user of the route. Banned users are only found by moderators.
+// It returns nil after setting the error to resp otherwise.
+func (c *UserController) getProfileUser(resp *Response) *entity.User {
+	id, err := strconv.ParseUint(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		resp.Code = status.BadRequest
+		resp.Message = "id is invalid"
+		return nil
+	}
+
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+	user, err := c.URepo.Get(ctx, uint(id))
+	if err == nil && user.BannedAt != nil && !authz.Can(c.Ctx, authz.UserModerate) {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			resp.Code = status.NotFound
+			resp.Message = `user not found`
+			return nil
+		}
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("getProfileUser", err)
+		return nil
+	}
+
+	return user
 }
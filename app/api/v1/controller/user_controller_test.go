@@ -2,16 +2,30 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	beegoctx "github.com/beego/beego/context"
+	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
 
 	"funny-project-be/domain/entity"
+	"funny-project-be/domain/oauth"
+	"funny-project-be/infra/beego/plugin/authn"
 	"funny-project-be/infra/constant"
+	"funny-project-be/infra/options"
+	"funny-project-be/infra/status"
 )
 
 type MockUserRepo struct{}
@@ -20,12 +34,20 @@ func (m *MockUserRepo) Get(ctx context.Context, uid uint) (*entity.User, error)
 	fmt.Println("Mock Get called")
 	// Mock implementation
 	return &entity.User{
+		ID:    uid,
+		Name:  "Test",
 		Email: "test@example.com",
 	}, nil
 }
 func (m *MockUserRepo) GetOneByEmail(ctx context.Context, email string) (*entity.User, error) {
 	return nil, nil
 }
+func (m *MockUserRepo) GetRange(ctx context.Context, limit int, page int) ([]*entity.User, error) {
+	return nil, nil
+}
+func (m *MockUserRepo) Count(ctx context.Context) (int64, error) {
+	return 0, nil
+}
 func (m *MockUserRepo) Add(ctx context.Context, users ...*entity.User) error {
 	return nil
 }
@@ -36,8 +58,217 @@ func (m *MockUserRepo) Update(ctx context.Context, users ...*entity.User) error
 	return nil
 }
 
+type MockRefreshTokenRepo struct {
+	mu     sync.Mutex
+	tokens []*entity.RefreshToken
+}
+
+func (m *MockRefreshTokenRepo) GetOneByHash(ctx context.Context, hash string) (*entity.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tokens {
+		if t.TokenHash == hash {
+			return t, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+func (m *MockRefreshTokenRepo) Add(ctx context.Context, tokens ...*entity.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range tokens {
+		t.ID = uint(len(m.tokens) + 1)
+		m.tokens = append(m.tokens, t)
+	}
+	return nil
+}
+func (m *MockRefreshTokenRepo) Revoke(ctx context.Context, id uint) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tokens {
+		if t.ID == id && t.RevokedAt == nil {
+			now := time.Now()
+			t.RevokedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (m *MockRefreshTokenRepo) RevokeFamily(ctx context.Context, familyID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			now := time.Now()
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+func (m *MockRefreshTokenRepo) RevokeByUser(ctx context.Context, userID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			now := time.Now()
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+type MockRevokedTokenRepo struct {
+	jtis map[string]bool
+}
+
+func (m *MockRevokedTokenRepo) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return m.jtis[jti], nil
+}
+func (m *MockRevokedTokenRepo) Add(ctx context.Context, tokens ...*entity.RevokedToken) error {
+	if m.jtis == nil {
+		m.jtis = map[string]bool{}
+	}
+	for _, t := range tokens {
+		m.jtis[t.JTI] = true
+	}
+	return nil
+}
+
 func (c *UserController) ServeJSON() {}
 
+func newUserController(t *testing.T, rtRepo *MockRefreshTokenRepo, revRepo *MockRevokedTokenRepo, body string) *UserController {
+	req, err := http.NewRequest("POST", "/auth", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := options.Options{
+		AccessTokenSecret:     "secret",
+		AccessTokenExpiresIn:  time.Hour,
+		RefreshTokenExpiresIn: 24 * time.Hour,
+		AuthRedirectURLs:      []string{"http://localhost"},
+		AuthStateExpiresIn:    time.Minute,
+	}
+	keys, err := authn.NewKeySet(opts)
+	require.NoError(t, err)
+
+	controller := &UserController{
+		URepo:   &MockUserRepo{},
+		FRepo:   &MockFollowRepo{},
+		RTRepo:  rtRepo,
+		RevRepo: revRepo,
+		Keys:    keys,
+		Opts:    opts,
+	}
+	controller.Ctx = &beegoctx.Context{
+		Input:          beegoctx.NewInput(),
+		Output:         beegoctx.NewOutput(),
+		Request:        req,
+		ResponseWriter: &beegoctx.Response{},
+	}
+	controller.Ctx.Input.Context = controller.Ctx
+	controller.Ctx.Input.RequestBody = []byte(body)
+	controller.Ctx.Output.Context = controller.Ctx
+	controller.Data = make(map[interface{}]interface{})
+	controller.Ctx.Input.SetData(constant.ContextUID, uint(1))
+	controller.Ctx.Input.SetData(constant.ContextCtx, context.Background())
+
+	return controller
+}
+
+// addRefreshToken issues a refresh token of a new family for user 1.
+func addRefreshToken(t *testing.T, controller *UserController) string {
+	familyID, err := newTokenID()
+	require.NoError(t, err)
+	token, err := controller.issueRefreshToken(context.Background(), &entity.User{ID: 1}, familyID)
+	require.NoError(t, err)
+
+	return token
+}
+
+func TestUserController_RefreshRotates(t *testing.T) {
+	rtRepo := &MockRefreshTokenRepo{}
+	controller := newUserController(t, rtRepo, &MockRevokedTokenRepo{}, ``)
+	first := addRefreshToken(t, controller)
+
+	controller = newUserController(t, rtRepo, &MockRevokedTokenRepo{}, fmt.Sprintf(`{"refreshToken":%q}`, first))
+	controller.Refresh()
+	resp := controller.Data["json"].(*RefreshResponse)
+	require.Equal(t, status.OK, resp.Code)
+	assert.NotEmpty(t, resp.Token)
+	assert.NotEmpty(t, resp.RefreshToken)
+	assert.NotEqual(t, first, resp.RefreshToken)
+
+	// The new token stays in the family of the first one.
+	require.Len(t, rtRepo.tokens, 2)
+	assert.Equal(t, rtRepo.tokens[0].FamilyID, rtRepo.tokens[1].FamilyID)
+	assert.NotNil(t, rtRepo.tokens[0].RevokedAt)
+	assert.Nil(t, rtRepo.tokens[1].RevokedAt)
+
+	// Unknown tokens are rejected.
+	controller = newUserController(t, rtRepo, &MockRevokedTokenRepo{}, `{"refreshToken":"unknown"}`)
+	controller.Refresh()
+	assert.Equal(t, status.Unauthorized, controller.Data["json"].(*RefreshResponse).Code)
+
+	// Expired tokens are rejected.
+	expired := addRefreshToken(t, controller)
+	rtRepo.tokens[2].ExpiresAt = time.Now().Add(-time.Minute)
+	controller = newUserController(t, rtRepo, &MockRevokedTokenRepo{}, fmt.Sprintf(`{"refreshToken":%q}`, expired))
+	controller.Refresh()
+	assert.Equal(t, status.Unauthorized, controller.Data["json"].(*RefreshResponse).Code)
+}
+
+func TestUserController_RefreshReuseRevokesFamily(t *testing.T) {
+	rtRepo := &MockRefreshTokenRepo{}
+	controller := newUserController(t, rtRepo, &MockRevokedTokenRepo{}, ``)
+	first := addRefreshToken(t, controller)
+	other := addRefreshToken(t, controller)
+
+	controller = newUserController(t, rtRepo, &MockRevokedTokenRepo{}, fmt.Sprintf(`{"refreshToken":%q}`, first))
+	controller.Refresh()
+	second := controller.Data["json"].(*RefreshResponse).RefreshToken
+	require.NotEmpty(t, second)
+
+	// Replaying the first token revokes the whole family, including the second token.
+	controller = newUserController(t, rtRepo, &MockRevokedTokenRepo{}, fmt.Sprintf(`{"refreshToken":%q}`, first))
+	controller.Refresh()
+	assert.Equal(t, status.Unauthorized, controller.Data["json"].(*RefreshResponse).Code)
+
+	controller = newUserController(t, rtRepo, &MockRevokedTokenRepo{}, fmt.Sprintf(`{"refreshToken":%q}`, second))
+	controller.Refresh()
+	assert.Equal(t, status.Unauthorized, controller.Data["json"].(*RefreshResponse).Code)
+
+	// Other logins are untouched.
+	controller = newUserController(t, rtRepo, &MockRevokedTokenRepo{}, fmt.Sprintf(`{"refreshToken":%q}`, other))
+	controller.Refresh()
+	assert.Equal(t, status.OK, controller.Data["json"].(*RefreshResponse).Code)
+}
+
+func TestUserController_Logout(t *testing.T) {
+	rtRepo := &MockRefreshTokenRepo{}
+	revRepo := &MockRevokedTokenRepo{}
+	controller := newUserController(t, rtRepo, revRepo, ``)
+	token := addRefreshToken(t, controller)
+
+	controller = newUserController(t, rtRepo, revRepo, fmt.Sprintf(`{"refreshToken":%q}`, token))
+	controller.Ctx.Input.SetData(constant.ContextJTI, "access-jti")
+	controller.Logout()
+	assert.Equal(t, status.OK, controller.Data["json"].(*Response).Code)
+
+	revoked, _ := revRepo.IsRevoked(context.Background(), "access-jti")
+	assert.True(t, revoked)
+	assert.NotNil(t, rtRepo.tokens[0].RevokedAt)
+
+	// Refresh tokens of other users are not revoked.
+	controller = newUserController(t, rtRepo, revRepo, ``)
+	token = addRefreshToken(t, controller)
+	rtRepo.tokens[1].UserID = 2
+	controller = newUserController(t, rtRepo, revRepo, fmt.Sprintf(`{"refreshToken":%q}`, token))
+	controller.Logout()
+	assert.Equal(t, status.OK, controller.Data["json"].(*Response).Code)
+	assert.Nil(t, rtRepo.tokens[1].RevokedAt)
+}
+
 func TestUserController_GetUser(t *testing.T) {
 	// Create a request to pass to our handler.
 	req, err := http.NewRequest("GET", "/user", nil)
@@ -77,3 +308,458 @@ func TestUserController_GetUser(t *testing.T) {
 	expected := "test@example.com"
 	assert.Equal(t, expected, controller.Data["json"].(*GetUserResponse).Email)
 }
+
+// memUserRepo stores users in memory.
+type memUserRepo struct {
+	MockUserRepo
+	users []*entity.User
+}
+
+func (m *memUserRepo) Get(ctx context.Context, id uint) (*entity.User, error) {
+	for _, u := range m.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+func (m *memUserRepo) GetOneByEmail(ctx context.Context, email string) (*entity.User, error) {
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+func (m *memUserRepo) GetRange(ctx context.Context, limit int, page int) ([]*entity.User, error) {
+	start := limit * (page - 1)
+	if start > len(m.users) {
+		start = len(m.users)
+	}
+	end := start + limit
+	if end > len(m.users) {
+		end = len(m.users)
+	}
+	return m.users[start:end], nil
+}
+func (m *memUserRepo) Count(ctx context.Context) (int64, error) {
+	return int64(len(m.users)), nil
+}
+func (m *memUserRepo) Add(ctx context.Context, users ...*entity.User) error {
+	for _, u := range users {
+		u.ID = uint(len(m.users) + 1)
+		m.users = append(m.users, u)
+	}
+	return nil
+}
+
+type MockUserIdentityRepo struct {
+	identities []*entity.UserIdentity
+}
+
+func (m *MockUserIdentityRepo) GetOneBySubject(ctx context.Context, provider string, subject string) (*entity.UserIdentity, error) {
+	for _, i := range m.identities {
+		if i.Provider == provider && i.Subject == subject {
+			return i, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+func (m *MockUserIdentityRepo) Add(ctx context.Context, identities ...*entity.UserIdentity) error {
+	m.identities = append(m.identities, identities...)
+	return nil
+}
+
+// MockIdentityProvider accepts the code "code" and returns its profile to the
+// nonce of its last consent URL.
+type MockIdentityProvider struct {
+	profile oauth.Profile
+	err     error
+	nonce   string
+}
+
+func (m *MockIdentityProvider) AuthCodeURL(ctx context.Context, state string, redirectURL string, opts ...oauth2.AuthCodeOption) (string, error) {
+	conf := oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://idp.example.com/auth"}, RedirectURL: redirectURL}
+	rawURL := conf.AuthCodeURL(state, opts...)
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	m.nonce = u.Query().Get("nonce")
+	return rawURL, nil
+}
+func (m *MockIdentityProvider) Exchange(ctx context.Context, code string, redirectURL string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	if code != "code" {
+		return nil, errors.New("invalid_grant")
+	}
+	return &oauth2.Token{AccessToken: "token"}, nil
+}
+func (m *MockIdentityProvider) Profile(ctx context.Context, token *oauth2.Token, nonce string) (*oauth.Profile, error) {
+	if nonce != m.nonce {
+		return nil, oauth.ErrNonceMismatch
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	p := m.profile
+	return &p, nil
+}
+
+type MockAuthStateRepo struct {
+	states map[string]*entity.AuthState
+}
+
+func (m *MockAuthStateRepo) Add(ctx context.Context, states ...*entity.AuthState) error {
+	if m.states == nil {
+		m.states = map[string]*entity.AuthState{}
+	}
+	for _, s := range states {
+		m.states[s.State] = s
+	}
+	return nil
+}
+func (m *MockAuthStateRepo) Take(ctx context.Context, state string) (*entity.AuthState, error) {
+	s, ok := m.states[state]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	delete(m.states, state)
+	return s, nil
+}
+func (m *MockAuthStateRepo) RemoveExpired(ctx context.Context, t time.Time) error {
+	for k, s := range m.states {
+		if s.ExpiresAt.Before(t) {
+			delete(m.states, k)
+		}
+	}
+	return nil
+}
+
+// authFlow runs the StartLogin and Login apis against the same repos.
+type authFlow struct {
+	t         *testing.T
+	uRepo     *memUserRepo
+	uiRepo    *MockUserIdentityRepo
+	asRepo    *MockAuthStateRepo
+	providers map[string]oauth.IdentityProvider
+}
+
+func newAuthFlow(t *testing.T) *authFlow {
+	return &authFlow{
+		t:      t,
+		uRepo:  &memUserRepo{},
+		uiRepo: &MockUserIdentityRepo{},
+		asRepo: &MockAuthStateRepo{},
+		providers: map[string]oauth.IdentityProvider{
+			oauth.ProviderGoogle: &MockIdentityProvider{profile: oauth.Profile{Subject: "g1", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}},
+			oauth.ProviderGitHub: &MockIdentityProvider{profile: oauth.Profile{Subject: "gh1", Email: "alice@example.com", EmailVerified: true, Name: "alice"}},
+			oauth.ProviderOIDC:   &MockIdentityProvider{profile: oauth.Profile{Subject: "o1", Email: "alice@example.com", Name: "Mallory"}},
+		},
+	}
+}
+
+func (f *authFlow) controller(body string) *UserController {
+	controller := newUserController(f.t, &MockRefreshTokenRepo{}, &MockRevokedTokenRepo{}, body)
+	controller.URepo = f.uRepo
+	controller.UIRepo = f.uiRepo
+	controller.ASRepo = f.asRepo
+	controller.Providers = f.providers
+	return controller
+}
+
+func (f *authFlow) start(provider string, redirectURL string) *StartLoginResponse {
+	controller := f.controller(fmt.Sprintf(`{"provider":%q,"redirectURL":%q}`, provider, redirectURL))
+	controller.StartLogin()
+	return controller.Data["json"].(*StartLoginResponse)
+}
+
+func (f *authFlow) login(provider string, code string, redirectURL string, state string) *LoginResponse {
+	controller := f.controller(fmt.Sprintf(`{"provider":%q,"code":%q,"redirectURL":%q,"state":%q}`, provider, code, redirectURL, state))
+	controller.Login()
+	return controller.Data["json"].(*LoginResponse)
+}
+
+// startAndLogin runs a whole login with provider.
+func (f *authFlow) startAndLogin(provider string, code string) *LoginResponse {
+	start := f.start(provider, "http://localhost")
+	require.Equal(f.t, status.OK, start.Code)
+	return f.login(provider, code, "http://localhost", start.State)
+}
+
+func TestUserController_LoginProviders(t *testing.T) {
+	f := newAuthFlow(t)
+
+	// Google is the default provider and registers the user.
+	resp := f.startAndLogin("", "code")
+	require.Equal(t, status.OK, resp.Code)
+	assert.NotEmpty(t, resp.Token)
+	assert.Equal(t, "Alice", resp.Name)
+	require.Len(t, f.uRepo.users, 1)
+	require.Len(t, f.uiRepo.identities, 1)
+
+	// GitHub with the same verified email is linked to the same user.
+	resp = f.startAndLogin("github", "code")
+	require.Equal(t, status.OK, resp.Code)
+	assert.Len(t, f.uRepo.users, 1)
+	require.Len(t, f.uiRepo.identities, 2)
+	assert.Equal(t, f.uRepo.users[0].ID, f.uiRepo.identities[1].UserID)
+
+	// Logging in again reuses the identity.
+	resp = f.startAndLogin("github", "code")
+	require.Equal(t, status.OK, resp.Code)
+	assert.Len(t, f.uiRepo.identities, 2)
+
+	// An unverified email can not take over an account.
+	resp = f.startAndLogin("oidc", "code")
+	assert.Equal(t, status.Conflict, resp.Code)
+	assert.Len(t, f.uiRepo.identities, 2)
+
+	resp = f.startAndLogin("github", "wrong")
+	assert.Equal(t, status.Unauthorized, resp.Code)
+
+	start := f.start("facebook", "http://localhost")
+	assert.Equal(t, status.BadRequest, start.Code)
+
+	// Providers reject users whose email they have not verified.
+	f.providers[oauth.ProviderGoogle].(*MockIdentityProvider).err = oauth.ErrEmailNotVerified
+	resp = f.startAndLogin("google", "code")
+	assert.Equal(t, status.Forbidden, resp.Code)
+}
+
+func TestUserController_StartLogin(t *testing.T) {
+	f := newAuthFlow(t)
+
+	start := f.start("github", "http://localhost")
+	require.Equal(t, status.OK, start.Code)
+	assert.Equal(t, "S256", start.CodeChallengeMethod)
+	authURL, err := url.Parse(start.AuthURL)
+	require.NoError(t, err)
+	assert.Equal(t, start.State, authURL.Query().Get("state"))
+	assert.Equal(t, start.CodeChallenge, authURL.Query().Get("code_challenge"))
+	assert.NotEmpty(t, authURL.Query().Get("nonce"))
+
+	// The verifier is kept on the server.
+	stored := f.asRepo.states[start.State]
+	require.NotNil(t, stored)
+	assert.Equal(t, oauth2.S256ChallengeFromVerifier(stored.CodeVerifier), start.CodeChallenge)
+
+	start = f.start("github", "https://evil.example.com")
+	assert.Equal(t, status.BadRequestInvalidRedirectURL, start.Code)
+}
+
+func TestUserController_LoginRejectsState(t *testing.T) {
+	f := newAuthFlow(t)
+
+	// Without StartLogin.
+	resp := f.login("google", "code", "http://localhost", "forged")
+	assert.Equal(t, status.Unauthorized, resp.Code)
+
+	// A state can only be used once.
+	start := f.start("google", "http://localhost")
+	resp = f.login("google", "code", "http://localhost", start.State)
+	require.Equal(t, status.OK, resp.Code)
+	resp = f.login("google", "code", "http://localhost", start.State)
+	assert.Equal(t, status.Unauthorized, resp.Code)
+
+	// The state is bound to its provider and redirect URL.
+	start = f.start("google", "http://localhost")
+	resp = f.login("github", "code", "http://localhost", start.State)
+	assert.Equal(t, status.Unauthorized, resp.Code)
+	start = f.start("google", "http://localhost")
+	resp = f.login("google", "code", "http://localhost/other", start.State)
+	assert.Equal(t, status.Unauthorized, resp.Code)
+
+	// Expired states are rejected.
+	start = f.start("google", "http://localhost")
+	f.asRepo.states[start.State].ExpiresAt = time.Now().Add(-time.Second)
+	resp = f.login("google", "code", "http://localhost", start.State)
+	assert.Equal(t, status.Unauthorized, resp.Code)
+
+	// The nonce of another login is rejected.
+	start = f.start("google", "http://localhost")
+	f.start("google", "http://localhost")
+	resp = f.login("google", "code", "http://localhost", start.State)
+	assert.Equal(t, status.Unauthorized, resp.Code)
+}
+
+func TestUserController_LoginRoles(t *testing.T) {
+	f := newAuthFlow(t)
+
+	resp := f.startAndLogin("google", "code")
+	require.Equal(t, status.OK, resp.Code)
+	require.Len(t, f.uRepo.users, 1)
+	assert.Equal(t, entity.RoleUser, f.uRepo.users[0].Role)
+	assert.Equal(t, entity.RoleUser, tokenClaims(t, resp.Token)["role"])
+
+	// Listed emails are promoted at their next login.
+	start := f.start("google", "http://localhost")
+	controller := f.controller(fmt.Sprintf(`{"code":"code","redirectURL":"http://localhost","state":%q}`, start.State))
+	controller.Opts.AdminEmails = []string{"alice@example.com"}
+	controller.Login()
+	resp = controller.Data["json"].(*LoginResponse)
+	require.Equal(t, status.OK, resp.Code)
+	assert.Equal(t, entity.RoleAdmin, f.uRepo.users[0].Role)
+	assert.Equal(t, entity.RoleAdmin, tokenClaims(t, resp.Token)["role"])
+
+	// Banned users can not log in.
+	now := time.Now()
+	f.uRepo.users[0].BannedAt = &now
+	resp = f.startAndLogin("google", "code")
+	assert.Equal(t, status.ForbiddenUserBanned, resp.Code)
+	assert.Empty(t, resp.Token)
+}
+
+func TestUserController_RefreshRejectsBanned(t *testing.T) {
+	rtRepo := &MockRefreshTokenRepo{}
+	now := time.Now()
+	uRepo := &memUserRepo{users: []*entity.User{{ID: 1, BannedAt: &now}}}
+	controller := newUserController(t, rtRepo, &MockRevokedTokenRepo{}, ``)
+	token := addRefreshToken(t, controller)
+
+	controller = newUserController(t, rtRepo, &MockRevokedTokenRepo{}, fmt.Sprintf(`{"refreshToken":%q}`, token))
+	controller.URepo = uRepo
+	controller.Refresh()
+
+	resp := controller.Data["json"].(*RefreshResponse)
+	assert.Equal(t, status.ForbiddenUserBanned, resp.Code)
+	assert.Empty(t, resp.Token)
+}
+
+// tokenClaims returns the claims of a signed access token.
+func tokenClaims(t *testing.T, token string) jwt.MapClaims {
+	keys, err := authn.NewKeySet(options.Options{AccessTokenSecret: "secret"})
+	require.NoError(t, err)
+	claims, ok := authn.IsValidJWT(context.Background(), keys, &MockRevokedTokenRepo{}, "Bearer "+token)
+	require.True(t, ok)
+	return claims
+}
+
+func TestUserController_UpdateUser(t *testing.T) {
+	uRepo := &memUserRepo{users: []*entity.User{{ID: 1, Name: "Alice", Email: "alice@example.com", Avatar: "https://example.com/a.png"}}}
+	update := func(body string) *GetUserResponse {
+		controller := newUserController(t, &MockRefreshTokenRepo{}, &MockRevokedTokenRepo{}, body)
+		controller.URepo = uRepo
+		controller.UpdateUser()
+		return controller.Data["json"].(*GetUserResponse)
+	}
+
+	resp := update(`{"bio":"  I share cat videos. "}`)
+	require.Equal(t, status.OK, resp.Code)
+	assert.Equal(t, "Alice", resp.User.Name)
+	assert.Equal(t, "https://example.com/a.png", resp.User.Avatar)
+	assert.Equal(t, "I share cat videos.", resp.User.Bio)
+
+	resp = update(`{"name":"Alice B","avatar":""}`)
+	require.Equal(t, status.OK, resp.Code)
+	assert.Equal(t, "Alice B", uRepo.users[0].Name)
+	assert.Empty(t, uRepo.users[0].Avatar)
+	assert.Equal(t, "I share cat videos.", uRepo.users[0].Bio)
+
+	for _, body := range []string{
+		`{"name":" "}`,
+		fmt.Sprintf(`{"bio":%q}`, strings.Repeat("a", 501)),
+		`{"avatar":"javascript:alert(1)"}`,
+		`{"name":1}`,
+		fmt.Sprintf(`{"name":%q}`, strings.Repeat("a", 101)),
+	} {
+		assert.Equal(t, status.BadRequest, update(body).Code, body)
+	}
+	assert.Equal(t, "Alice B", uRepo.users[0].Name)
+
+	// Users whose provider returned no name can edit the rest of their profile.
+	uRepo.users[0].Name = ""
+	resp = update(`{"bio":"Dogs too."}`)
+	require.Equal(t, status.OK, resp.Code)
+	assert.Empty(t, uRepo.users[0].Name)
+	assert.Equal(t, "Dogs too.", uRepo.users[0].Bio)
+}
+
+func TestUserController_GetProfile(t *testing.T) {
+	now := time.Now()
+	alice := &entity.User{ID: 1, Name: "Alice", Email: "alice@example.com", Bio: "Cats"}
+	uRepo := &memUserRepo{users: []*entity.User{alice, {ID: 2, Name: "Bob", BannedAt: &now}}}
+	vRepo := &MockVideoRepo{}
+	for i := 0; i < recentSharesLimit+2; i++ {
+		vRepo.Add(context.Background(), &entity.Video{UserID: userID(1), User: alice, CreatedAt: now.Add(time.Duration(i) * time.Minute)})
+	}
+	vRepo.Add(context.Background(), &entity.Video{UserID: userID(1), User: alice, HiddenAt: &now})
+	vRepo.Add(context.Background(), &entity.Video{UserID: userID(2)})
+
+	get := func(id string, role string) *GetProfileResponse {
+		controller := newUserController(t, &MockRefreshTokenRepo{}, &MockRevokedTokenRepo{}, ``)
+		controller.URepo = uRepo
+		controller.VRepo = vRepo
+		controller.Ctx.Input.SetParam(":id", id)
+		controller.Ctx.Input.SetData(constant.ContextRole, role)
+		controller.GetProfile()
+		return controller.Data["json"].(*GetProfileResponse)
+	}
+
+	resp := get("1", entity.RoleUser)
+	require.Equal(t, status.OK, resp.Code)
+	assert.Equal(t, "Alice", resp.Profile.Name)
+	assert.Equal(t, "Cats", resp.Profile.Bio)
+	assert.Equal(t, int64(recentSharesLimit+2), resp.Profile.ShareCount)
+	require.Len(t, resp.Profile.RecentShares, recentSharesLimit)
+	assert.Equal(t, uint(recentSharesLimit+2), resp.Profile.RecentShares[0].ID)
+	body, err := json.Marshal(resp)
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), "alice@example.com")
+
+	assert.Equal(t, status.NotFound, get("2", entity.RoleUser).Code)
+	assert.Equal(t, status.OK, get("2", entity.RoleAdmin).Code)
+	assert.Equal(t, status.NotFound, get("3", entity.RoleUser).Code)
+	assert.Equal(t, status.BadRequest, get("me", entity.RoleUser).Code)
+}
+
+func TestUserController_Follow(t *testing.T) {
+	now := time.Now()
+	uRepo := &memUserRepo{users: []*entity.User{{ID: 1, Name: "Alice"}, {ID: 2, Name: "Bob"}, {ID: 3, Name: "Carol", BannedAt: &now}}}
+	fRepo := &MockFollowRepo{}
+	follow := func(id string, following bool) *FollowResponse {
+		controller := newUserController(t, &MockRefreshTokenRepo{}, &MockRevokedTokenRepo{}, ``)
+		controller.URepo = uRepo
+		controller.FRepo = fRepo
+		controller.Ctx.Input.SetParam(":id", id)
+		if following {
+			controller.Follow()
+		} else {
+			controller.Unfollow()
+		}
+		return controller.Data["json"].(*FollowResponse)
+	}
+	profile := func(id string) *Profile {
+		controller := newUserController(t, &MockRefreshTokenRepo{}, &MockRevokedTokenRepo{}, ``)
+		controller.URepo = uRepo
+		controller.VRepo = &MockVideoRepo{}
+		controller.FRepo = fRepo
+		controller.Ctx.Input.SetParam(":id", id)
+		controller.GetProfile()
+		return controller.Data["json"].(*GetProfileResponse).Profile
+	}
+
+	// Following twice is not an error.
+	for i := 0; i < 2; i++ {
+		resp := follow("2", true)
+		require.Equal(t, status.OK, resp.Code)
+		assert.True(t, resp.Following)
+		assert.Equal(t, int64(1), resp.FollowerCount)
+	}
+	p := profile("2")
+	assert.True(t, p.Following)
+	assert.Equal(t, int64(1), p.FollowerCount)
+	assert.Equal(t, int64(0), p.FollowingCount)
+	p = profile("1")
+	assert.False(t, p.Following)
+	assert.Equal(t, int64(1), p.FollowingCount)
+
+	assert.Equal(t, status.BadRequest, follow("1", true).Code)
+	assert.Equal(t, status.NotFound, follow("3", true).Code)
+	assert.Equal(t, status.NotFound, follow("4", true).Code)
+
+	resp := follow("2", false)
+	require.Equal(t, status.OK, resp.Code)
+	assert.False(t, resp.Following)
+	assert.Equal(t, int64(0), resp.FollowerCount)
+	assert.False(t, profile("2").Following)
+}
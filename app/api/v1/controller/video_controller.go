@@ -1,46 +1,81 @@
 package controller
 
 import (
-	"container/list"
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
+	"html"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/beego/beego"
 	"github.com/beego/beego/validation"
 	"github.com/gorilla/websocket"
 	"gorm.io/gorm"
 
 	"funny-project-be/domain/entity"
+	"funny-project-be/domain/metadata"
+	"funny-project-be/domain/pubsub"
 	"funny-project-be/domain/repo"
+	"funny-project-be/domain/youtube"
 	"funny-project-be/infra/beego/plugin/authn"
+	"funny-project-be/infra/beego/plugin/authz"
 	"funny-project-be/infra/constant"
 	"funny-project-be/infra/options"
 	"funny-project-be/infra/status"
+	"funny-project-be/infra/ws"
 )
 
+// defaultSearchLimit is the page size of SearchVideos when no limit is given.
+const defaultSearchLimit = 20
+
+// defaultFeedLimit is the page size of GetFeed when no limit is given.
+const defaultFeedLimit = 20
+
+// keepAlivePeriod is the time between the keep-alive comments of StreamVideos.
+var keepAlivePeriod = 15 * time.Second
+
+// errInvalidToken is returned when a WebSocket client authenticates with an invalid token.
+var errInvalidToken = errors.New("token is invalid")
+
 // VideoController exposes apis of Video resource.
 type VideoController struct {
 	BaseController
 
-	VRepo repo.VideoRepo
-	URepo repo.UserRepo
+	VRepo   repo.VideoRepo
+	URepo   repo.UserRepo
+	RRepo   repo.ReactionRepo
+	FRepo   repo.FollowRepo
+	NRepo   repo.NotificationRepo
+	RevRepo repo.RevokedTokenRepo
+
+	Hub      *ws.Hub
+	Broker   pubsub.Broker
+	Resolver metadata.Resolver
+	Keys     *authn.KeySet
 
 	Opts options.Options
 }
 
 // Video info.
 type Video struct {
-	ID          uint      `json:"id,omitempty"`
-	URL         string    `json:"url,omitempty"`
-	SharedBy    string    `json:"sharedBy,omitempty"`
-	Description string    `json:"description,omitempty"`
-	CreatedAt   time.Time `json:"createdAt,omitempty"`
-	UpdatedAt   time.Time `json:"updatedAt,omitempty"`
+	ID              uint    `json:"id,omitempty"`
+	URL             string  `json:"url,omitempty"`
+	Author          *Author `json:"author,omitempty"`
+	Description     string  `json:"description,omitempty"`
+	Title           string  `json:"title,omitempty"`
+	ThumbnailURL    string  `json:"thumbnailURL,omitempty"`
+	ChannelName     string  `json:"channelName,omitempty"`
+	DurationSeconds int     `json:"durationSeconds,omitempty"`
+	Likes           int64   `json:"likes"`
+	Dislikes        int64   `json:"dislikes"`
+	MyReaction      string  `json:"myReaction,omitempty"`
+	// HiddenAt is only seen by moderators.
+	HiddenAt  *time.Time `json:"hiddenAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt,omitempty"`
+	UpdatedAt time.Time  `json:"updatedAt,omitempty"`
 }
 
 // NewVideoFromEntity creates Video from entity.
@@ -50,26 +85,39 @@ func NewVideoFromEntity(e *entity.Video) *Video {
 	}
 
 	return &Video{
-		ID:          e.ID,
-		URL:         e.URL,
-		SharedBy:    e.SharedBy,
-		Description: e.Description,
-		CreatedAt:   e.CreatedAt,
-		UpdatedAt:   e.UpdatedAt,
+		ID:              e.ID,
+		URL:             e.URL,
+		Author:          NewAuthorFromEntity(e.User),
+		Description:     e.Description,
+		Title:           e.Title,
+		ThumbnailURL:    e.ThumbnailURL,
+		ChannelName:     e.ChannelName,
+		DurationSeconds: e.DurationSeconds,
+		HiddenAt:        e.HiddenAt,
+		CreatedAt:       e.CreatedAt,
+		UpdatedAt:       e.UpdatedAt,
 	}
 }
 
-type Subscriber struct {
-	UID  uint
-	IP   string
-	Conn *websocket.Conn // Only for WebSocket users; otherwise nil.
+// Author is the public profile of the user who shared a video.
+type Author struct {
+	ID     uint   `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
 }
 
-var (
-	// Channel for new join users.
-	subscribe   = make(chan Subscriber, 100)
-	subscribers = list.New()
-)
+// NewAuthorFromEntity creates Author from entity.
+func NewAuthorFromEntity(e *entity.User) *Author {
+	if e == nil {
+		return nil
+	}
+
+	return &Author{
+		ID:     e.ID,
+		Name:   e.Name,
+		Avatar: e.Avatar,
+	}
+}
 
 // GetVideoRequest represents a request for get video.
 type GetVideoRequest struct {
@@ -101,26 +149,31 @@ func (c *VideoController) GetVideo() {
 		return
 	}
 	req.ID = uint(id)
+	uid := c.Ctx.Input.GetData(constant.ContextUID).(uint)
 	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
-	video, err := c.VRepo.Get(ctx, req.ID)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			resp.Code = status.NotFound
-			resp.Message = `video not found`
-			return
-		}
-		resp.Code = status.InternalServerError
-		beego.Error("GetVideo ", err)
+	video := c.getVisibleVideo(ctx, c.VRepo, req.ID, &resp.Response, "GetVideo")
+	if video == nil {
 		return
 	}
 
 	resp.Video = NewVideoFromEntity(video)
+	if err := c.setReactions(ctx, uid, resp.Video); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("GetVideo", err)
+		return
+	}
 }
 
 // CreateVideoRequest is a request of CreateVideo.
 type CreateVideoRequest struct {
 	URL         string `json:"url,omitempty" valid:"Required"`
-	Description string `json:"description,omitempty"`
+	Description string `json:"description,omitempty" valid:"MaxSize(1000)"`
+}
+
+// Valid checks that the description fits in the video.created event.
+func (r *CreateVideoRequest) Valid(v *validation.Validation) {
+	validEventText(v, "description", r.Description)
 }
 
 // CreateVideoResponse is a struct for return new created video.
@@ -152,7 +205,7 @@ func (c *VideoController) CreateVideo() {
 	if err != nil {
 		resp.Code = status.InternalServerError
 		resp.SetError(err)
-		beego.Error("CreateVideo ", err)
+		c.logError("CreateVideo", err)
 		return
 	}
 	if !valid {
@@ -161,46 +214,294 @@ func (c *VideoController) CreateVideo() {
 		return
 	}
 
-	video := &entity.Video{
-		URL:         req.URL,
-		Description: req.Description,
+	youtubeID, err := youtube.ParseURL(req.URL)
+	if err != nil {
+		resp.Code = status.BadRequestInvalidYouTubeURL
+		resp.SetError(err)
+		return
 	}
+
 	uid := c.Ctx.Input.GetData(constant.ContextUID).(uint)
 	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
 
+	existing, err := c.VRepo.GetOneByYouTubeID(ctx, youtubeID)
+	if err == nil {
+		setDuplicateVideo(&resp, existing)
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("CreateVideo", err)
+		return
+	}
+
+	video := &entity.Video{
+		URL:         youtube.CanonicalURL(youtubeID),
+		YouTubeID:   youtubeID,
+		Description: req.Description,
+	}
+
 	user, err := c.URepo.Get(ctx, uid)
 	if err != nil {
 		resp.Code = status.InternalServerError
 		resp.SetError(err)
-		beego.Error("GetUser ", err)
+		c.logError("GetUser", err)
+		return
+	}
+	video.UserID = &user.ID
+	video.User = user
+
+	meta, err := c.Resolver.Resolve(ctx, video.URL)
+	switch {
+	case errors.Is(err, metadata.ErrVideoUnavailable):
+		resp.Code = status.BadRequestVideoUnavailable
+		resp.SetError(err)
 		return
+	case err != nil:
+		// Metadata is best effort, the video is still shared without it.
+		c.logError("CreateVideo Resolve", err)
+	default:
+		video.Title = meta.Title
+		video.ThumbnailURL = meta.ThumbnailURL
+		video.ChannelName = meta.ChannelName
+		video.DurationSeconds = meta.DurationSeconds
 	}
-	video.SharedBy = user.Email
 
 	if err := c.VRepo.Add(ctx, video); err != nil {
+		// A concurrent share of the same video was inserted first.
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			resp.Code = status.Conflict
+			resp.Message = `video has already been shared`
+			if existing, err := c.VRepo.GetOneByYouTubeID(ctx, youtubeID); err == nil {
+				setDuplicateVideo(&resp, existing)
+			}
+			return
+		}
 		resp.Code = status.InternalServerError
 		resp.SetError(err)
-		beego.Error("CreateVideo ", err)
+		c.logError("CreateVideo", err)
 		return
 	}
 
-	c.broadcastWebSocket(NewVideoFromEntity(video), uid)
+	c.broadcastWebSocket(ctx, NewVideoFromEntity(video), uid)
 
 	resp.Code = status.Created
 	resp.Video = NewVideoFromEntity(video)
 }
 
+// setDuplicateVideo sets the conflict of a share of the video existing.
+func setDuplicateVideo(resp *CreateVideoResponse, existing *entity.Video) {
+	resp.Code = status.Conflict
+	if existing.HiddenAt != nil {
+		resp.Message = `video has been hidden by a moderator`
+		return
+	}
+	resp.Message = `video has already been shared`
+	resp.Video = NewVideoFromEntity(existing)
+}
+
+// UpdateVideoRequest is a request of UpdateVideo.
+type UpdateVideoRequest struct {
+	Description string `json:"description" valid:"MaxSize(1000)"`
+}
+
+// Valid checks that the description fits in the video.updated event.
+func (r *UpdateVideoRequest) Valid(v *validation.Validation) {
+	validEventText(v, "description", r.Description)
+}
+
+// UpdateVideoResponse is a response of UpdateVideo.
+type UpdateVideoResponse struct {
+	Response
+	Video *Video `json:"video,omitempty"`
+}
+
+// UpdateVideo API. Only the user who shared a video can edit its description,
+// unless a moderator hid it.
+func (c *VideoController) UpdateVideo() {
+	var req UpdateVideoRequest
+	var resp UpdateVideoResponse
+	resp.Code = status.OK
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		resp.Code = status.BadRequest
+		resp.SetError(err)
+		return
+	}
+
+	var validator validation.Validation
+	valid, err := validator.Valid(&req)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("UpdateVideo", err)
+		return
+	}
+	if !valid {
+		resp.Code = status.BadRequest
+		resp.SetValidationErrors(validator.Errors)
+		return
+	}
+
+	video := c.getOwnVideo(&resp.Response, false)
+	if video == nil {
+		return
+	}
+	// Hidden videos can not be edited, their edits would be pushed to every feed.
+	if video.HiddenAt != nil {
+		resp.Code = status.NotFound
+		resp.Message = `video not found`
+		return
+	}
+
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+	video.Description = req.Description
+	if err := c.VRepo.Update(ctx, video); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("UpdateVideo", err)
+		return
+	}
+
+	resp.Video = NewVideoFromEntity(video)
+	c.publishEvent(ctx, c.Broker, EventVideoUpdated, resp.Video, videoAudience(resp.Video.ID))
+}
+
+// DeleteVideo API. Users can delete the videos they shared, moderators any video.
+// Deleted videos are kept in the database but are never served again.
+func (c *VideoController) DeleteVideo() {
+	var resp Response
+	resp.Code = status.OK
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	video := c.getOwnVideo(&resp, true)
+	if video == nil {
+		return
+	}
+
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+	if err := c.VRepo.Remove(ctx, video); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("DeleteVideo", err)
+		return
+	}
+
+	c.publishEvent(ctx, c.Broker, EventVideoDeleted, &VideoDeleted{ID: video.ID}, videoAudience(video.ID))
+}
+
+// getVisibleVideo finds the video id in vRepo. Hidden videos are only found by
+// moderators, so that nobody else can read them, react to them or comment on them.
+// It returns nil after setting the error to resp otherwise.
+func (c *BaseController) getVisibleVideo(ctx context.Context, vRepo repo.VideoRepo, id uint, resp *Response, name string) *entity.Video {
+	video, err := vRepo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			resp.Code = status.NotFound
+			resp.Message = `video not found`
+			return nil
+		}
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError(name, err)
+		return nil
+	}
+	if video.HiddenAt != nil && !authz.Can(c.Ctx, authz.VideoModerate) {
+		resp.Code = status.NotFound
+		resp.Message = `video not found`
+		return nil
+	}
+
+	return video
+}
+
+// getOwnVideo finds the video of the route and checks that the caller shared it,
+// or is a moderator when moderators is true. The caller is identified by the
+// access token, never by the request body.
+// It returns nil after setting the error to resp otherwise.
+func (c *VideoController) getOwnVideo(resp *Response, moderators bool) *entity.Video {
+	id, err := strconv.ParseUint(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		resp.Code = status.BadRequest
+		resp.Message = "id is invalid"
+		return nil
+	}
+
+	uid := c.Ctx.Input.GetData(constant.ContextUID).(uint)
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+
+	video, err := c.VRepo.Get(ctx, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			resp.Code = status.NotFound
+			resp.Message = `video not found`
+			return nil
+		}
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("getOwnVideo", err)
+		return nil
+	}
+
+	if moderators && authz.Can(c.Ctx, authz.VideoModerate) {
+		return video
+	}
+	if video.UserID == nil || *video.UserID != uid {
+		resp.Code = status.Forbidden
+		resp.Message = `video was shared by another user`
+		return nil
+	}
+
+	return video
+}
+
 // ListVideosRequest represents a request for listing videos.
+// Requests with a page use the page/limit mode of older clients, the others are
+// paginated by an opaque cursor from the newest video. Both modes accept the
+// filter[name] parameters of parseVideoFilter, Sort is parsed by parseVideoSort.
 type ListVideosRequest struct {
-	Page  int    `form:"page" valid:"Min(1)"`
-	Limit int    `form:"limit" valid:"Range(1, 200)"`
-	Sort  string `form:"sort"`
+	Page   int    `form:"page"`
+	Limit  int    `form:"limit" valid:"Range(1, 200)"`
+	Sort   string `form:"sort"`
+	Cursor string `form:"cursor"`
+}
+
+// Valid checks that page/limit and cursor modes are not mixed.
+func (r *ListVideosRequest) Valid(v *validation.Validation) {
+	if r.Page < 0 {
+		v.SetError("page", "Minimum is 1")
+	}
+	if r.Page > 0 && r.Cursor != "" {
+		v.SetError("cursor", "Can not be used with page")
+	}
+	if r.Page == 0 && r.Sort != "" {
+		v.SetError("sort", "Can only be used with page")
+	}
 }
 
 // ListVideosResponse is the response of ListVideos.
 type ListVideosResponse struct {
 	RangeResponse
-	Items []*Video `json:"_items"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+	Items      []*Video `json:"_items"`
+}
+
+// videoCursor is the position encoded in the cursors of ListVideos.
+type videoCursor struct {
+	CreatedAt time.Time `json:"createdAt"`
+	ID        uint      `json:"id"`
 }
 
 // ListVideos API.
@@ -227,7 +528,7 @@ func (c *VideoController) ListVideos() {
 	if err != nil {
 		resp.Code = status.InternalServerError
 		resp.SetError(err)
-		beego.Error("ListVideos ", err)
+		c.logError("ListVideos", err)
 		return
 	}
 	if !valid {
@@ -236,101 +537,501 @@ func (c *VideoController) ListVideos() {
 		return
 	}
 
+	var query repo.VideoQuery
+	if query.Sort, err = parseVideoSort(req.Sort); err != nil {
+		resp.Code = status.BadRequestInvalidQuery
+		resp.SetError(err)
+		return
+	}
+	if query.Filter, err = parseVideoFilter(c.Input()); err != nil {
+		resp.Code = status.BadRequestInvalidQuery
+		resp.SetError(err)
+		return
+	}
+
+	uid := c.Ctx.Input.GetData(constant.ContextUID).(uint)
 	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
-	videos, err := c.VRepo.GetRangeByQuery(ctx, req.Sort, req.Limit, req.Page)
+
+	var videos []*entity.Video
+	if req.Page > 0 {
+		videos, err = c.VRepo.GetRangeByVideoQuery(ctx, query, req.Limit, req.Page)
+		if err != nil {
+			resp.Code = status.InternalServerError
+			resp.SetError(err)
+			c.logError("ListVideos", err)
+			return
+		}
+		total, err := c.VRepo.Count(ctx, query.Filter)
+		if err != nil {
+			resp.Code = status.InternalServerError
+			resp.SetError(err)
+			c.logError("ListVideos", err)
+			return
+		}
+		resp.Page = req.Page
+		resp.Total = total
+	} else {
+		videos, resp.NextCursor, err = c.getRangeByCursor(ctx, query.Filter, req.Cursor, req.Limit)
+		if errors.Is(err, errInvalidCursor) {
+			resp.Code = status.BadRequest
+			resp.SetError(err)
+			return
+		}
+		if err != nil {
+			resp.Code = status.InternalServerError
+			resp.SetError(err)
+			c.logError("ListVideos", err)
+			return
+		}
+	}
+
+	resp.Limit = req.Limit
+	for _, u := range videos {
+		if video := NewVideoFromEntity(u); video != nil {
+			resp.Items = append(resp.Items, video)
+		}
+	}
+
+	if err := c.setReactions(ctx, uid, resp.Items...); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("ListVideos", err)
+		return
+	}
+}
+
+// GetFeedRequest represents a request for the feed of the user.
+type GetFeedRequest struct {
+	Limit  int    `form:"limit" valid:"Range(1, 200)"`
+	Cursor string `form:"cursor"`
+}
+
+// GetFeed API. It lists the videos shared by the users the user follows,
+// newest first, paginated by the cursors of ListVideos.
+func (c *VideoController) GetFeed() {
+	var req GetFeedRequest
+	var resp ListVideosResponse
+	resp.Code = status.OK
+	resp.Items = []*Video{}
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	if err := c.ParseForm(&req); err != nil {
+		resp.Code = status.BadRequest
+		resp.SetError(err)
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultFeedLimit
+	}
+
+	var validator validation.Validation
+	valid, err := validator.Valid(&req)
 	if err != nil {
 		resp.Code = status.InternalServerError
 		resp.SetError(err)
-		beego.Error("ListVideos ", err)
+		c.logError("GetFeed", err)
+		return
+	}
+	if !valid {
+		resp.Code = status.BadRequest
+		resp.SetValidationErrors(validator.Errors)
+		return
+	}
+
+	uid := c.Ctx.Input.GetData(constant.ContextUID).(uint)
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+
+	videos, nextCursor, err := c.getRangeByCursor(ctx, repo.VideoFilter{FollowedBy: uid}, req.Cursor, req.Limit)
+	if errors.Is(err, errInvalidCursor) {
+		resp.Code = status.BadRequest
+		resp.SetError(err)
 		return
 	}
-	total, err := c.VRepo.Count(ctx)
 	if err != nil {
 		resp.Code = status.InternalServerError
 		resp.SetError(err)
-		beego.Error("ListVideos ", err)
+		c.logError("GetFeed", err)
+		return
+	}
+
+	resp.Limit = req.Limit
+	resp.NextCursor = nextCursor
+	for _, v := range videos {
+		resp.Items = append(resp.Items, NewVideoFromEntity(v))
+	}
+
+	if err := c.setReactions(ctx, uid, resp.Items...); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("GetFeed", err)
+		return
+	}
+}
+
+// SearchVideosRequest represents a request for searching videos.
+type SearchVideosRequest struct {
+	Q     string `form:"q" valid:"Required;MaxSize(200)"`
+	Page  int    `form:"page" valid:"Min(1)"`
+	Limit int    `form:"limit" valid:"Range(1, 200)"`
+}
+
+// VideoSearchItem is a video matching a search.
+type VideoSearchItem struct {
+	*Video
+	Rank float64 `json:"rank"`
+	// Snippet is an HTML-escaped excerpt with the matched terms in <mark> tags.
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// SearchVideosResponse is the response of SearchVideos.
+type SearchVideosResponse struct {
+	RangeResponse
+	Items []*VideoSearchItem `json:"_items"`
+}
+
+// SearchVideos API. Videos are searched by title and description, most relevant first.
+func (c *VideoController) SearchVideos() {
+	var req SearchVideosRequest
+	var resp SearchVideosResponse
+	resp.Code = status.OK
+	resp.Items = []*VideoSearchItem{}
+
+	defer func() {
+		c.Ctx.Output.SetStatus(resp.Code / 1000)
+		c.Data["json"] = &resp
+		c.ServeJSON()
+	}()
+
+	if err := c.ParseForm(&req); err != nil {
+		resp.Code = status.BadRequest
+		resp.SetError(err)
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultSearchLimit
+	}
+
+	var validator validation.Validation
+	valid, err := validator.Valid(&req)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("SearchVideos", err)
+		return
+	}
+	if !valid {
+		resp.Code = status.BadRequest
+		resp.SetValidationErrors(validator.Errors)
+		return
+	}
+
+	uid := c.Ctx.Input.GetData(constant.ContextUID).(uint)
+	ctx := c.Ctx.Input.GetData(constant.ContextCtx).(context.Context)
+	results, total, err := c.VRepo.SearchVideos(ctx, req.Q, req.Limit, req.Page)
+	if err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("SearchVideos", err)
 		return
 	}
 
 	resp.Page = req.Page
 	resp.Limit = req.Limit
 	resp.Total = total
-	for _, u := range videos {
-		if video := NewVideoFromEntity(u); u != nil {
-			resp.Items = append(resp.Items, video)
+	videos := make([]*Video, 0, len(results))
+	for _, r := range results {
+		video := NewVideoFromEntity(r.Video)
+		videos = append(videos, video)
+		resp.Items = append(resp.Items, &VideoSearchItem{
+			Video:   video,
+			Rank:    r.Rank,
+			Snippet: highlight(r.Snippet),
+		})
+	}
+
+	if err := c.setReactions(ctx, uid, videos...); err != nil {
+		resp.Code = status.InternalServerError
+		resp.SetError(err)
+		c.logError("SearchVideos", err)
+		return
+	}
+}
+
+// highlight escapes a search snippet and turns its markers into <mark> tags.
+func highlight(snippet string) string {
+	s := html.EscapeString(snippet)
+	s = strings.ReplaceAll(s, repo.HighlightStart, "<mark>")
+
+	return strings.ReplaceAll(s, repo.HighlightStop, "</mark>")
+}
+
+// getRangeByCursor returns a page of videos after cursor and the cursor of the next page,
+// which is empty on the last page.
+func (c *VideoController) getRangeByCursor(ctx context.Context, filter repo.VideoFilter, cursor string, limit int) ([]*entity.Video, string, error) {
+	var after videoCursor
+	if cursor != "" {
+		if err := decodeCursor(cursor, &after); err != nil {
+			return nil, "", err
 		}
 	}
+
+	// Fetch one more video to know whether there is a next page.
+	videos, err := c.VRepo.GetRangeByCursor(ctx, filter, after.CreatedAt, after.ID, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(videos) <= limit {
+		return videos, "", nil
+	}
+
+	videos = videos[:limit]
+	last := videos[len(videos)-1]
+
+	return videos, encodeCursor(videoCursor{CreatedAt: last.CreatedAt, ID: last.ID}), nil
 }
 
-// Join method handles WebSocket requests.
+// setReactions sets the reaction counters and the reaction of user uid to videos.
+func (c *VideoController) setReactions(ctx context.Context, uid uint, videos ...*Video) error {
+	ids := make([]uint, 0, len(videos))
+	for _, v := range videos {
+		ids = append(ids, v.ID)
+	}
+
+	counts, err := c.RRepo.CountByVideoIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	reactions, err := c.RRepo.GetByVideoIDs(ctx, uid, ids)
+	if err != nil {
+		return err
+	}
+
+	kinds := make(map[uint]string, len(reactions))
+	for _, r := range reactions {
+		kinds[r.VideoID] = r.Kind
+	}
+	for _, v := range videos {
+		if count, ok := counts[v.ID]; ok {
+			v.Likes = count.Likes
+			v.Dislikes = count.Dislikes
+		}
+		v.MyReaction = kinds[v.ID]
+	}
+
+	return nil
+}
+
+// JoinWebSocket handles WebSocket requests. Clients selecting the ws.Subprotocol
+// speak ws.Version1, the others send their token in the first frame. A legacy
+// client reconnecting with the lastSeenId query parameter, the id of the last
+// notification it received, is first sent the notifications it missed.
 func (c *VideoController) JoinWebSocket() {
 	ip := c.Ctx.Input.IP()
 	// Upgrade from http request to WebSocket.
 	var upgrader = websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
+		Subprotocols:    []string{ws.Subprotocol},
 		CheckOrigin: func(r *http.Request) bool {
 			// Allow all origins for WebSocket connections
 			return true
 		},
 	}
-	ws, err := upgrader.Upgrade(c.Ctx.ResponseWriter, c.Ctx.Request, nil)
+	conn, err := upgrader.Upgrade(c.Ctx.ResponseWriter, c.Ctx.Request, nil)
 	if _, ok := err.(websocket.HandshakeError); ok {
 		http.Error(c.Ctx.ResponseWriter, "Not a websocket handshake", 400)
 		return
 	} else if err != nil {
-		beego.Error("Cannot setup WebSocket connection:", err)
+		c.logError("Cannot setup WebSocket connection", err)
 		return
 	}
 
-	_, tokenBytes, err := ws.ReadMessage()
+	if conn.Subprotocol() == ws.Subprotocol {
+		c.Hub.Serve(ip, conn, &wsAuthenticator{Keys: c.Keys, RevRepo: c.RevRepo, NRepo: c.NRepo, Hub: c.Hub, Log: c.logger()})
+		return
+	}
+
+	_, tokenBytes, err := conn.ReadMessage()
 	if err != nil {
-		ws.Close()
+		conn.Close()
 		return
 	}
-	claims, valid := authn.IsValidJWT(c.Opts, string(tokenBytes))
+	claims, valid := authn.IsValidJWT(c.Ctx.Request.Context(), c.Keys, c.RevRepo, string(tokenBytes))
 	if !valid {
-		ws.Close()
+		conn.Close()
 		return
 	}
 	uid, err := strconv.Atoi(claims["sub"].(string))
 	if err != nil {
-		ws.Close()
+		conn.Close()
 		return
 	}
-	subscribe <- Subscriber{UID: uint(uid), IP: ip, Conn: ws}
-}
+	client := c.Hub.Register(uint(uid), ip, conn)
 
-// broadcastWebSocket broadcasts messages to WebSocket users.
-func (c *VideoController) broadcastWebSocket(video *Video, uid uint) {
-	data, err := json.Marshal(video)
-	if err != nil {
-		beego.Error("Fail to marshal video:", err)
+	lastSeenID, err := strconv.ParseUint(c.Ctx.Input.Query("lastSeenId"), 10, 64)
+	if err != nil || lastSeenID == 0 {
 		return
 	}
+	if err := replayNotifications(c.Ctx.Request.Context(), c.NRepo, c.Hub, client, uint(uid), uint(lastSeenID)); err != nil {
+		c.logError("JoinWebSocket replay", err)
+	}
+}
+
+// StreamVideos streams the events of JoinWebSocket as Server-Sent Events, for
+// the clients whose proxies block WebSocket upgrades. A client reconnecting with
+// the Last-Event-ID header is first sent the notifications it missed. A comment
+// is sent every keepAlivePeriod so that proxies keep idle streams open.
+func (c *VideoController) StreamVideos() {
+	uid := c.Ctx.Input.GetData(constant.ContextUID).(uint)
+	w := c.Ctx.ResponseWriter
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Disable the response buffering of nginx.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
 
-	for sub := subscribers.Front(); sub != nil; sub = sub.Next() {
-		if uid == sub.Value.(Subscriber).UID {
-			continue
+	client := c.Hub.Stream(uid, c.Ctx.Input.IP())
+	defer c.Hub.Unregister(client)
+
+	ctx := c.Ctx.Request.Context()
+	lastEventID, err := strconv.ParseUint(c.Ctx.Input.Header("Last-Event-ID"), 10, 64)
+	if err == nil && lastEventID != 0 {
+		if err := replayNotifications(ctx, c.NRepo, c.Hub, client, uid, uint(lastEventID)); err != nil {
+			c.logError("StreamVideos replay", err)
 		}
-		ws := sub.Value.(Subscriber).Conn
-		if ws != nil {
-			if ws.WriteMessage(websocket.TextMessage, data) != nil {
-				beego.Info("User disconnected")
+	}
+
+	ticker := time.NewTicker(keepAlivePeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case frame, ok := <-client.Events():
+			if !ok {
+				// The hub dropped the client.
+				return
+			}
+			if _, err := w.Write(frame); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := w.Write([]byte(": keep-alive\n\n")); err != nil {
+				return
 			}
+		case <-ctx.Done():
+			return
 		}
+		w.Flush()
 	}
 }
 
-// This function handles all incoming chan messages.
-func handleScription() {
-	for {
-		sub := <-subscribe
-		subscribers.PushBack(sub)
-		fmt.Printf("%v+\n", subscribers)
+// wsAuthenticator authenticates the ws.Version1 clients with their access token
+// and replays the notifications they missed.
+type wsAuthenticator struct {
+	Keys    *authn.KeySet
+	RevRepo repo.RevokedTokenRepo
+	NRepo   repo.NotificationRepo
+	Hub     *ws.Hub
+	Log     *slog.Logger
+}
+
+// Authenticate implements ws.Authenticator.
+func (a *wsAuthenticator) Authenticate(ctx context.Context, token string) (uint, error) {
+	if !strings.HasPrefix(token, "Bearer ") {
+		token = "Bearer " + token
+	}
+	claims, valid := authn.IsValidJWT(ctx, a.Keys, a.RevRepo, token)
+	if !valid {
+		return 0, errInvalidToken
+	}
+	sub, _ := claims["sub"].(string)
+	uid, err := strconv.ParseUint(sub, 10, 64)
+	if err != nil {
+		return 0, errInvalidToken
+	}
+
+	return uint(uid), nil
+}
+
+// Authenticated implements ws.Authenticator.
+func (a *wsAuthenticator) Authenticated(ctx context.Context, client *ws.Client, auth ws.AuthPayload) {
+	if auth.LastSeenID == 0 {
+		return
+	}
+	if err := replayNotifications(ctx, a.NRepo, a.Hub, client, client.UserID(), auth.LastSeenID); err != nil {
+		a.Log.ErrorContext(ctx, "JoinWebSocket replay", "uid", client.UserID(), "err", err)
 	}
 }
 
-func init() {
-	go handleScription()
+// broadcastWebSocket notifies the followers of the sharer uid of a new video, in their
+// inbox and over the WebSockets connected to any replica. The subscribers of the
+// sharer are notified over WebSockets too. The event carries uid rather than the
+// followers, which the hub of each replica resolves, and the inboxes are filled
+// off the request since the number of followers is unbounded.
+func (c *VideoController) broadcastWebSocket(ctx context.Context, video *Video, uid uint) {
+	data, err := json.Marshal(video)
+	if err != nil {
+		c.logger().ErrorContext(ctx, "Fail to marshal video", "err", err)
+		return
+	}
+
+	msg := ws.Message{
+		Audience:   ws.Audience{FollowersOf: uid, Except: uid, Topics: []string{ws.TopicUser(uid)}},
+		Type:       EventVideoCreated,
+		Payload:    data,
+		LegacyBare: true,
+	}
+	if err := ws.Publish(ctx, c.Broker, msg); err != nil {
+		c.logger().ErrorContext(ctx, "Fail to publish video", "err", err)
+	}
+
+	go c.notifyFollowers(context.WithoutCancel(ctx), video.ID, uid)
+}
+
+// notifyFollowers adds a notification of the video videoID to the inbox of every
+// follower of the sharer uid, then pushes a single event to all of them, whom
+// the hub of each replica looks up. The event has no id since every follower has
+// their own notification, which is replayed to clients reconnecting with lastSeenId.
+// It outlives the request, so it must not use c.Ctx.
+func (c *VideoController) notifyFollowers(ctx context.Context, videoID uint, uid uint) {
+	followers, err := c.FRepo.GetFollowerIDs(ctx, uid)
+	if err != nil {
+		c.logger().ErrorContext(ctx, "Fail to get followers", "err", err)
+		return
+	}
+	if len(followers) == 0 {
+		return
+	}
+
+	notifications := make([]*entity.Notification, 0, len(followers))
+	for _, follower := range followers {
+		notifications = append(notifications, &entity.Notification{
+			UserID:  follower,
+			ActorID: uid,
+			Kind:    entity.NotificationVideoShared,
+			VideoID: &videoID,
+		})
+	}
+	if err := c.NRepo.Add(ctx, notifications...); err != nil {
+		c.logger().ErrorContext(ctx, "Fail to add notifications", "err", err)
+		return
+	}
+
+	event := NewNotificationFromEntity(&entity.Notification{
+		ActorID:   uid,
+		Kind:      entity.NotificationVideoShared,
+		VideoID:   &videoID,
+		CreatedAt: notifications[0].CreatedAt,
+	})
+	c.publishEvent(ctx, c.Broker, EventNotification, event, ws.Audience{FollowersOf: uid, Except: uid})
 }
@@ -0,0 +1,840 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	beegoctx "github.com/beego/beego/context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"funny-project-be/domain/entity"
+	"funny-project-be/domain/metadata"
+	"funny-project-be/domain/repo"
+	"funny-project-be/infra/constant"
+	"funny-project-be/infra/pubsub/pubsubimpl"
+	"funny-project-be/infra/status"
+	"funny-project-be/infra/ws"
+)
+
+type MockVideoRepo struct {
+	mu     sync.Mutex
+	videos []*entity.Video
+	// follows resolves VideoFilter.FollowedBy.
+	follows *MockFollowRepo
+}
+
+func (m *MockVideoRepo) Get(ctx context.Context, id uint) (*entity.Video, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range m.videos {
+		if v.ID == id {
+			return v, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+func (m *MockVideoRepo) GetOneByYouTubeID(ctx context.Context, youtubeID string) (*entity.Video, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range m.videos {
+		if v.YouTubeID == youtubeID {
+			return v, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+func (m *MockVideoRepo) filter(filter repo.VideoFilter) []*entity.Video {
+	var videos []*entity.Video
+	for _, v := range m.videos {
+		if v.HiddenAt != nil {
+			continue
+		}
+		if filter.UserID != 0 && (v.UserID == nil || *v.UserID != filter.UserID) {
+			continue
+		}
+		if filter.FollowedBy != 0 && (v.UserID == nil || !m.follows.isFollowing(filter.FollowedBy, *v.UserID)) {
+			continue
+		}
+		if !filter.CreatedAfter.IsZero() && !v.CreatedAt.After(filter.CreatedAfter) {
+			continue
+		}
+		if !filter.CreatedBefore.IsZero() && !v.CreatedAt.Before(filter.CreatedBefore) {
+			continue
+		}
+		videos = append(videos, v)
+	}
+	return videos
+}
+func (m *MockVideoRepo) GetRangeByVideoQuery(ctx context.Context, query repo.VideoQuery, limit int, page int) ([]*entity.Video, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.filter(query.Filter), nil
+}
+func (m *MockVideoRepo) GetRangeByCursor(ctx context.Context, filter repo.VideoFilter, createdAt time.Time, id uint, limit int) ([]*entity.Video, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	videos := m.filter(filter)
+	sort.Slice(videos, func(i, j int) bool {
+		if videos[i].CreatedAt.Equal(videos[j].CreatedAt) {
+			return videos[i].ID > videos[j].ID
+		}
+		return videos[i].CreatedAt.After(videos[j].CreatedAt)
+	})
+	var page []*entity.Video
+	for _, v := range videos {
+		after := v.CreatedAt.Before(createdAt) || (v.CreatedAt.Equal(createdAt) && v.ID < id)
+		if (id == 0 || after) && len(page) < limit {
+			page = append(page, v)
+		}
+	}
+	return page, nil
+}
+
+// SearchVideos matches videos containing every term, ranking title matches first.
+func (m *MockVideoRepo) SearchVideos(ctx context.Context, text string, limit int, page int) ([]*repo.VideoSearchResult, int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	terms := strings.Fields(strings.ToLower(text))
+	var results []*repo.VideoSearchResult
+	for _, v := range m.videos {
+		doc := strings.ToLower(v.Title + " " + v.Description)
+		rank := 0.0
+		for _, term := range terms {
+			if !strings.Contains(doc, term) {
+				rank = 0
+				break
+			}
+			rank += 0.1
+			if strings.Contains(strings.ToLower(v.Title), term) {
+				rank += 1
+			}
+		}
+		if rank == 0 {
+			continue
+		}
+		snippet := v.Title + " " + v.Description
+		for _, term := range terms {
+			i := strings.Index(strings.ToLower(snippet), term)
+			snippet = snippet[:i] + repo.HighlightStart + snippet[i:i+len(term)] + repo.HighlightStop + snippet[i+len(term):]
+		}
+		results = append(results, &repo.VideoSearchResult{Video: v, Rank: rank, Snippet: snippet})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Rank > results[j].Rank })
+	total := int64(len(results))
+	start := limit * (page - 1)
+	if start > len(results) {
+		start = len(results)
+	}
+	end := start + limit
+	if end > len(results) {
+		end = len(results)
+	}
+	return results[start:end], total, nil
+}
+func (m *MockVideoRepo) Count(ctx context.Context, filter repo.VideoFilter) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(len(m.filter(filter))), nil
+}
+func (m *MockVideoRepo) Add(ctx context.Context, videos ...*entity.Video) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range videos {
+		// Like the unique index of youtube_id.
+		for _, existing := range m.videos {
+			if v.YouTubeID != "" && existing.YouTubeID == v.YouTubeID {
+				return gorm.ErrDuplicatedKey
+			}
+		}
+		v.ID = uint(len(m.videos) + 1)
+		m.videos = append(m.videos, v)
+	}
+	return nil
+}
+func (m *MockVideoRepo) Remove(ctx context.Context, videos ...*entity.Video) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, video := range videos {
+		for i, v := range m.videos {
+			if v.ID == video.ID {
+				m.videos = append(m.videos[:i], m.videos[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
+func (m *MockVideoRepo) Update(ctx context.Context, videos ...*entity.Video) error {
+	return nil
+}
+
+type MockResolver struct {
+	Video *metadata.Video
+	Err   error
+}
+
+func (m *MockResolver) Resolve(ctx context.Context, url string) (*metadata.Video, error) {
+	return m.Video, m.Err
+}
+
+func (c *VideoController) ServeJSON() {}
+
+type MockFollowRepo struct {
+	mu      sync.Mutex
+	follows []*entity.Follow
+}
+
+func (m *MockFollowRepo) Get(ctx context.Context, followerID uint, followeeID uint) (*entity.Follow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, f := range m.follows {
+		if f.FollowerID == followerID && f.FolloweeID == followeeID {
+			return f, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+func (m *MockFollowRepo) GetFollowerIDs(ctx context.Context, followeeID uint) ([]uint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []uint
+	for _, f := range m.follows {
+		if f.FolloweeID == followeeID {
+			ids = append(ids, f.FollowerID)
+		}
+	}
+	return ids, nil
+}
+func (m *MockFollowRepo) CountFollowers(ctx context.Context, followeeID uint) (int64, error) {
+	ids, err := m.GetFollowerIDs(ctx, followeeID)
+	return int64(len(ids)), err
+}
+func (m *MockFollowRepo) CountFollowing(ctx context.Context, followerID uint) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var count int64
+	for _, f := range m.follows {
+		if f.FollowerID == followerID {
+			count++
+		}
+	}
+	return count, nil
+}
+func (m *MockFollowRepo) Add(ctx context.Context, follow *entity.Follow) error {
+	if _, err := m.Get(ctx, follow.FollowerID, follow.FolloweeID); err == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	follow.ID = uint(len(m.follows) + 1)
+	m.follows = append(m.follows, follow)
+	return nil
+}
+func (m *MockFollowRepo) Remove(ctx context.Context, follows ...*entity.Follow) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, follow := range follows {
+		for i, f := range m.follows {
+			if f.FollowerID == follow.FollowerID && f.FolloweeID == follow.FolloweeID {
+				m.follows = append(m.follows[:i], m.follows[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// isFollowing reports whether followerID follows followeeID, without locking the videos.
+func (m *MockFollowRepo) isFollowing(followerID uint, followeeID uint) bool {
+	if m == nil {
+		return false
+	}
+	_, err := m.Get(context.Background(), followerID, followeeID)
+	return err == nil
+}
+
+// userID returns a pointer to id.
+func userID(id uint) *uint {
+	return &id
+}
+
+func newVideoController(t *testing.T, method string, body string) *VideoController {
+	req, err := http.NewRequest(method, "/videos", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	controller := &VideoController{
+		VRepo:    &MockVideoRepo{},
+		URepo:    &MockUserRepo{},
+		RRepo:    &MockReactionRepo{},
+		FRepo:    &MockFollowRepo{},
+		NRepo:    &MockNotificationRepo{},
+		Broker:   pubsubimpl.NewMemoryBroker(),
+		Resolver: &MockResolver{Video: &metadata.Video{}},
+	}
+	controller.Ctx = &beegoctx.Context{
+		Input:          beegoctx.NewInput(),
+		Output:         beegoctx.NewOutput(),
+		Request:        req,
+		ResponseWriter: &beegoctx.Response{},
+	}
+	controller.Ctx.Input.Context = controller.Ctx
+	controller.Ctx.Input.RequestBody = []byte(body)
+	controller.Ctx.Output.Context = controller.Ctx
+	controller.Data = make(map[interface{}]interface{})
+	controller.Ctx.Input.SetData(constant.ContextUID, uint(1))
+	controller.Ctx.Input.SetData(constant.ContextCtx, context.Background())
+
+	return controller
+}
+
+func TestVideoController_CreateVideo(t *testing.T) {
+	controller := newVideoController(t, "POST", `{"url":"https://www.youtube.com/watch?v=dQw4w9WgXcQ"}`)
+	controller.Resolver = &MockResolver{Video: &metadata.Video{
+		Title:           "Never Gonna Give You Up",
+		ThumbnailURL:    "https://i.ytimg.com/vi/dQw4w9WgXcQ/hqdefault.jpg",
+		ChannelName:     "Rick Astley",
+		DurationSeconds: 213,
+	}}
+
+	controller.CreateVideo()
+
+	resp := controller.Data["json"].(*CreateVideoResponse)
+	assert.Equal(t, status.Created, resp.Code)
+	assert.Equal(t, &Author{ID: 1, Name: "Test"}, resp.Video.Author)
+	// The email of the sharer is never exposed.
+	data, err := json.Marshal(resp.Video)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "test@example.com")
+	assert.Equal(t, "Never Gonna Give You Up", resp.Video.Title)
+	assert.Equal(t, "Rick Astley", resp.Video.ChannelName)
+	assert.Equal(t, 213, resp.Video.DurationSeconds)
+}
+
+func TestVideoController_CreateVideoCanonicalisesURL(t *testing.T) {
+	controller := newVideoController(t, "POST", `{"url":"https://youtu.be/dQw4w9WgXcQ?t=42"}`)
+
+	controller.CreateVideo()
+
+	resp := controller.Data["json"].(*CreateVideoResponse)
+	assert.Equal(t, status.Created, resp.Code)
+	assert.Equal(t, "https://www.youtube.com/watch?v=dQw4w9WgXcQ", resp.Video.URL)
+}
+
+func TestVideoController_CreateVideoInvalidURL(t *testing.T) {
+	controller := newVideoController(t, "POST", `{"url":"https://vimeo.com/123456"}`)
+
+	controller.CreateVideo()
+
+	resp := controller.Data["json"].(*CreateVideoResponse)
+	assert.Equal(t, status.BadRequestInvalidYouTubeURL, resp.Code)
+	assert.Equal(t, http.StatusBadRequest, resp.Code/1000)
+}
+
+func TestVideoController_CreateVideoLongDescription(t *testing.T) {
+	for _, description := range []string{strings.Repeat("a", 1001), strings.Repeat("&", 1000)} {
+		body := `{"url":"https://www.youtube.com/watch?v=dQw4w9WgXcQ","description":` + strconv.Quote(description) + `}`
+		controller := newVideoController(t, "POST", body)
+
+		controller.CreateVideo()
+
+		resp := controller.Data["json"].(*CreateVideoResponse)
+		assert.Equal(t, status.BadRequest, resp.Code)
+	}
+}
+
+func TestVideoController_CreateVideoDuplicate(t *testing.T) {
+	vRepo := &MockVideoRepo{}
+	vRepo.Add(context.Background(), &entity.Video{
+		URL:       "https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+		YouTubeID: "dQw4w9WgXcQ",
+	})
+	controller := newVideoController(t, "POST", `{"url":"https://m.youtube.com/shorts/dQw4w9WgXcQ"}`)
+	controller.VRepo = vRepo
+
+	controller.CreateVideo()
+
+	resp := controller.Data["json"].(*CreateVideoResponse)
+	assert.Equal(t, status.Conflict, resp.Code)
+	assert.Equal(t, uint(1), resp.Video.ID)
+	assert.Len(t, vRepo.videos, 1)
+}
+
+// racingVideoRepo misses the video of the first duplicate check, as when a
+// concurrent share is inserted right after it.
+type racingVideoRepo struct {
+	*MockVideoRepo
+	checked bool
+}
+
+func (m *racingVideoRepo) GetOneByYouTubeID(ctx context.Context, youtubeID string) (*entity.Video, error) {
+	if !m.checked {
+		m.checked = true
+		return nil, gorm.ErrRecordNotFound
+	}
+	return m.MockVideoRepo.GetOneByYouTubeID(ctx, youtubeID)
+}
+
+func TestVideoController_CreateVideoConcurrentDuplicate(t *testing.T) {
+	vRepo := &MockVideoRepo{}
+	vRepo.Add(context.Background(), &entity.Video{
+		URL:       "https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+		YouTubeID: "dQw4w9WgXcQ",
+	})
+	controller := newVideoController(t, "POST", `{"url":"https://www.youtube.com/watch?v=dQw4w9WgXcQ"}`)
+	controller.VRepo = &racingVideoRepo{MockVideoRepo: vRepo}
+
+	controller.CreateVideo()
+
+	resp := controller.Data["json"].(*CreateVideoResponse)
+	assert.Equal(t, status.Conflict, resp.Code)
+	assert.Equal(t, uint(1), resp.Video.ID)
+	assert.Len(t, vRepo.videos, 1)
+}
+
+func TestVideoController_CreateVideoUnavailable(t *testing.T) {
+	controller := newVideoController(t, "POST", `{"url":"https://www.youtube.com/watch?v=xxxxxxxxxxx"}`)
+	controller.Resolver = &MockResolver{Err: metadata.ErrVideoUnavailable}
+
+	controller.CreateVideo()
+
+	resp := controller.Data["json"].(*CreateVideoResponse)
+	assert.Equal(t, status.BadRequestVideoUnavailable, resp.Code)
+	assert.Nil(t, resp.Video)
+}
+
+func TestVideoController_ListVideosWithReactions(t *testing.T) {
+	controller := newVideoController(t, "GET", ``)
+	vRepo := controller.VRepo.(*MockVideoRepo)
+	vRepo.Add(context.Background(), &entity.Video{YouTubeID: "aaaaaaaaaaa"}, &entity.Video{YouTubeID: "bbbbbbbbbbb"})
+	rRepo := controller.RRepo.(*MockReactionRepo)
+	rRepo.Upsert(context.Background(), &entity.Reaction{UserID: 1, VideoID: 1, Kind: entity.ReactionLike})
+	rRepo.Upsert(context.Background(), &entity.Reaction{UserID: 2, VideoID: 1, Kind: entity.ReactionDislike})
+	rRepo.Upsert(context.Background(), &entity.Reaction{UserID: 2, VideoID: 2, Kind: entity.ReactionLike})
+	controller.Ctx.Request.Form = map[string][]string{"page": {"1"}, "limit": {"10"}}
+
+	controller.ListVideos()
+
+	resp := controller.Data["json"].(*ListVideosResponse)
+	assert.Equal(t, status.OK, resp.Code)
+	assert.Len(t, resp.Items, 2)
+	assert.Equal(t, int64(1), resp.Items[0].Likes)
+	assert.Equal(t, int64(1), resp.Items[0].Dislikes)
+	assert.Equal(t, entity.ReactionLike, resp.Items[0].MyReaction)
+	assert.Equal(t, int64(1), resp.Items[1].Likes)
+	assert.Empty(t, resp.Items[1].MyReaction)
+}
+
+func TestVideoController_ListVideosByCursor(t *testing.T) {
+	vRepo := &MockVideoRepo{}
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		// Two videos share each timestamp to exercise the id tie-breaker.
+		vRepo.Add(context.Background(), &entity.Video{CreatedAt: now.Add(time.Duration(i/2) * time.Second)})
+	}
+
+	var ids []uint
+	cursor := ""
+	for page := 0; page < 5; page++ {
+		controller := newVideoController(t, "GET", ``)
+		controller.VRepo = vRepo
+		controller.Ctx.Request.Form = map[string][]string{"limit": {"2"}, "cursor": {cursor}}
+		controller.ListVideos()
+
+		resp := controller.Data["json"].(*ListVideosResponse)
+		assert.Equal(t, status.OK, resp.Code)
+		for _, v := range resp.Items {
+			ids = append(ids, v.ID)
+		}
+		cursor = resp.NextCursor
+		if cursor == "" {
+			break
+		}
+
+		// A video shared meanwhile does not shift the following pages.
+		if page == 0 {
+			vRepo.Add(context.Background(), &entity.Video{CreatedAt: now.Add(time.Hour)})
+		}
+	}
+	assert.Equal(t, []uint{5, 4, 3, 2, 1}, ids)
+}
+
+func TestVideoController_ListVideosInvalidModes(t *testing.T) {
+	cases := []map[string][]string{
+		{"page": {"1"}, "limit": {"2"}, "cursor": {"abc"}},
+		{"limit": {"2"}, "sort": {"id"}},
+		{"page": {"-1"}, "limit": {"2"}},
+		{"limit": {"2"}, "cursor": {"!!"}},
+	}
+	for _, form := range cases {
+		controller := newVideoController(t, "GET", ``)
+		controller.Ctx.Request.Form = form
+		controller.ListVideos()
+		assert.Equal(t, status.BadRequest, controller.Data["json"].(*ListVideosResponse).Code, form)
+	}
+}
+
+func TestVideoController_ListVideosFiltered(t *testing.T) {
+	controller := newVideoController(t, "GET", ``)
+	vRepo := controller.VRepo.(*MockVideoRepo)
+	vRepo.Add(context.Background(),
+		&entity.Video{UserID: userID(1)},
+		&entity.Video{UserID: userID(2)},
+		&entity.Video{UserID: userID(1)},
+	)
+	controller.Ctx.Request.Form = map[string][]string{
+		"page":             {"1"},
+		"limit":            {"10"},
+		"sort":             {"-createdAt"},
+		"filter[authorId]": {"1"},
+	}
+
+	controller.ListVideos()
+
+	resp := controller.Data["json"].(*ListVideosResponse)
+	assert.Equal(t, status.OK, resp.Code)
+	assert.Equal(t, int64(2), resp.Total)
+	assert.Len(t, resp.Items, 2)
+
+	controller = newVideoController(t, "GET", ``)
+	controller.Ctx.Request.Form = map[string][]string{"page": {"1"}, "limit": {"10"}, "sort": {"url"}}
+	controller.ListVideos()
+	assert.Equal(t, status.BadRequestInvalidQuery, controller.Data["json"].(*ListVideosResponse).Code)
+}
+
+func TestVideoController_SearchVideos(t *testing.T) {
+	controller := newVideoController(t, "GET", ``)
+	vRepo := controller.VRepo.(*MockVideoRepo)
+	vRepo.Add(context.Background(),
+		&entity.Video{Title: "Cat compilation", Description: "funny <b>cats</b>"},
+		&entity.Video{Title: "Dog tricks", Description: "a dog and a cat"},
+		&entity.Video{Title: "Cooking", Description: "pho recipe"},
+	)
+	controller.Ctx.Request.Form = map[string][]string{"q": {"cat"}}
+
+	controller.SearchVideos()
+
+	resp := controller.Data["json"].(*SearchVideosResponse)
+	assert.Equal(t, status.OK, resp.Code)
+	assert.Equal(t, int64(2), resp.Total)
+	assert.Equal(t, 1, resp.Page)
+	assert.Equal(t, defaultSearchLimit, resp.Limit)
+	if assert.Len(t, resp.Items, 2) {
+		// Title matches rank first.
+		assert.Equal(t, uint(1), resp.Items[0].ID)
+		assert.Equal(t, "<mark>Cat</mark> compilation funny &lt;b&gt;cats&lt;/b&gt;", resp.Items[0].Snippet)
+		assert.Equal(t, uint(2), resp.Items[1].ID)
+	}
+
+	controller = newVideoController(t, "GET", ``)
+	controller.Ctx.Request.Form = map[string][]string{"q": {""}}
+	controller.SearchVideos()
+	assert.Equal(t, status.BadRequest, controller.Data["json"].(*SearchVideosResponse).Code)
+}
+
+func TestVideoController_DeleteVideo(t *testing.T) {
+	vRepo := &MockVideoRepo{}
+	vRepo.Add(context.Background(),
+		&entity.Video{UserID: userID(1)},
+		&entity.Video{UserID: userID(2)},
+	)
+	broker := pubsubimpl.NewMemoryBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := broker.Subscribe(ctx, ws.BroadcastChannel)
+	require.NoError(t, err)
+	deleteVideo := func(id string, role string) int {
+		controller := newVideoController(t, "DELETE", ``)
+		controller.VRepo = vRepo
+		controller.Broker = broker
+		controller.Ctx.Input.SetParam(":id", id)
+		controller.Ctx.Input.SetData(constant.ContextRole, role)
+		controller.DeleteVideo()
+		return controller.Data["json"].(*Response).Code
+	}
+
+	// Users can only delete their own shares.
+	assert.Equal(t, status.Forbidden, deleteVideo("2", entity.RoleUser))
+	assert.Equal(t, status.OK, deleteVideo("1", entity.RoleUser))
+	assert.Equal(t, EventVideoDeleted, nextEvent(t, events, &VideoDeleted{}))
+	assert.Equal(t, status.NotFound, deleteVideo("1", entity.RoleUser))
+
+	// Moderators can delete any video.
+	assert.Equal(t, status.OK, deleteVideo("2", entity.RoleAdmin))
+	assert.Empty(t, vRepo.videos)
+}
+
+func TestVideoController_GetHiddenVideo(t *testing.T) {
+	now := time.Now()
+	vRepo := &MockVideoRepo{}
+	vRepo.Add(context.Background(), &entity.Video{HiddenAt: &now})
+	getVideo := func(role string) *GetVideoResponse {
+		controller := newVideoController(t, "GET", ``)
+		controller.VRepo = vRepo
+		controller.Ctx.Input.SetParam(":id", "1")
+		controller.Ctx.Input.SetData(constant.ContextRole, role)
+		controller.GetVideo()
+		return controller.Data["json"].(*GetVideoResponse)
+	}
+
+	assert.Equal(t, status.NotFound, getVideo(entity.RoleUser).Code)
+	resp := getVideo(entity.RoleAdmin)
+	assert.Equal(t, status.OK, resp.Code)
+	assert.NotNil(t, resp.HiddenAt)
+}
+
+// nextEvent returns the type of the next event published to the subscribers of
+// TopicVideos, and decodes its payload.
+func nextEvent(t *testing.T, ch <-chan []byte, payload interface{}) string {
+	select {
+	case data := <-ch:
+		var msg ws.Message
+		require.NoError(t, json.Unmarshal(data, &msg))
+		assert.Empty(t, msg.To)
+		assert.Contains(t, msg.Topics, ws.TopicVideos)
+		require.NoError(t, json.Unmarshal(msg.Payload, payload))
+		return msg.Type
+	case <-time.After(time.Second):
+		t.Fatal("event not published")
+		return ""
+	}
+}
+
+func TestVideoController_UpdateVideo(t *testing.T) {
+	vRepo := &MockVideoRepo{}
+	vRepo.Add(context.Background(),
+		&entity.Video{UserID: userID(1), Description: "old"},
+		&entity.Video{UserID: userID(2), Description: "old"},
+	)
+	updateVideo := func(id string, body string) (*UpdateVideoResponse, <-chan []byte) {
+		controller := newVideoController(t, "PATCH", body)
+		controller.VRepo = vRepo
+		controller.Ctx.Input.SetParam(":id", id)
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		ch, err := controller.Broker.Subscribe(ctx, ws.BroadcastChannel)
+		require.NoError(t, err)
+		controller.UpdateVideo()
+		return controller.Data["json"].(*UpdateVideoResponse), ch
+	}
+
+	resp, ch := updateVideo("1", `{"description":"new"}`)
+	assert.Equal(t, status.OK, resp.Code)
+	assert.Equal(t, "new", resp.Video.Description)
+	var video Video
+	assert.Equal(t, EventVideoUpdated, nextEvent(t, ch, &video))
+	assert.Equal(t, uint(1), video.ID)
+	assert.Equal(t, "new", video.Description)
+
+	// The author in the body is ignored, ownership comes from the token.
+	resp, _ = updateVideo("2", `{"description":"new","author":{"id":1}}`)
+	assert.Equal(t, status.Forbidden, resp.Code)
+	assert.Equal(t, "old", vRepo.videos[1].Description)
+
+	resp, _ = updateVideo("3", `{"description":"new"}`)
+	assert.Equal(t, status.NotFound, resp.Code)
+	resp, _ = updateVideo("1", `{"description":`+strconv.Quote(strings.Repeat("a", 1001))+`}`)
+	assert.Equal(t, status.BadRequest, resp.Code)
+
+	// Videos hidden by a moderator are not found by their sharer, and nothing is pushed.
+	now := time.Now()
+	vRepo.videos[0].HiddenAt = &now
+	resp, ch = updateVideo("1", `{"description":"newer"}`)
+	assert.Equal(t, status.NotFound, resp.Code)
+	assert.Equal(t, "new", vRepo.videos[0].Description)
+	select {
+	case <-ch:
+		t.Fatal("hidden video published")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestVideoController_GetFeed(t *testing.T) {
+	now := time.Now()
+	fRepo := &MockFollowRepo{}
+	fRepo.Add(context.Background(), &entity.Follow{FollowerID: 1, FolloweeID: 2})
+	vRepo := &MockVideoRepo{follows: fRepo}
+	vRepo.Add(context.Background(),
+		&entity.Video{UserID: userID(2), CreatedAt: now.Add(-3 * time.Minute)},
+		&entity.Video{UserID: userID(3), CreatedAt: now.Add(-2 * time.Minute)},
+		&entity.Video{UserID: userID(2), CreatedAt: now.Add(-time.Minute)},
+		&entity.Video{UserID: userID(1), CreatedAt: now},
+		&entity.Video{CreatedAt: now},
+	)
+	getFeed := func(form url.Values) *ListVideosResponse {
+		controller := newVideoController(t, "GET", ``)
+		controller.VRepo = vRepo
+		controller.Ctx.Request.Form = form
+		controller.GetFeed()
+		return controller.Data["json"].(*ListVideosResponse)
+	}
+
+	resp := getFeed(url.Values{"limit": {"1"}})
+	require.Equal(t, status.OK, resp.Code)
+	require.Len(t, resp.Items, 1)
+	assert.Equal(t, uint(3), resp.Items[0].ID)
+	require.NotEmpty(t, resp.NextCursor)
+
+	resp = getFeed(url.Values{"limit": {"1"}, "cursor": {resp.NextCursor}})
+	require.Equal(t, status.OK, resp.Code)
+	require.Len(t, resp.Items, 1)
+	assert.Equal(t, uint(1), resp.Items[0].ID)
+	assert.Empty(t, resp.NextCursor)
+
+	resp = getFeed(url.Values{})
+	assert.Equal(t, defaultFeedLimit, resp.Limit)
+	assert.Len(t, resp.Items, 2)
+
+	assert.Equal(t, status.BadRequest, getFeed(url.Values{"cursor": {"bad"}}).Code)
+}
+
+func TestVideoController_CreateVideoNotifiesFollowers(t *testing.T) {
+	controller := newVideoController(t, "POST", `{"url":"https://www.youtube.com/watch?v=dQw4w9WgXcQ"}`)
+	fRepo := &MockFollowRepo{}
+	fRepo.Add(context.Background(), &entity.Follow{FollowerID: 2, FolloweeID: 1})
+	fRepo.Add(context.Background(), &entity.Follow{FollowerID: 3, FolloweeID: 1})
+	fRepo.Add(context.Background(), &entity.Follow{FollowerID: 1, FolloweeID: 4})
+	controller.FRepo = fRepo
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := controller.Broker.Subscribe(ctx, ws.BroadcastChannel)
+	require.NoError(t, err)
+
+	controller.CreateVideo()
+	require.Equal(t, status.Created, controller.Data["json"].(*CreateVideoResponse).Code)
+
+	select {
+	case data := <-ch:
+		var msg ws.Message
+		require.NoError(t, json.Unmarshal(data, &msg))
+		assert.Empty(t, msg.To)
+		assert.Equal(t, uint(1), msg.FollowersOf)
+		assert.Equal(t, []string{"user:1"}, msg.Topics)
+		assert.Equal(t, uint(1), msg.Except)
+		assert.Equal(t, EventVideoCreated, msg.Type)
+		assert.True(t, msg.LegacyBare)
+	case <-time.After(time.Second):
+		t.Fatal("video not published")
+	}
+
+	// Then a single notification for all of them, once it is in their inbox.
+	select {
+	case data := <-ch:
+		var msg ws.Message
+		require.NoError(t, json.Unmarshal(data, &msg))
+		assert.Equal(t, EventNotification, msg.Type)
+		assert.Empty(t, msg.To)
+		assert.Equal(t, uint(1), msg.FollowersOf)
+		assert.Equal(t, uint(1), msg.Except)
+		var notification Notification
+		require.NoError(t, json.Unmarshal(msg.Payload, &notification))
+		assert.Zero(t, notification.ID)
+		assert.Equal(t, entity.NotificationVideoShared, notification.Kind)
+		assert.Equal(t, uint(1), notification.Actor.ID)
+	case <-time.After(time.Second):
+		t.Fatal("notification not published")
+	}
+	nRepo := controller.NRepo.(*MockNotificationRepo)
+	assert.Equal(t, []string{entity.NotificationVideoShared}, nRepo.kinds(2))
+	assert.Equal(t, []string{entity.NotificationVideoShared}, nRepo.kinds(3))
+	select {
+	case <-ch:
+		t.Fatal("notification published twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The subscribers of the sharer are notified even when they have no follower.
+	controller = newVideoController(t, "POST", `{"url":"https://www.youtube.com/watch?v=9bZkp7q19f0"}`)
+	ch, err = controller.Broker.Subscribe(ctx, ws.BroadcastChannel)
+	require.NoError(t, err)
+	controller.CreateVideo()
+	assert.Equal(t, status.Created, controller.Data["json"].(*CreateVideoResponse).Code)
+	select {
+	case data := <-ch:
+		var msg ws.Message
+		require.NoError(t, json.Unmarshal(data, &msg))
+		assert.Empty(t, msg.To)
+		assert.Equal(t, []string{"user:1"}, msg.Topics)
+	case <-time.After(time.Second):
+		t.Fatal("video not published")
+	}
+}
+
+// readFrame returns the next Server-Sent Event or comment of r, without its blank line.
+func readFrame(t *testing.T, r *bufio.Reader) string {
+	var frame strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		if line == "\n" {
+			return frame.String()
+		}
+		frame.WriteString(line)
+	}
+}
+
+func TestVideoController_StreamVideos(t *testing.T) {
+	defer func(period time.Duration) { keepAlivePeriod = period }(keepAlivePeriod)
+	keepAlivePeriod = 50 * time.Millisecond
+
+	nRepo := &MockNotificationRepo{}
+	for i := 0; i < 3; i++ {
+		nRepo.Add(context.Background(), &entity.Notification{UserID: 1, Kind: entity.NotificationVideoShared})
+	}
+	hub := ws.NewHub(slog.Default())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		controller := &VideoController{NRepo: nRepo, Hub: hub}
+		controller.Ctx = &beegoctx.Context{
+			Input:          beegoctx.NewInput(),
+			Output:         beegoctx.NewOutput(),
+			Request:        r,
+			ResponseWriter: &beegoctx.Response{ResponseWriter: w},
+		}
+		controller.Ctx.Input.Context = controller.Ctx
+		controller.Ctx.Input.SetData(constant.ContextUID, uint(1))
+		controller.StreamVideos()
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	r := bufio.NewReader(resp.Body)
+
+	// The notifications newer than Last-Event-ID are replayed first.
+	for _, id := range []string{"2", "3"} {
+		frame := readFrame(t, r)
+		assert.True(t, strings.HasPrefix(frame, "id: "+id+"\nevent: "+EventNotification+"\ndata: {"), frame)
+	}
+
+	// Then the events published to the user, between keep-alive comments.
+	hub.Deliver(&ws.Message{Audience: ws.Audience{Topics: []string{ws.TopicVideos}}, Type: EventVideoDeleted, Payload: json.RawMessage(`{"id":1}`)})
+	frame := readFrame(t, r)
+	for frame == ": keep-alive\n" {
+		frame = readFrame(t, r)
+	}
+	assert.Equal(t, "event: video.deleted\ndata: {\"id\":1}\n", frame)
+	assert.Equal(t, ": keep-alive\n", readFrame(t, r))
+
+	// The client is unregistered once the request is done.
+	resp.Body.Close()
+	assert.Eventually(t, func() bool { return hub.Len() == 0 }, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"funny-project-be/domain/repo"
+)
+
+// maxVideoSortKeys bounds the number of keys of a sort expression.
+const maxVideoSortKeys = 3
+
+// videoSortFields maps the sort fields of the API to the columns they sort by.
+var videoSortFields = map[string]repo.VideoSortColumn{
+	"id":              repo.VideoSortID,
+	"createdAt":       repo.VideoSortCreatedAt,
+	"title":           repo.VideoSortTitle,
+	"durationSeconds": repo.VideoSortDurationSeconds,
+}
+
+// Filters of ListVideos, passed as filter[name]=value.
+const (
+	filterAuthorID      = "authorId"
+	filterCreatedAfter  = "createdAfter"
+	filterCreatedBefore = "createdBefore"
+)
+
+// parseVideoSort parses a sort expression such as -createdAt,id where a leading
+// minus sorts in descending order. Only the fields of videoSortFields are accepted.
+func parseVideoSort(expr string) ([]repo.VideoSort, error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	keys := strings.Split(expr, ",")
+	if len(keys) > maxVideoSortKeys {
+		return nil, fmt.Errorf("sort accepts at most %d fields", maxVideoSortKeys)
+	}
+
+	sorts := make([]repo.VideoSort, 0, len(keys))
+	seen := map[string]bool{}
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		desc := strings.HasPrefix(key, "-")
+		field := strings.TrimPrefix(key, "-")
+
+		column, ok := videoSortFields[field]
+		if !ok {
+			return nil, fmt.Errorf("can not sort by %q", field)
+		}
+		if seen[field] {
+			return nil, fmt.Errorf("sort field %q is repeated", field)
+		}
+		seen[field] = true
+
+		sorts = append(sorts, repo.VideoSort{Column: column, Desc: desc})
+	}
+
+	return sorts, nil
+}
+
+// parseVideoFilter parses the filter[name] parameters of form.
+// Dates are RFC 3339 timestamps, e.g. filter[createdAfter]=2024-03-01T00:00:00Z.
+func parseVideoFilter(form url.Values) (repo.VideoFilter, error) {
+	var filter repo.VideoFilter
+
+	for key, values := range form {
+		if !strings.HasPrefix(key, "filter[") || !strings.HasSuffix(key, "]") {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(key, "filter["), "]")
+		if len(values) != 1 {
+			return filter, fmt.Errorf("filter %q must be given once", name)
+		}
+		value := values[0]
+
+		var err error
+		switch name {
+		case filterAuthorID:
+			var id uint64
+			if id, err = strconv.ParseUint(value, 10, 64); err != nil || id == 0 {
+				return filter, fmt.Errorf("filter %q must be a user id", name)
+			}
+			filter.UserID = uint(id)
+		case filterCreatedAfter:
+			filter.CreatedAfter, err = time.Parse(time.RFC3339, value)
+		case filterCreatedBefore:
+			filter.CreatedBefore, err = time.Parse(time.RFC3339, value)
+		default:
+			return filter, fmt.Errorf("can not filter by %q", name)
+		}
+		if err != nil {
+			return filter, fmt.Errorf("filter %q must be an RFC 3339 time", name)
+		}
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"funny-project-be/domain/repo"
+)
+
+func TestParseVideoSort(t *testing.T) {
+	sorts, err := parseVideoSort("-createdAt,id")
+	assert.NoError(t, err)
+	assert.Equal(t, []repo.VideoSort{
+		{Column: repo.VideoSortCreatedAt, Desc: true},
+		{Column: repo.VideoSortID},
+	}, sorts)
+
+	sorts, err = parseVideoSort("")
+	assert.NoError(t, err)
+	assert.Empty(t, sorts)
+
+	invalid := []string{
+		"shared_by",
+		"id; DROP TABLE video",
+		"created_at desc",
+		"id,-id",
+		"--id",
+		"id,title,createdAt,durationSeconds",
+		",",
+	}
+	for _, expr := range invalid {
+		_, err := parseVideoSort(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestParseVideoFilter(t *testing.T) {
+	filter, err := parseVideoFilter(url.Values{
+		"filter[authorId]":      {"42"},
+		"filter[createdAfter]":  {"2024-03-01T00:00:00Z"},
+		"filter[createdBefore]": {"2024-04-01T00:00:00+07:00"},
+		"limit":                 {"10"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(42), filter.UserID)
+	assert.True(t, filter.CreatedAfter.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, filter.CreatedBefore.Equal(time.Date(2024, 3, 31, 17, 0, 0, 0, time.UTC)))
+
+	invalid := []url.Values{
+		{"filter[url]": {"x"}},
+		{"filter[createdAfter]": {"yesterday"}},
+		{"filter[authorId]": {"1", "2"}},
+		{"filter[authorId]": {"test@example.com"}},
+		{"filter[authorId]": {"0"}},
+	}
+	for _, form := range invalid {
+		_, err := parseVideoFilter(form)
+		assert.Error(t, err, form)
+	}
+}
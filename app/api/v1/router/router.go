@@ -1,42 +1,142 @@
 package router
 
 import (
+	"log/slog"
+
 	"github.com/beego/beego"
 
 	"funny-project-be/app/api/v1/controller"
+	"funny-project-be/domain/metadata"
+	"funny-project-be/domain/oauth"
+	"funny-project-be/domain/pubsub"
+	"funny-project-be/domain/ratelimit"
 	"funny-project-be/domain/repo"
+	"funny-project-be/infra/beego/plugin/authn"
+	"funny-project-be/infra/beego/plugin/authz"
+	"funny-project-be/infra/beego/plugin/reqlog"
+	"funny-project-be/infra/beego/plugin/throttle"
 	"funny-project-be/infra/options"
+	"funny-project-be/infra/ws"
 )
 
 // InitRouters initializes router of beego.
 func InitRouters(
 	uRepo repo.UserRepo,
+	uiRepo repo.UserIdentityRepo,
+	asRepo repo.AuthStateRepo,
 	vRepo repo.VideoRepo,
+	rRepo repo.ReactionRepo,
+	cRepo repo.CommentRepo,
+	fRepo repo.FollowRepo,
+	nRepo repo.NotificationRepo,
+	rtRepo repo.RefreshTokenRepo,
+	revRepo repo.RevokedTokenRepo,
+	hub *ws.Hub,
+	broker pubsub.Broker,
+	limiter ratelimit.Store,
+	resolver metadata.Resolver,
+	providers map[string]oauth.IdentityProvider,
+	keys *authn.KeySet,
+	log *slog.Logger,
 	opts options.Options,
 ) {
+	// The route of a request is logged even when a filter below rejects it.
+	beego.InsertFilter("*", beego.BeforeExec, reqlog.Route())
+
+	// Every authenticated route declares the permissions it requires.
+	const rest = "/funny-project/v1/rest"
+	authz.Register(
+		authz.Rule{Method: "GET", Pattern: rest + "/users/me", Permissions: []authz.Permission{authz.VideoRead}},
+		authz.Rule{Method: "PATCH", Pattern: rest + "/users/me", Permissions: []authz.Permission{authz.ProfileWrite}},
+		authz.Rule{Method: "GET", Pattern: rest + "/users/:id", Permissions: []authz.Permission{authz.VideoRead}},
+		authz.Rule{Pattern: rest + "/users/:id/follow", Permissions: []authz.Permission{authz.UserFollow}},
+		authz.Rule{Pattern: rest + "/notifications", Permissions: []authz.Permission{authz.VideoRead}},
+		authz.Rule{Pattern: rest + "/notifications/*", Permissions: []authz.Permission{authz.VideoRead}},
+		authz.Rule{Method: "GET", Pattern: rest + "/feed", Permissions: []authz.Permission{authz.VideoRead, authz.UserFollow}},
+		authz.Rule{Method: "GET", Pattern: rest + "/videos", Permissions: []authz.Permission{authz.VideoRead}},
+		authz.Rule{Method: "POST", Pattern: rest + "/videos", Permissions: []authz.Permission{authz.VideoShare}},
+		authz.Rule{Method: "GET", Pattern: rest + "/videos/*", Permissions: []authz.Permission{authz.VideoRead}},
+		authz.Rule{Method: "PATCH", Pattern: rest + "/videos/:id", Permissions: []authz.Permission{authz.VideoShare}},
+		authz.Rule{Method: "DELETE", Pattern: rest + "/videos/:id", Permissions: []authz.Permission{authz.VideoDelete}},
+		authz.Rule{Pattern: rest + "/videos/:id/reaction", Permissions: []authz.Permission{authz.VideoReact}},
+		authz.Rule{Method: "POST", Pattern: rest + "/videos/:id/comments", Permissions: []authz.Permission{authz.CommentWrite}},
+		authz.Rule{Method: "PATCH", Pattern: rest + "/videos/:id/comments/:commentId", Permissions: []authz.Permission{authz.CommentWrite}},
+		authz.Rule{Method: "DELETE", Pattern: rest + "/videos/:id/comments/:commentId", Permissions: []authz.Permission{authz.CommentWrite}},
+		authz.Rule{Pattern: rest + "/admin/users/*", Permissions: []authz.Permission{authz.UserModerate}},
+		authz.Rule{Pattern: rest + "/admin/users", Permissions: []authz.Permission{authz.UserModerate}},
+		authz.Rule{Pattern: rest + "/admin/videos/*", Permissions: []authz.Permission{authz.VideoModerate}},
+		authz.Rule{Method: "GET", Pattern: "/funny-project/v1/sse/videos", Permissions: []authz.Permission{authz.VideoRead}},
+	)
+
+	// Shares are broadcast to the followers, logins can be brute-forced and each
+	// join holds a connection, so they are limited per user or client IP.
+	throttle.Register(limiter,
+		throttle.Rule{Name: "share", Method: "POST", Pattern: rest + "/videos", Limit: opts.RateLimitShare},
+		throttle.Rule{Name: "login", Method: "POST", Pattern: "/funny-project/v1/rpc/auth/login", Limit: opts.RateLimitLogin},
+		throttle.Rule{Name: "join", Method: "GET", Pattern: "/funny-project/v1/ws/videos/join", Limit: opts.RateLimitJoin},
+		throttle.Rule{Name: "join", Method: "GET", Pattern: "/funny-project/v1/sse/videos", Limit: opts.RateLimitJoin},
+	)
+
+	base := controller.BaseController{Log: log}
+	beego.Router("/.well-known/jwks.json", &controller.KeyController{BaseController: base, Keys: keys}, "get:GetJWKS")
+
 	beego.AddNamespace(
 		beego.NewNamespace("/funny-project",
 			beego.NSNamespace("/v1",
 				beego.NSNamespace("/rest",
 					beego.NSNamespace("/users",
-						beego.NSRouter("/me", &controller.UserController{BaseController: controller.BaseController{}, URepo: uRepo, Opts: opts}, "get:GetUser"),
+						beego.NSRouter("/me", &controller.UserController{BaseController: base, URepo: uRepo, Opts: opts}, "get:GetUser"),
+						beego.NSRouter("/me", &controller.UserController{BaseController: base, URepo: uRepo, Opts: opts}, "patch:UpdateUser"),
+						beego.NSRouter("/:id", &controller.UserController{BaseController: base, URepo: uRepo, VRepo: vRepo, FRepo: fRepo, Opts: opts}, "get:GetProfile"),
+						beego.NSRouter("/:id/follow", &controller.UserController{BaseController: base, URepo: uRepo, FRepo: fRepo, Opts: opts}, "post:Follow"),
+						beego.NSRouter("/:id/follow", &controller.UserController{BaseController: base, URepo: uRepo, FRepo: fRepo, Opts: opts}, "delete:Unfollow"),
+					),
+					beego.NSNamespace("/notifications",
+						beego.NSRouter("/read", &controller.NotificationController{BaseController: base, NRepo: nRepo, Opts: opts}, "post:MarkNotificationsRead"),
+						beego.NSRouter("", &controller.NotificationController{BaseController: base, NRepo: nRepo, Opts: opts}, "get:ListNotifications"),
+					),
+					beego.NSNamespace("/feed",
+						beego.NSRouter("", &controller.VideoController{BaseController: base, VRepo: vRepo, URepo: uRepo, RRepo: rRepo, Opts: opts}, "get:GetFeed"),
 					),
 					beego.NSNamespace("/videos",
-						beego.NSRouter("/:id", &controller.VideoController{BaseController: controller.BaseController{}, VRepo: vRepo, URepo: uRepo, Opts: opts}, "get:GetVideo"),
-						beego.NSRouter("", &controller.VideoController{BaseController: controller.BaseController{}, VRepo: vRepo, URepo: uRepo, Opts: opts}, "post:CreateVideo"),
-						beego.NSRouter("", &controller.VideoController{BaseController: controller.BaseController{}, VRepo: vRepo, URepo: uRepo, Opts: opts}, "get:ListVideos"),
+						beego.NSRouter("/search", &controller.VideoController{BaseController: base, VRepo: vRepo, URepo: uRepo, RRepo: rRepo, Opts: opts}, "get:SearchVideos"),
+						beego.NSRouter("/:id", &controller.VideoController{BaseController: base, VRepo: vRepo, URepo: uRepo, RRepo: rRepo, Opts: opts}, "get:GetVideo"),
+						beego.NSRouter("/:id", &controller.VideoController{BaseController: base, VRepo: vRepo, URepo: uRepo, Broker: broker, Opts: opts}, "patch:UpdateVideo"),
+						beego.NSRouter("/:id", &controller.VideoController{BaseController: base, VRepo: vRepo, URepo: uRepo, Broker: broker, Opts: opts}, "delete:DeleteVideo"),
+						beego.NSRouter("/:id/reaction", &controller.ReactionController{BaseController: base, RRepo: rRepo, VRepo: vRepo, NRepo: nRepo, Broker: broker, Opts: opts}, "put:PutReaction"),
+						beego.NSRouter("/:id/reaction", &controller.ReactionController{BaseController: base, RRepo: rRepo, VRepo: vRepo, Broker: broker, Opts: opts}, "delete:DeleteReaction"),
+						beego.NSRouter("/:id/comments", &controller.CommentController{BaseController: base, CRepo: cRepo, VRepo: vRepo, NRepo: nRepo, Broker: broker, Opts: opts}, "post:CreateComment"),
+						beego.NSRouter("/:id/comments", &controller.CommentController{BaseController: base, CRepo: cRepo, VRepo: vRepo, Broker: broker, Opts: opts}, "get:ListComments"),
+						beego.NSRouter("/:id/comments/:commentId", &controller.CommentController{BaseController: base, CRepo: cRepo, VRepo: vRepo, Broker: broker, Opts: opts}, "patch:UpdateComment"),
+						beego.NSRouter("/:id/comments/:commentId", &controller.CommentController{BaseController: base, CRepo: cRepo, VRepo: vRepo, Broker: broker, Opts: opts}, "delete:DeleteComment"),
+						beego.NSRouter("", &controller.VideoController{BaseController: base, VRepo: vRepo, URepo: uRepo, FRepo: fRepo, NRepo: nRepo, Broker: broker, Resolver: resolver, Opts: opts}, "post:CreateVideo"),
+						beego.NSRouter("", &controller.VideoController{BaseController: base, VRepo: vRepo, URepo: uRepo, RRepo: rRepo, Opts: opts}, "get:ListVideos"),
+					),
+					beego.NSNamespace("/admin",
+						beego.NSRouter("/users", &controller.AdminController{BaseController: base, URepo: uRepo, Opts: opts}, "get:ListUsers"),
+						beego.NSRouter("/users/:id/ban", &controller.AdminController{BaseController: base, URepo: uRepo, RTRepo: rtRepo, Opts: opts}, "put:BanUser"),
+						beego.NSRouter("/users/:id/ban", &controller.AdminController{BaseController: base, URepo: uRepo, Opts: opts}, "delete:UnbanUser"),
+						beego.NSRouter("/videos/:id/hidden", &controller.AdminController{BaseController: base, VRepo: vRepo, Broker: broker, Opts: opts}, "put:HideVideo"),
+						beego.NSRouter("/videos/:id/hidden", &controller.AdminController{BaseController: base, VRepo: vRepo, Broker: broker, Opts: opts}, "delete:UnhideVideo"),
 					),
 				),
 
 				beego.NSNamespace("/rpc",
 					beego.NSNamespace("/auth",
-						beego.NSRouter("/login", &controller.UserController{BaseController: controller.BaseController{}, URepo: uRepo, Opts: opts}, "post:Login"),
+						beego.NSRouter("/start", &controller.UserController{BaseController: base, ASRepo: asRepo, Providers: providers, Opts: opts}, "post:StartLogin"),
+						beego.NSRouter("/login", &controller.UserController{BaseController: base, URepo: uRepo, UIRepo: uiRepo, ASRepo: asRepo, RTRepo: rtRepo, Providers: providers, Keys: keys, Opts: opts}, "post:Login"),
+						beego.NSRouter("/refresh", &controller.UserController{BaseController: base, URepo: uRepo, RTRepo: rtRepo, Keys: keys, Opts: opts}, "post:Refresh"),
+						beego.NSRouter("/logout", &controller.UserController{BaseController: base, URepo: uRepo, RTRepo: rtRepo, RevRepo: revRepo, Keys: keys, Opts: opts}, "post:Logout"),
 					),
 				),
 
+				beego.NSNamespace("/sse",
+					beego.NSRouter("/videos", &controller.VideoController{BaseController: base, NRepo: nRepo, Hub: hub, Opts: opts}, "get:StreamVideos"),
+				),
+
 				beego.NSNamespace("/ws",
 					beego.NSNamespace("/videos",
-						beego.NSRouter("/join", &controller.VideoController{BaseController: controller.BaseController{}, VRepo: vRepo, URepo: uRepo, Opts: opts}, "get:JoinWebSocket"),
+						beego.NSRouter("/join", &controller.VideoController{BaseController: base, VRepo: vRepo, URepo: uRepo, NRepo: nRepo, RevRepo: revRepo, Hub: hub, Keys: keys, Opts: opts}, "get:JoinWebSocket"),
 					),
 				),
 			),
@@ -0,0 +1,126 @@
+// Command migrate applies and rolls back the schema migrations of
+// infra/repo/migration. Run it from the infra directory, like the service.
+//
+//	migrate up            apply the pending migrations
+//	migrate down [n]      roll back the last n migrations, 1 by default
+//	migrate status        list the migrations and when they were applied
+//	migrate create <name> write the files of a new migration
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/beego/beego"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"funny-project-be/infra/options"
+	"funny-project-be/infra/repo/migration"
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "usage: migrate [-dir dir] up | down [n] | status | create <name>\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	dir := flag.String("dir", "repo/migration/sql", "directory of the migration files, used by create")
+	flag.Usage = usage
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	// Create only writes files, the binary has to be rebuilt to embed them.
+	if args[0] == "create" {
+		if len(args) != 2 {
+			usage()
+			os.Exit(2)
+		}
+		paths, err := migration.Create(*dir, args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, path := range paths {
+			fmt.Println(path)
+		}
+		return
+	}
+
+	if err := beego.LoadAppConfig("ini", "config/app.conf"); err != nil {
+		log.Fatal(err)
+	}
+	opts, err := options.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := gorm.Open(postgres.Open(opts.DatabaseURL()), &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{SingularTable: true},
+		NowFunc:        func() time.Time { return time.Now().Local() },
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	migrator, err := migration.NewMigrator(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migration")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatalf("invalid number of migrations %q", args[1])
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		for _, m := range rolledBack {
+			fmt.Printf("rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			if s.Missing {
+				state += ", files missing"
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, state)
+		}
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"log"
+	"log/slog"
+	"os"
 	"time"
 
 	"github.com/beego/beego"
@@ -12,10 +14,20 @@ import (
 	"gorm.io/gorm/schema"
 
 	"funny-project-be/app/api/v1/router"
-	"funny-project-be/domain/entity"
+	"funny-project-be/domain/pubsub"
+	"funny-project-be/domain/ratelimit"
 	"funny-project-be/infra/beego/plugin/authn"
+	"funny-project-be/infra/beego/plugin/authz"
+	"funny-project-be/infra/beego/plugin/reqlog"
+	"funny-project-be/infra/logger"
+	"funny-project-be/infra/metadata/metadataimpl"
+	"funny-project-be/infra/oauth/oauthimpl"
 	"funny-project-be/infra/options"
+	"funny-project-be/infra/pubsub/pubsubimpl"
+	"funny-project-be/infra/ratelimit/ratelimitimpl"
+	"funny-project-be/infra/repo/migration"
 	"funny-project-be/infra/repo/repoimpl"
+	"funny-project-be/infra/ws"
 )
 
 func main() {
@@ -28,43 +40,95 @@ func main() {
 		log.Fatal(err)
 	}
 
-	var connectStr = fmt.Sprintf(
-		"postgresql://%s:%s@%s:%s/%s",
-		opts.DBUser,
-		opts.DBPass,
-		opts.DBHost,
-		opts.DBPort,
-		opts.DBName,
-	)
+	appLog, err := logger.New(os.Stderr, opts.LogFormat, opts.LogLevel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The plugins log through the default logger.
+	slog.SetDefault(appLog)
 
-	db, err := gorm.Open(postgres.Open(connectStr), &gorm.Config{
+	db, err := gorm.Open(postgres.Open(opts.DatabaseURL()), &gorm.Config{
 		NamingStrategy: schema.NamingStrategy{SingularTable: true},
 		NowFunc:        func() time.Time { return time.Now().Local() },
+		Logger:         logger.NewGormLogger(appLog),
+		// Unique violations are returned as gorm.ErrDuplicatedKey.
+		TranslateError: true,
 	})
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// Migrate the schema.
-	db.AutoMigrate(&entity.User{})
-	db.AutoMigrate(&entity.Video{})
+	// Migrate the schema. Replicas starting together wait for the first one.
+	migrator, err := migration.NewMigrator(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		log.Fatal(err)
+	}
 
 	uRepo := repoimpl.NewUserRepo(db)
+	uiRepo := repoimpl.NewUserIdentityRepo(db)
+	asRepo := repoimpl.NewAuthStateRepo(db)
 	vRepo := repoimpl.NewVideoRepo(db)
+	rRepo := repoimpl.NewReactionRepo(db)
+	cRepo := repoimpl.NewCommentRepo(db)
+	fRepo := repoimpl.NewFollowRepo(db)
+	nRepo := repoimpl.NewNotificationRepo(db)
+	rtRepo := repoimpl.NewRefreshTokenRepo(db)
+	revRepo := repoimpl.NewRevokedTokenRepo(db)
+
+	var broker pubsub.Broker
+	switch opts.PubSubBackend {
+	case "memory":
+		broker = pubsubimpl.NewMemoryBroker()
+	case "postgres":
+		broker = pubsubimpl.NewPostgresBroker(db, appLog)
+	default:
+		log.Fatalf("unknown pubsub backend %q", opts.PubSubBackend)
+	}
+
+	var limiter ratelimit.Store
+	switch opts.RateLimitBackend {
+	case "memory":
+		limiter = ratelimitimpl.NewMemoryStore()
+	case "postgres":
+		limiter = ratelimitimpl.NewPostgresStore(db, appLog)
+	default:
+		log.Fatalf("unknown rate limit backend %q", opts.RateLimitBackend)
+	}
+
+	// Every replica fans out the broker's notifications to its own WebSocket and event stream clients.
+	hub := ws.NewHub(appLog)
+	if err := hub.Relay(context.Background(), broker, fRepo); err != nil {
+		log.Fatal(err)
+	}
+
+	resolver := metadataimpl.NewYouTubeResolver(opts, appLog)
+	providers := oauthimpl.NewProviders(opts)
+
+	keys, err := authn.NewKeySet(opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	router.InitRouters(uRepo, uiRepo, asRepo, vRepo, rRepo, cRepo, fRepo, nRepo, rtRepo, revRepo, hub, broker, limiter, resolver, providers, keys, appLog, opts)
 
-	router.InitRouters(uRepo, vRepo, opts)
+	// The request ID comes first so that every filter logs with it.
+	beego.InsertFilter("*", beego.BeforeRouter, reqlog.RequestID())
 
 	// cors plugin
 	beego.InsertFilter("*", beego.BeforeRouter, cors.Allow(&cors.Options{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "OPTIONS", "DELETE"},
-		AllowHeaders:     []string{"Origin", "Authorization", "Access-Control-Allow-Origin", "Content-Type", "X-Token"},
-		ExposeHeaders:    []string{"Content-Length", "Access-Control-Allow-Origin", "Content-Type"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "OPTIONS", "DELETE"},
+		AllowHeaders:     []string{"Origin", "Authorization", "Access-Control-Allow-Origin", "Content-Type", "X-Token", "Last-Event-ID", reqlog.HeaderRequestID},
+		ExposeHeaders:    []string{"Content-Length", "Access-Control-Allow-Origin", "Content-Type", "Retry-After", reqlog.HeaderRequestID},
 		AllowCredentials: true,
 	}))
 
-	beego.InsertFilter("*", beego.BeforeRouter, authn.VerifyToken(opts))
+	beego.InsertFilter("*", beego.BeforeRouter, authn.VerifyToken(keys, revRepo))
+	beego.InsertFilter("*", beego.BeforeRouter, authz.RejectBanned(uRepo))
 	beego.BConfig.WebConfig.AutoRender = false
 
-	beego.Run()
+	beego.RunWithMiddleWares("", reqlog.AccessLog(appLog))
 }
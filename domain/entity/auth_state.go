@@ -0,0 +1,23 @@
+package entity
+
+import (
+	"time"
+)
+
+// AuthState model. A login started with the rpc/auth/start API, waiting for the
+// identity provider to redirect back with its state. It can only be used once.
+type AuthState struct {
+	State        string    `gorm:"primary_key;type:varchar(32);column:state"`
+	Provider     string    `gorm:"type:varchar(20);column:provider"`
+	RedirectURL  string    `gorm:"type:varchar(255);column:redirect_url"`
+	CodeVerifier string    `gorm:"type:varchar(128);column:code_verifier"`
+	Nonce        string    `gorm:"type:varchar(32);column:nonce"`
+	ExpiresAt    time.Time `gorm:"column:expires_at;index"`
+
+	CreatedAt time.Time `gorm:"column:created_at;autocreatetime"`
+}
+
+// TableName is the pluralized version of struct name
+func (AuthState) TableName() string {
+	return "auth_state"
+}
@@ -0,0 +1,25 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Comment model. A comment with a parent is a reply to that comment.
+type Comment struct {
+	ID       uint   `gorm:"primary_key;column:id;auto_increment:true"`
+	VideoID  uint   `gorm:"column:video_id;index"`
+	UserID   uint   `gorm:"column:user_id;index"`
+	ParentID *uint  `gorm:"column:parent_id;index"`
+	Body     string `gorm:"type:text;column:body"`
+
+	CreatedAt time.Time      `gorm:"column:created_at;autocreatetime"`
+	UpdatedAt time.Time      `gorm:"column:updated_at;autoupdatetime"`
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index"`
+}
+
+// TableName is the pluralized version of struct name
+func (Comment) TableName() string {
+	return "comment"
+}
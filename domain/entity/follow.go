@@ -0,0 +1,19 @@
+package entity
+
+import (
+	"time"
+)
+
+// Follow model. The follower is notified of the videos shared by the followee.
+type Follow struct {
+	ID         uint `gorm:"primary_key;column:id;auto_increment:true"`
+	FollowerID uint `gorm:"column:follower_id;uniqueIndex:idx_follow_follower_followee"`
+	FolloweeID uint `gorm:"column:followee_id;uniqueIndex:idx_follow_follower_followee;index"`
+
+	CreatedAt time.Time `gorm:"column:created_at;autocreatetime"`
+}
+
+// TableName is the pluralized version of struct name
+func (Follow) TableName() string {
+	return "follow"
+}
@@ -0,0 +1,41 @@
+package entity
+
+import (
+	"time"
+)
+
+// Notification kinds.
+const (
+	// NotificationVideoShared tells a follower that the actor shared a video.
+	NotificationVideoShared = "video.shared"
+	// NotificationCommentCreated tells a sharer that the actor commented on their video,
+	// or a commenter that the actor replied to their comment.
+	NotificationCommentCreated = "comment.created"
+	// NotificationReactionCreated tells a sharer that the actor reacted to their video.
+	NotificationReactionCreated = "reaction.created"
+)
+
+// Notification model. A notification belongs to the user it is sent to.
+type Notification struct {
+	ID      uint   `gorm:"primary_key;column:id;auto_increment:true"`
+	UserID  uint   `gorm:"column:user_id;index:idx_notification_user_id"`
+	ActorID uint   `gorm:"column:actor_id"`
+	Actor   *User  `gorm:"foreignKey:ActorID"`
+	Kind    string `gorm:"type:varchar(30);column:kind"`
+
+	VideoID   *uint `gorm:"column:video_id"`
+	CommentID *uint `gorm:"column:comment_id"`
+
+	// ReactionKind is the kind of reaction of NotificationReactionCreated.
+	ReactionKind string `gorm:"type:varchar(10);column:reaction_kind"`
+
+	// ReadAt is set once the user has read the notification.
+	ReadAt *time.Time `gorm:"column:read_at"`
+
+	CreatedAt time.Time `gorm:"column:created_at;autocreatetime"`
+}
+
+// TableName is the pluralized version of struct name
+func (Notification) TableName() string {
+	return "notification"
+}
@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"time"
+)
+
+// Reaction kinds.
+const (
+	ReactionLike    = "like"
+	ReactionDislike = "dislike"
+)
+
+// Reaction model. A user has at most one reaction per video.
+type Reaction struct {
+	ID      uint   `gorm:"primary_key;column:id;auto_increment:true"`
+	UserID  uint   `gorm:"column:user_id;uniqueIndex:idx_reaction_user_video"`
+	VideoID uint   `gorm:"column:video_id;uniqueIndex:idx_reaction_user_video;index"`
+	Kind    string `gorm:"type:varchar(10);column:kind"`
+
+	CreatedAt time.Time `gorm:"column:created_at;autocreatetime"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoupdatetime"`
+}
+
+// TableName is the pluralized version of struct name
+func (Reaction) TableName() string {
+	return "reaction"
+}
+
+// ReactionCount is the number of likes and dislikes of a video.
+type ReactionCount struct {
+	VideoID  uint
+	Likes    int64
+	Dislikes int64
+}
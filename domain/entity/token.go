@@ -0,0 +1,37 @@
+package entity
+
+import (
+	"time"
+)
+
+// RefreshToken model. Only the hash of a token is stored. Every refresh revokes
+// the token and issues a new one of the same family, so presenting a revoked
+// token again reveals that it was stolen.
+type RefreshToken struct {
+	ID        uint       `gorm:"primary_key;column:id;auto_increment:true"`
+	UserID    uint       `gorm:"column:user_id;index"`
+	FamilyID  string     `gorm:"type:varchar(32);column:family_id;index"`
+	TokenHash string     `gorm:"type:varchar(64);column:token_hash;uniqueIndex"`
+	ExpiresAt time.Time  `gorm:"column:expires_at"`
+	RevokedAt *time.Time `gorm:"column:revoked_at"`
+
+	CreatedAt time.Time `gorm:"column:created_at;autocreatetime"`
+}
+
+// TableName is the pluralized version of struct name
+func (RefreshToken) TableName() string {
+	return "refresh_token"
+}
+
+// RevokedToken model. An access token killed before it expires, identified by its jti claim.
+type RevokedToken struct {
+	JTI       string    `gorm:"primary_key;type:varchar(32);column:jti"`
+	ExpiresAt time.Time `gorm:"column:expires_at;index"`
+
+	CreatedAt time.Time `gorm:"column:created_at;autocreatetime"`
+}
+
+// TableName is the pluralized version of struct name
+func (RevokedToken) TableName() string {
+	return "revoked_token"
+}
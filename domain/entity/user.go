@@ -4,11 +4,27 @@ import (
 	"time"
 )
 
+// Roles of users.
+const (
+	// RoleUser can share, react to and comment on videos.
+	RoleUser = "user"
+	// RoleAdmin can also moderate videos and users.
+	RoleAdmin = "admin"
+)
+
 // User model.
 type User struct {
 	ID    uint   `gorm:"primary_key;column:id;auto_increment:true"`
 	Name  string `gorm:"type:varchar(100);column:name"`
 	Email string `gorm:"type:varchar(100);column:email;index:unique"`
+	Role  string `gorm:"type:varchar(20);column:role;not null;default:user"`
+
+	// Avatar is the picture of the first login until the user changes it.
+	Avatar string `gorm:"type:varchar(255);column:avatar"`
+	Bio    string `gorm:"type:varchar(500);column:bio"`
+
+	// BannedAt is set while the user is banned.
+	BannedAt *time.Time `gorm:"column:banned_at"`
 
 	CreatedAt time.Time `gorm:"column:created_at;autocreatetime"`
 	UpdatedAt time.Time `gorm:"column:updated_at;autoupdatetime"`
@@ -0,0 +1,21 @@
+package entity
+
+import (
+	"time"
+)
+
+// UserIdentity links an account of an identity provider to a user.
+type UserIdentity struct {
+	ID       uint   `gorm:"primary_key;column:id;auto_increment:true"`
+	UserID   uint   `gorm:"column:user_id;index"`
+	Provider string `gorm:"type:varchar(20);column:provider;uniqueIndex:idx_user_identity_provider_subject"`
+	Subject  string `gorm:"type:varchar(255);column:subject;uniqueIndex:idx_user_identity_provider_subject"`
+	Email    string `gorm:"type:varchar(100);column:email"`
+
+	CreatedAt time.Time `gorm:"column:created_at;autocreatetime"`
+}
+
+// TableName is the pluralized version of struct name
+func (UserIdentity) TableName() string {
+	return "user_identity"
+}
@@ -2,17 +2,32 @@ package entity
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // Video model.
 type Video struct {
-	ID          uint   `gorm:"primary_key;column:id;auto_increment:true"`
+	ID          uint   `gorm:"primary_key;column:id;auto_increment:true;index:idx_video_created_at_id,priority:2"`
 	URL         string `gorm:"type:varchar(200);column:url"`
-	SharedBy    string `gorm:"type:varchar(100);column:shared_by"`
+	YouTubeID   string `gorm:"type:varchar(11);column:youtube_id;uniqueIndex:idx_video_youtube_id,where:deleted_at IS NULL"`
 	Description string `gorm:"column:description"`
 
-	CreatedAt time.Time `gorm:"column:created_at;autocreatetime"`
-	UpdatedAt time.Time `gorm:"column:updated_at;autoupdatetime"`
+	// UserID is the user who shared the video, nil once that user is deleted.
+	UserID *uint `gorm:"column:user_id;index"`
+	User   *User `gorm:"foreignKey:UserID;constraint:OnDelete:SET NULL"`
+
+	Title           string `gorm:"type:varchar(255);column:title"`
+	ThumbnailURL    string `gorm:"type:varchar(255);column:thumbnail_url"`
+	ChannelName     string `gorm:"type:varchar(100);column:channel_name"`
+	DurationSeconds int    `gorm:"column:duration_seconds"`
+
+	// HiddenAt is set while a moderator hides the video.
+	HiddenAt *time.Time `gorm:"column:hidden_at"`
+
+	CreatedAt time.Time      `gorm:"column:created_at;autocreatetime;index:idx_video_created_at_id,priority:1"`
+	UpdatedAt time.Time      `gorm:"column:updated_at;autoupdatetime"`
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index"`
 }
 
 // TableName is the pluralized version of struct name
@@ -0,0 +1,23 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrVideoUnavailable is returned when a video does not exist or is private.
+var ErrVideoUnavailable = errors.New("video is unavailable")
+
+// Video holds the metadata of a video hosted by a video platform.
+type Video struct {
+	Title           string
+	ThumbnailURL    string
+	ChannelName     string
+	DurationSeconds int
+}
+
+// Resolver exposes methods of a video metadata resolver.
+type Resolver interface {
+	// Resolve fetches and returns the metadata of the video at url.
+	Resolve(ctx context.Context, url string) (*Video, error)
+}
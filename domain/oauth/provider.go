@@ -0,0 +1,44 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrNonceMismatch is returned when the id_token of a login was not issued for its nonce.
+var ErrNonceMismatch = errors.New("id_token nonce does not match")
+
+// ErrEmailNotVerified is returned when the provider has not verified the email of a user.
+var ErrEmailNotVerified = errors.New("email is not verified by the identity provider")
+
+// Names of the supported identity providers.
+const (
+	ProviderGoogle = "google"
+	ProviderGitHub = "github"
+	ProviderOIDC   = "oidc"
+)
+
+// Profile is the profile of a user authenticated by an identity provider.
+type Profile struct {
+	// Subject identifies the user within the provider.
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+// IdentityProvider exposes methods of an OAuth 2.0 identity provider.
+type IdentityProvider interface {
+	// AuthCodeURL returns the URL of the consent page, which redirects to redirectURL with state.
+	AuthCodeURL(ctx context.Context, state string, redirectURL string, opts ...oauth2.AuthCodeOption) (string, error)
+
+	// Exchange exchanges an authorization code for a token.
+	Exchange(ctx context.Context, code string, redirectURL string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error)
+
+	// Profile fetches the profile of the user owning token. Providers supporting
+	// OpenID Connect check that the id_token of token was issued for nonce.
+	Profile(ctx context.Context, token *oauth2.Token, nonce string) (*Profile, error)
+}
@@ -0,0 +1,23 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+)
+
+// MaxPayloadSize is the size in bytes of the largest payload a Broker publishes,
+// bounded by the 8000 bytes of a Postgres NOTIFY.
+const MaxPayloadSize = 7999
+
+// ErrPayloadTooLarge is returned by Publish for the payloads over MaxPayloadSize.
+var ErrPayloadTooLarge = errors.New("pubsub: payload is too large")
+
+// Broker exposes methods of a publish/subscribe backend shared by every replica.
+type Broker interface {
+	// Publish sends a payload to every subscriber of channel. Payloads over
+	// MaxPayloadSize are rejected with ErrPayloadTooLarge on every backend.
+	Publish(ctx context.Context, channel string, payload []byte) error
+
+	// Subscribe returns the payloads published to channel until ctx is done.
+	Subscribe(ctx context.Context, channel string) (<-chan []byte, error)
+}
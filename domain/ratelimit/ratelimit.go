@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Burst requests at once, its token bucket being refilled evenly
+// over Per. The zero Limit allows every request.
+type Limit struct {
+	Burst int
+	Per   time.Duration
+}
+
+// ParseLimit parses a limit written "<burst>/<per>", e.g. "10/1h". An empty
+// string or "0" is the zero Limit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+
+	burst, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("limit %q is not <burst>/<per>", s)
+	}
+	n, err := strconv.Atoi(burst)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("burst of limit %q must be a positive integer", s)
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("period of limit %q must be a positive duration", s)
+	}
+
+	return Limit{Burst: n, Per: d}, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler with ParseLimit.
+func (l *Limit) UnmarshalText(text []byte) error {
+	limit, err := ParseLimit(string(text))
+	if err != nil {
+		return err
+	}
+	*l = limit
+
+	return nil
+}
+
+// String returns the limit in the format of ParseLimit.
+func (l Limit) String() string {
+	if l.IsZero() {
+		return "0"
+	}
+
+	return strconv.Itoa(l.Burst) + "/" + l.Per.String()
+}
+
+// IsZero reports whether l allows every request.
+func (l Limit) IsZero() bool {
+	return l.Burst <= 0 || l.Per <= 0
+}
+
+// Bucket is the token bucket of a key.
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// NewBucket returns the full bucket of l at now. A bucket which is not stored is full.
+func (l Limit) NewBucket(now time.Time) Bucket {
+	return Bucket{Tokens: float64(l.Burst), UpdatedAt: now}
+}
+
+// Take refills b up to now and takes a token from it. When b is empty, it
+// reports false with the time to wait for the next token.
+func (l Limit) Take(b *Bucket, now time.Time) (bool, time.Duration) {
+	perToken := l.Per / time.Duration(l.Burst)
+	if elapsed := now.Sub(b.UpdatedAt); elapsed > 0 {
+		b.Tokens += float64(elapsed) / float64(perToken)
+		if b.Tokens > float64(l.Burst) {
+			b.Tokens = float64(l.Burst)
+		}
+	}
+	b.UpdatedAt = now
+
+	if b.Tokens < 1 {
+		return false, time.Duration((1 - b.Tokens) * float64(perToken))
+	}
+	b.Tokens--
+
+	return true, 0
+}
+
+// FullAt returns when b is full again, after which it needs not be stored.
+func (l Limit) FullAt(b Bucket) time.Time {
+	perToken := l.Per / time.Duration(l.Burst)
+
+	return b.UpdatedAt.Add(time.Duration((float64(l.Burst) - b.Tokens) * float64(perToken)))
+}
+
+// Store keeps the token buckets of the rate limiters.
+type Store interface {
+	// Take takes a token from the bucket of key for limit. When the bucket is
+	// empty, it reports false with the time to wait for the next token.
+	Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error)
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLimit(t *testing.T) {
+	for s, want := range map[string]Limit{
+		"":       {},
+		"0":      {},
+		"10/1h":  {Burst: 10, Per: time.Hour},
+		" 5/30s": {Burst: 5, Per: 30 * time.Second},
+	} {
+		limit, err := ParseLimit(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, want, limit, s)
+	}
+
+	for _, s := range []string{"10", "10/", "x/1h", "0/1h", "-1/1h", "10/1x", "10/0s"} {
+		_, err := ParseLimit(s)
+		assert.Error(t, err, s)
+	}
+
+	assert.Equal(t, "10/1h0m0s", Limit{Burst: 10, Per: time.Hour}.String())
+	assert.Equal(t, "0", Limit{}.String())
+}
+
+func TestLimit_Take(t *testing.T) {
+	limit := Limit{Burst: 3, Per: 3 * time.Minute}
+	now := time.Now()
+	b := limit.NewBucket(now)
+
+	for i := 0; i < 3; i++ {
+		allowed, _ := limit.Take(&b, now)
+		assert.True(t, allowed)
+	}
+	allowed, retryAfter := limit.Take(&b, now)
+	assert.False(t, allowed)
+	assert.Equal(t, time.Minute, retryAfter)
+	assert.Equal(t, now.Add(3*time.Minute), limit.FullAt(b))
+
+	// A token is refilled every minute, never above the burst.
+	allowed, retryAfter = limit.Take(&b, now.Add(30*time.Second))
+	assert.False(t, allowed)
+	assert.Equal(t, 30*time.Second, retryAfter)
+	allowed, _ = limit.Take(&b, now.Add(time.Minute))
+	assert.True(t, allowed)
+
+	limit.Take(&b, now.Add(time.Hour))
+	assert.Equal(t, 2.0, b.Tokens)
+}
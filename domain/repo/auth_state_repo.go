@@ -0,0 +1,20 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"funny-project-be/domain/entity"
+)
+
+// AuthStateRepo exposes methods of auth state's repository.
+type AuthStateRepo interface {
+	// Add adds new auth states to repo.
+	Add(ctx context.Context, states ...*entity.AuthState) error
+
+	// Take finds, removes and returns an auth state, so that it can not be used twice.
+	Take(ctx context.Context, state string) (*entity.AuthState, error)
+
+	// RemoveExpired removes the auth states which expired before t.
+	RemoveExpired(ctx context.Context, t time.Time) error
+}
@@ -0,0 +1,26 @@
+package repo
+
+import (
+	"context"
+
+	"funny-project-be/domain/entity"
+)
+
+// CommentRepo exposes methods of comment's repository.
+type CommentRepo interface {
+	// Get finds and returns a comment by id.
+	Get(ctx context.Context, id uint) (*entity.Comment, error)
+
+	// GetRangeByVideo finds and returns at most limit comments of a video
+	// with an id greater than afterID, oldest first.
+	GetRangeByVideo(ctx context.Context, videoID uint, afterID uint, limit int) ([]*entity.Comment, error)
+
+	// Add adds new comments to repo.
+	Add(ctx context.Context, comments ...*entity.Comment) error
+
+	// Remove removes comments from repo.
+	Remove(ctx context.Context, comments ...*entity.Comment) error
+
+	// Update updates comments in repo.
+	Update(ctx context.Context, comments ...*entity.Comment) error
+}
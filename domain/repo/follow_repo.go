@@ -0,0 +1,28 @@
+package repo
+
+import (
+	"context"
+
+	"funny-project-be/domain/entity"
+)
+
+// FollowRepo exposes methods of follow's repository.
+type FollowRepo interface {
+	// Get finds and returns the follow of followee by follower.
+	Get(ctx context.Context, followerID uint, followeeID uint) (*entity.Follow, error)
+
+	// GetFollowerIDs finds and returns the ids of the followers of a user.
+	GetFollowerIDs(ctx context.Context, followeeID uint) ([]uint, error)
+
+	// CountFollowers counts and returns the followers of a user.
+	CountFollowers(ctx context.Context, followeeID uint) (int64, error)
+
+	// CountFollowing counts and returns the users followed by a user.
+	CountFollowing(ctx context.Context, followerID uint) (int64, error)
+
+	// Add adds a follow, unless the follower already follows the followee.
+	Add(ctx context.Context, follow *entity.Follow) error
+
+	// Remove removes follows from repo.
+	Remove(ctx context.Context, follows ...*entity.Follow) error
+}
@@ -0,0 +1,24 @@
+package repo
+
+import (
+	"context"
+
+	"funny-project-be/domain/entity"
+)
+
+// NotificationRepo exposes methods of notification's repository.
+// Notifications are returned with their Actor.
+type NotificationRepo interface {
+	// GetRangeByUser finds and returns at most limit notifications of a user, newest first,
+	// with an id lower than beforeID and greater than afterID. Zero ids are not applied.
+	GetRangeByUser(ctx context.Context, userID uint, beforeID uint, afterID uint, limit int) ([]*entity.Notification, error)
+
+	// CountUnread counts and returns the notifications of a user which have not been read.
+	CountUnread(ctx context.Context, userID uint) (int64, error)
+
+	// MarkRead marks the notifications ids of a user as read, or all of them when ids is empty.
+	MarkRead(ctx context.Context, userID uint, ids []uint) error
+
+	// Add adds new notifications to repo.
+	Add(ctx context.Context, notifications ...*entity.Notification) error
+}
@@ -0,0 +1,25 @@
+package repo
+
+import (
+	"context"
+
+	"funny-project-be/domain/entity"
+)
+
+// ReactionRepo exposes methods of reaction's repository.
+type ReactionRepo interface {
+	// Get finds and returns the reaction of a user to a video.
+	Get(ctx context.Context, userID uint, videoID uint) (*entity.Reaction, error)
+
+	// GetByVideoIDs finds and returns the reactions of a user to the given videos.
+	GetByVideoIDs(ctx context.Context, userID uint, videoIDs []uint) ([]*entity.Reaction, error)
+
+	// CountByVideoIDs counts and returns likes and dislikes keyed by video id.
+	CountByVideoIDs(ctx context.Context, videoIDs []uint) (map[uint]*entity.ReactionCount, error)
+
+	// Upsert adds a reaction or replaces the kind of the existing one.
+	Upsert(ctx context.Context, reaction *entity.Reaction) error
+
+	// Remove removes reactions from repo.
+	Remove(ctx context.Context, reactions ...*entity.Reaction) error
+}
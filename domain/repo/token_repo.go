@@ -0,0 +1,34 @@
+package repo
+
+import (
+	"context"
+
+	"funny-project-be/domain/entity"
+)
+
+// RefreshTokenRepo exposes methods of refresh token's repository.
+type RefreshTokenRepo interface {
+	// GetOneByHash finds and returns a refresh token by the hash of its value.
+	GetOneByHash(ctx context.Context, hash string) (*entity.RefreshToken, error)
+
+	// Add adds new refresh tokens to repo.
+	Add(ctx context.Context, tokens ...*entity.RefreshToken) error
+
+	// Revoke revokes a refresh token by id. It returns false when the token was already revoked.
+	Revoke(ctx context.Context, id uint) (bool, error)
+
+	// RevokeFamily revokes every refresh token of a family.
+	RevokeFamily(ctx context.Context, familyID string) error
+
+	// RevokeByUser revokes every refresh token of a user.
+	RevokeByUser(ctx context.Context, userID uint) error
+}
+
+// RevokedTokenRepo exposes methods of revoked access token's repository.
+type RevokedTokenRepo interface {
+	// IsRevoked reports whether the access token with jti has been revoked.
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+
+	// Add adds new revoked tokens to repo.
+	Add(ctx context.Context, tokens ...*entity.RevokedToken) error
+}
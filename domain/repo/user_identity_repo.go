@@ -0,0 +1,16 @@
+package repo
+
+import (
+	"context"
+
+	"funny-project-be/domain/entity"
+)
+
+// UserIdentityRepo exposes methods of user identity's repository.
+type UserIdentityRepo interface {
+	// GetOneBySubject finds and returns the identity of subject at provider.
+	GetOneBySubject(ctx context.Context, provider string, subject string) (*entity.UserIdentity, error)
+
+	// Add adds new identities to repo.
+	Add(ctx context.Context, identities ...*entity.UserIdentity) error
+}
@@ -14,6 +14,12 @@ type UserRepo interface {
 	// GetOneByEmail finds and returns a user by email.
 	GetOneByEmail(ctx context.Context, email string) (*entity.User, error)
 
+	// GetRange finds and returns a range of users, oldest first.
+	GetRange(ctx context.Context, limit int, page int) ([]*entity.User, error)
+
+	// Count counts and returns the number of users.
+	Count(ctx context.Context) (int64, error)
+
 	// Add adds new users to repo.
 	Add(ctx context.Context, users ...*entity.User) error
 
@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"time"
+
+	"funny-project-be/domain/entity"
+)
+
+// VideoSortColumn is a column videos can be sorted by.
+type VideoSortColumn string
+
+// Columns videos can be sorted by.
+const (
+	VideoSortID              VideoSortColumn = "id"
+	VideoSortCreatedAt       VideoSortColumn = "created_at"
+	VideoSortTitle           VideoSortColumn = "title"
+	VideoSortDurationSeconds VideoSortColumn = "duration_seconds"
+)
+
+// VideoSort is one sort key of a VideoQuery.
+type VideoSort struct {
+	Column VideoSortColumn
+	Desc   bool
+}
+
+// VideoFilter restricts the videos of a query. Zero fields are not applied.
+type VideoFilter struct {
+	UserID uint
+	// FollowedBy keeps the videos shared by the users this user follows.
+	FollowedBy    uint
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// VideoQuery is a validated query over videos.
+type VideoQuery struct {
+	Filter VideoFilter
+	Sort   []VideoSort
+}
+
+// Markers around the matched terms of VideoSearchResult.Snippet.
+const (
+	HighlightStart = "\x01"
+	HighlightStop  = "\x02"
+)
+
+// VideoSearchResult is a video matching a full-text search.
+type VideoSearchResult struct {
+	Video *entity.Video
+	// Rank is the relevance of the video, higher is better.
+	Rank float64
+	// Snippet is an excerpt of the title and description with the matched
+	// terms between HighlightStart and HighlightStop.
+	Snippet string
+}
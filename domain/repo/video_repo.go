@@ -2,20 +2,35 @@ package repo
 
 import (
 	"context"
+	"time"
 
 	"funny-project-be/domain/entity"
 )
 
 // VideoRepo exposes methods of video's repository.
+// Videos are returned with the User who shared them.
+// Get and GetOneByYouTubeID also return hidden videos, the ranges leave them out.
+// Removed videos are soft-deleted and never returned.
 type VideoRepo interface {
 	// Get finds and returns a video by id.
 	Get(ctx context.Context, id uint) (*entity.Video, error)
 
-	// GetRangeByQuery finds and returns a range of videos.
-	GetRangeByQuery(ctx context.Context, sort string, limit int, page int) ([]*entity.Video, error)
+	// GetOneByYouTubeID finds and returns a video by its YouTube video id.
+	GetOneByYouTubeID(ctx context.Context, youtubeID string) (*entity.Video, error)
 
-	// Count counts and returns the number of videos.
-	Count(ctx context.Context) (int64, error)
+	// GetRangeByVideoQuery finds and returns a range of videos matching query.
+	GetRangeByVideoQuery(ctx context.Context, query VideoQuery, limit int, page int) ([]*entity.Video, error)
+
+	// GetRangeByCursor finds and returns at most limit videos matching filter, newest first,
+	// which come after the video created at createdAt with id. A zero id starts from the newest video.
+	GetRangeByCursor(ctx context.Context, filter VideoFilter, createdAt time.Time, id uint, limit int) ([]*entity.Video, error)
+
+	// SearchVideos finds and returns a range of videos matching the full-text search text,
+	// most relevant first, along with the total number of matching videos.
+	SearchVideos(ctx context.Context, text string, limit int, page int) ([]*VideoSearchResult, int64, error)
+
+	// Count counts and returns the number of videos matching filter.
+	Count(ctx context.Context, filter VideoFilter) (int64, error)
 
 	// Add adds new videos to repo.
 	Add(ctx context.Context, videos ...*entity.Video) error
@@ -0,0 +1,59 @@
+package youtube
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidURL is returned when a URL does not point to a YouTube video.
+var ErrInvalidURL = errors.New("url is not a youtube video")
+
+var idRe = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+
+// Path prefixes which are followed by the video id.
+var idPathPrefixes = []string{"/shorts/", "/embed/", "/v/", "/live/"}
+
+// ParseURL extracts the video id from the various forms of a YouTube video URL,
+// e.g. youtu.be/<id>, m.youtube.com/watch?v=<id>&t=42, /shorts/<id> and /embed/<id>.
+func ParseURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", ErrInvalidURL
+	}
+
+	var id string
+	switch host := strings.ToLower(u.Hostname()); host {
+	case "youtu.be", "www.youtu.be":
+		id = strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)[0]
+	case "youtube.com", "www.youtube.com", "m.youtube.com", "music.youtube.com",
+		"youtube-nocookie.com", "www.youtube-nocookie.com":
+		if u.Path == "/watch" {
+			id = u.Query().Get("v")
+			break
+		}
+		for _, prefix := range idPathPrefixes {
+			if strings.HasPrefix(u.Path, prefix) {
+				id = strings.SplitN(strings.TrimPrefix(u.Path, prefix), "/", 2)[0]
+				break
+			}
+		}
+	}
+
+	if !idRe.MatchString(id) {
+		return "", ErrInvalidURL
+	}
+
+	return id, nil
+}
+
+// CanonicalURL returns the canonical watch URL of the video id.
+func CanonicalURL(id string) string {
+	return "https://www.youtube.com/watch?v=" + id
+}
@@ -0,0 +1,57 @@
+package youtube
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURL(t *testing.T) {
+	valid := []string{
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+		"https://youtube.com/watch?v=dQw4w9WgXcQ",
+		"http://www.youtube.com/watch?v=dQw4w9WgXcQ",
+		"https://m.youtube.com/watch?v=dQw4w9WgXcQ",
+		"https://music.youtube.com/watch?v=dQw4w9WgXcQ",
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=42s",
+		"https://www.youtube.com/watch?feature=share&v=dQw4w9WgXcQ&list=PL123",
+		"https://youtu.be/dQw4w9WgXcQ",
+		"https://youtu.be/dQw4w9WgXcQ?t=42",
+		"https://www.youtube.com/shorts/dQw4w9WgXcQ",
+		"https://www.youtube.com/embed/dQw4w9WgXcQ?autoplay=1",
+		"https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ",
+		"https://www.youtube.com/v/dQw4w9WgXcQ",
+		"https://www.youtube.com/live/dQw4w9WgXcQ",
+		"www.youtube.com/watch?v=dQw4w9WgXcQ",
+		"youtu.be/dQw4w9WgXcQ",
+		"  https://WWW.YOUTUBE.COM/watch?v=dQw4w9WgXcQ  ",
+	}
+	for _, raw := range valid {
+		id, err := ParseURL(raw)
+		assert.NoError(t, err, raw)
+		assert.Equal(t, "dQw4w9WgXcQ", id, raw)
+	}
+
+	invalid := []string{
+		"",
+		"not a url",
+		"https://vimeo.com/123456",
+		"https://www.youtube.com/",
+		"https://www.youtube.com/watch",
+		"https://www.youtube.com/watch?v=short",
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ<script>",
+		"https://www.youtube.com/channel/UCuAXFkgsw1L7xaCfnd5JJOw",
+		"https://youtube.com.evil.com/watch?v=dQw4w9WgXcQ",
+		"https://evil.com/?u=https://youtu.be/dQw4w9WgXcQ",
+		"ftp://youtu.be/dQw4w9WgXcQ",
+		"javascript:alert(1)",
+	}
+	for _, raw := range invalid {
+		_, err := ParseURL(raw)
+		assert.ErrorIs(t, err, ErrInvalidURL, raw)
+	}
+}
+
+func TestCanonicalURL(t *testing.T) {
+	assert.Equal(t, "https://www.youtube.com/watch?v=dQw4w9WgXcQ", CanonicalURL("dQw4w9WgXcQ"))
+}
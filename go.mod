@@ -4,6 +4,7 @@ go 1.22.1
 
 require (
 	github.com/beego/beego v1.12.12
+	github.com/jackc/pgx/v5 v5.4.3
 	github.com/stretchr/testify v1.8.1
 )
 
@@ -14,7 +15,6 @@ require (
 	github.com/elazarl/go-bindata-assetfs v1.0.1 // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
-	github.com/jackc/pgx/v5 v5.4.3 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
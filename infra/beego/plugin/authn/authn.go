@@ -2,7 +2,7 @@ package authn
 
 import (
 	goctx "context"
-	"fmt"
+	"log/slog"
 	"strconv"
 	"strings"
 
@@ -10,19 +10,31 @@ import (
 	"github.com/beego/beego/context"
 	jwt "github.com/dgrijalva/jwt-go"
 
+	"funny-project-be/domain/repo"
 	"funny-project-be/infra/constant"
-	"funny-project-be/infra/options"
+	"funny-project-be/infra/logger"
 )
 
+// publicPaths are the paths which do not require an access token.
+var publicPaths = []string{
+	"/funny-project/v1/rpc/auth/start",
+	"/funny-project/v1/rpc/auth/login",
+	"/funny-project/v1/rpc/auth/refresh",
+	"/funny-project/v1/ws",
+	"/.well-known/",
+}
+
 // VerifyToken verifies the JWT.
-func VerifyToken(opts options.Options) beego.FilterFunc {
+func VerifyToken(keys *KeySet, revoked repo.RevokedTokenRepo) beego.FilterFunc {
 	return func(ctx *context.Context) {
-		if strings.HasPrefix(ctx.Input.URL(), "/funny-project/v1/rpc/auth/login") || strings.HasPrefix(ctx.Input.URL(), "/funny-project/v1/ws") {
-			return
+		for _, path := range publicPaths {
+			if strings.HasPrefix(ctx.Input.URL(), path) {
+				return
+			}
 		}
 
 		w := ctx.ResponseWriter
-		claims, valid := IsValidJWT(opts, ctx.Request.Header.Get("Authorization"))
+		claims, valid := IsValidJWT(ctx.Request.Context(), keys, revoked, ctx.Request.Header.Get("Authorization"))
 		if !valid {
 			w.WriteHeader(401)
 			w.Write([]byte("401 Unauthorized\n"))
@@ -31,36 +43,41 @@ func VerifyToken(opts options.Options) beego.FilterFunc {
 
 		uid, err := strconv.Atoi(claims["sub"].(string))
 		if err != nil {
-			beego.Error("VerifyToken ", err)
+			slog.ErrorContext(ctx.Request.Context(), "VerifyToken", "err", err)
 			w.WriteHeader(401)
 			w.Write([]byte("401 Unauthorized\n"))
 			return
 		}
 		ctx.Input.SetData(constant.ContextUID, uint(uid))
 		ctx.Input.SetData(constant.ContextEmail, claims["email"])
+		ctx.Input.SetData(constant.ContextJTI, claims["jti"])
 
-		customctx := goctx.Background()
+		// The records of the request are logged with its user.
+		logger.RequestFrom(ctx.Request.Context()).SetUID(uint(uid))
+
+		// Keep the request ID set by reqlog.RequestID.
+		customctx, ok := ctx.Input.GetData(constant.ContextCtx).(goctx.Context)
+		if !ok {
+			customctx = goctx.Background()
+		}
 		customctx = goctx.WithValue(customctx, constant.ContextUID, uint(uid))
 		customctx = goctx.WithValue(customctx, constant.ContextEmail, claims["email"])
 		ctx.Input.SetData(constant.ContextCtx, customctx)
 	}
 }
 
-func IsValidJWT(opts options.Options, token string) (jwt.MapClaims, bool) {
+// IsValidJWT verifies a "Bearer <jwt>" token and returns its claims.
+// The verification key is picked from keys, tokens whose jti has been revoked are rejected.
+func IsValidJWT(ctx goctx.Context, keys *KeySet, revoked repo.RevokedTokenRepo, token string) (jwt.MapClaims, bool) {
 	// The Authorization header should come in this format: Bearer <jwt>
 	s := strings.SplitN(token, " ", 2)
 	if len(s) != 2 || s[0] != "Bearer" {
 		return nil, false
 	}
 
-	t, err := jwt.Parse(s[1], func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
-		}
-		return []byte(opts.AccessTokenSecret), nil
-	})
+	t, err := jwt.Parse(s[1], keys.Keyfunc)
 	if err != nil {
-		beego.Error("IsValidJWT ", err)
+		slog.InfoContext(ctx, "IsValidJWT", "err", err)
 		return nil, false
 	}
 
@@ -69,5 +86,17 @@ func IsValidJWT(opts options.Options, token string) (jwt.MapClaims, bool) {
 		return nil, false
 	}
 
+	// Tokens issued before the jti claim existed can not be revoked and live until they expire.
+	if jti, ok := claims["jti"].(string); ok && jti != "" {
+		isRevoked, err := revoked.IsRevoked(ctx, jti)
+		if err != nil {
+			slog.ErrorContext(ctx, "IsValidJWT", "err", err)
+			return nil, false
+		}
+		if isRevoked {
+			return nil, false
+		}
+	}
+
 	return claims, true
 }
@@ -0,0 +1,212 @@
+package authn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"funny-project-be/infra/options"
+)
+
+// signingKey is an asymmetric key which signs access tokens.
+type signingKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	jwk     JWK
+}
+
+// defaultAccessTokenSecret is the development secret of app.conf, which anyone
+// can sign tokens with.
+const defaultAccessTokenSecret = "R6m9bmGoq4M0"
+
+// KeySet holds the keys which sign and verify access tokens.
+// The first configured key signs new tokens, every key verifies them so that
+// tokens signed by a retired key stay valid until they expire.
+// Without asymmetric keys, tokens are signed with the HS512 shared secret, which
+// only verifies them afterwards when opts.AccessTokenAcceptHS512 is set.
+type KeySet struct {
+	keys   []*signingKey
+	secret []byte
+}
+
+// JWK is a public JSON Web Key (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewKeySet loads the PEM private keys of opts and returns a new instance of KeySet.
+func NewKeySet(opts options.Options) (*KeySet, error) {
+	s := &KeySet{}
+	if opts.AccessTokenSecret != "" {
+		s.secret = []byte(opts.AccessTokenSecret)
+	}
+
+	for _, file := range opts.AccessTokenKeyFiles {
+		file = strings.TrimSpace(file)
+		if file == "" {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		key, err := parseSigningKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if s.key(key.id) == nil {
+			s.keys = append(s.keys, key)
+		}
+	}
+
+	if len(s.keys) == 0 && s.secret == nil {
+		return nil, errors.New("no access token signing key configured")
+	}
+	if len(s.keys) > 0 {
+		if opts.AccessTokenSecret == defaultAccessTokenSecret {
+			return nil, errors.New("the access token secret must be changed from its default once key files are configured")
+		}
+		if !opts.AccessTokenAcceptHS512 {
+			s.secret = nil
+		}
+	}
+
+	return s, nil
+}
+
+// parseSigningKey parses a PEM encoded RSA or P-256 private key.
+func parseSigningKey(data []byte) (*signingKey, error) {
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		jwk := JWK{
+			Kty: "RSA",
+			Alg: jwt.SigningMethodRS256.Alg(),
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}
+		return newSigningKey(jwt.SigningMethodRS256, key, jwk), nil
+	}
+
+	key, err := jwt.ParseECPrivateKeyFromPEM(data)
+	if err != nil {
+		return nil, errors.New("key must be a PEM encoded RSA or EC private key")
+	}
+	if key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("unsupported curve %s, only P-256 is supported", key.Curve.Params().Name)
+	}
+	jwk := JWK{
+		Kty: "EC",
+		Alg: jwt.SigningMethodES256.Alg(),
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+	return newSigningKey(jwt.SigningMethodES256, key, jwk), nil
+}
+
+// newSigningKey identifies a key by its JWK thumbprint (RFC 7638).
+func newSigningKey(method jwt.SigningMethod, private crypto.Signer, jwk JWK) *signingKey {
+	var members string
+	if jwk.Kty == "RSA" {
+		members = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, jwk.E, jwk.N)
+	} else {
+		members = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, jwk.Crv, jwk.X, jwk.Y)
+	}
+	sum := sha256.Sum256([]byte(members))
+
+	jwk.Kid = base64.RawURLEncoding.EncodeToString(sum[:])
+	jwk.Use = "sig"
+
+	return &signingKey{
+		id:      jwk.Kid,
+		method:  method,
+		private: private,
+		jwk:     jwk,
+	}
+}
+
+// key returns the key identified by kid, or nil.
+func (s *KeySet) key(kid string) *signingKey {
+	for _, key := range s.keys {
+		if key.id == kid {
+			return key
+		}
+	}
+
+	return nil
+}
+
+// Sign signs claims with the current signing key.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if len(s.keys) == 0 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString(s.secret)
+	}
+
+	signing := s.keys[0]
+	token := jwt.NewWithClaims(signing.method, claims)
+	token.Header["kid"] = signing.id
+
+	return token.SignedString(signing.private)
+}
+
+// Keyfunc returns the key verifying token, picked by its kid header.
+// HS512 tokens are verified with the shared secret while it is accepted.
+func (s *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if token.Method == jwt.SigningMethodHS512 {
+		if s.secret == nil {
+			return nil, errors.New("HS512 tokens are not accepted")
+		}
+		return s.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key := s.key(kid)
+	if key == nil {
+		return nil, fmt.Errorf("unknown kid: %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	switch private := key.private.(type) {
+	case *rsa.PrivateKey:
+		return &private.PublicKey, nil
+	case *ecdsa.PrivateKey:
+		return &private.PublicKey, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %T", key.private)
+}
+
+// JWKS returns the public keys of the set, the signing key first.
+func (s *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range s.keys {
+		jwks.Keys = append(jwks.Keys, key.jwk)
+	}
+
+	return jwks
+}
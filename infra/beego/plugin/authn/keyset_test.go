@@ -0,0 +1,155 @@
+package authn
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"funny-project-be/infra/options"
+)
+
+func writeRSAKey(t *testing.T) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "rsa.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	require.NoError(t, os.WriteFile(file, data, 0o600))
+
+	return file
+}
+
+func writeECKey(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "ec.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	require.NoError(t, os.WriteFile(file, data, 0o600))
+
+	return file
+}
+
+func newClaims() jwt.StandardClaims {
+	return jwt.StandardClaims{
+		Subject:   "1",
+		ExpiresAt: time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestKeySet_SignAndVerify(t *testing.T) {
+	for name, file := range map[string]string{"RS256": writeRSAKey(t), "ES256": writeECKey(t)} {
+		t.Run(name, func(t *testing.T) {
+			keys, err := NewKeySet(options.Options{AccessTokenKeyFiles: []string{file}})
+			require.NoError(t, err)
+
+			token, err := keys.Sign(newClaims())
+			require.NoError(t, err)
+
+			parsed, err := jwt.Parse(token, keys.Keyfunc)
+			require.NoError(t, err)
+			assert.Equal(t, name, parsed.Method.Alg())
+			assert.Equal(t, keys.JWKS().Keys[0].Kid, parsed.Header["kid"])
+
+			claims, valid := IsValidJWT(context.Background(), keys, nil, "Bearer "+token)
+			require.True(t, valid)
+			assert.Equal(t, "1", claims["sub"])
+		})
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	oldFile, newFile := writeRSAKey(t), writeECKey(t)
+
+	oldKeys, err := NewKeySet(options.Options{AccessTokenKeyFiles: []string{oldFile}})
+	require.NoError(t, err)
+	oldToken, err := oldKeys.Sign(newClaims())
+	require.NoError(t, err)
+
+	// The new key signs, the old one still verifies.
+	keys, err := NewKeySet(options.Options{AccessTokenKeyFiles: []string{newFile, oldFile}})
+	require.NoError(t, err)
+	newToken, err := keys.Sign(newClaims())
+	require.NoError(t, err)
+
+	_, err = jwt.Parse(oldToken, keys.Keyfunc)
+	assert.NoError(t, err)
+	parsed, err := jwt.Parse(newToken, keys.Keyfunc)
+	require.NoError(t, err)
+	assert.Equal(t, "ES256", parsed.Method.Alg())
+
+	jwks := keys.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "EC", jwks.Keys[0].Kty)
+	assert.Equal(t, "P-256", jwks.Keys[0].Crv)
+	assert.Equal(t, "RSA", jwks.Keys[1].Kty)
+	assert.Equal(t, "AQAB", jwks.Keys[1].E)
+	assert.Equal(t, parsed.Header["kid"], jwks.Keys[0].Kid)
+
+	// Once the old key is removed its tokens are rejected.
+	newKeys, err := NewKeySet(options.Options{AccessTokenKeyFiles: []string{newFile}})
+	require.NoError(t, err)
+	_, err = jwt.Parse(oldToken, newKeys.Keyfunc)
+	assert.Error(t, err)
+}
+
+func TestKeySet_HS512(t *testing.T) {
+	secretKeys, err := NewKeySet(options.Options{AccessTokenSecret: "secret"})
+	require.NoError(t, err)
+	token, err := secretKeys.Sign(newClaims())
+	require.NoError(t, err)
+
+	// HS512 tokens stay valid along key files only while they are accepted.
+	keys, err := NewKeySet(options.Options{AccessTokenSecret: "secret", AccessTokenKeyFiles: []string{writeRSAKey(t)}, AccessTokenAcceptHS512: true})
+	require.NoError(t, err)
+	_, valid := IsValidJWT(context.Background(), keys, nil, "Bearer "+token)
+	assert.True(t, valid)
+
+	keys, err = NewKeySet(options.Options{AccessTokenSecret: "secret", AccessTokenKeyFiles: []string{writeRSAKey(t)}})
+	require.NoError(t, err)
+	_, valid = IsValidJWT(context.Background(), keys, nil, "Bearer "+token)
+	assert.False(t, valid)
+
+	keys, err = NewKeySet(options.Options{AccessTokenKeyFiles: []string{writeRSAKey(t)}, AccessTokenAcceptHS512: true})
+	require.NoError(t, err)
+	_, valid = IsValidJWT(context.Background(), keys, nil, "Bearer "+token)
+	assert.False(t, valid)
+
+	// The default secret of app.conf is public, it can not be left along key files.
+	_, err = NewKeySet(options.Options{AccessTokenSecret: defaultAccessTokenSecret, AccessTokenKeyFiles: []string{writeRSAKey(t)}})
+	assert.Error(t, err)
+}
+
+func TestKeySet_RejectsMismatchedAlg(t *testing.T) {
+	keys, err := NewKeySet(options.Options{AccessTokenKeyFiles: []string{writeECKey(t)}})
+	require.NoError(t, err)
+	kid := keys.JWKS().Keys[0].Kid
+
+	// A token claiming the kid of the EC key but signed with another algorithm.
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, newClaims())
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(other)
+	require.NoError(t, err)
+
+	_, err = jwt.Parse(signed, keys.Keyfunc)
+	assert.Error(t, err)
+
+	_, err = NewKeySet(options.Options{})
+	assert.Error(t, err)
+}
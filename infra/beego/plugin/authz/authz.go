@@ -0,0 +1,135 @@
+package authz
+
+import (
+	goctx "context"
+	"log/slog"
+	"strings"
+
+	"github.com/beego/beego"
+	"github.com/beego/beego/context"
+
+	"funny-project-be/domain/entity"
+	"funny-project-be/domain/repo"
+	"funny-project-be/infra/constant"
+)
+
+// Permission allows an action on a resource.
+type Permission string
+
+// Permissions.
+const (
+	// VideoRead allows reading and searching videos and their comments.
+	VideoRead Permission = "video:read"
+	// VideoShare allows sharing videos.
+	VideoShare Permission = "video:share"
+	// VideoReact allows liking and disliking videos.
+	VideoReact Permission = "video:react"
+	// VideoDelete allows deleting the videos the user shared.
+	VideoDelete Permission = "video:delete"
+	// VideoModerate allows deleting, hiding and reading hidden videos of any user.
+	VideoModerate Permission = "video:moderate"
+	// CommentWrite allows writing comments.
+	CommentWrite Permission = "comment:write"
+	// ProfileWrite allows editing the profile of the user.
+	ProfileWrite Permission = "profile:write"
+	// UserFollow allows following users and reading the feed of their videos.
+	UserFollow Permission = "user:follow"
+	// UserModerate allows listing and banning users.
+	UserModerate Permission = "user:moderate"
+)
+
+// rolePermissions are the permissions granted to each role.
+var rolePermissions = map[string][]Permission{
+	entity.RoleUser: {VideoRead, VideoShare, VideoReact, VideoDelete, CommentWrite, ProfileWrite, UserFollow},
+	entity.RoleAdmin: {VideoRead, VideoShare, VideoReact, VideoDelete, CommentWrite, ProfileWrite, UserFollow,
+		VideoModerate, UserModerate},
+}
+
+// HasPermission reports whether role grants perm. Unknown roles have no permission.
+func HasPermission(role string, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Can reports whether the user of the request has perm, according to the role
+// set by RejectBanned.
+func Can(ctx *context.Context, perm Permission) bool {
+	role, _ := ctx.Input.GetData(constant.ContextRole).(string)
+
+	return HasPermission(role, perm)
+}
+
+// Rule requires Permissions for the requests of Method, or any method when it is
+// empty, to the routes matching Pattern.
+type Rule struct {
+	Method      string
+	Pattern     string
+	Permissions []Permission
+}
+
+// Register inserts a filter checking each rule. The filters run before the
+// controllers, once authn has set the role of the request.
+func Register(rules ...Rule) {
+	for _, rule := range rules {
+		beego.InsertFilter(rule.Pattern, beego.BeforeExec, Require(rule.Method, rule.Permissions...))
+	}
+}
+
+// Require returns a filter rejecting the requests of method whose user misses
+// one of perms with 403 Forbidden.
+func Require(method string, perms ...Permission) beego.FilterFunc {
+	return func(ctx *context.Context) {
+		if method != "" && !strings.EqualFold(ctx.Input.Method(), method) {
+			return
+		}
+		for _, perm := range perms {
+			if !Can(ctx, perm) {
+				w := ctx.ResponseWriter
+				w.WriteHeader(403)
+				w.Write([]byte("403 Forbidden\n"))
+				return
+			}
+		}
+	}
+}
+
+// RejectBanned returns a filter rejecting the authenticated requests of banned
+// users with 403 Forbidden, and setting the role of the others. Access tokens
+// stay valid until they expire, so bans and roles are read from users on every
+// request rather than from the role claim of the token.
+func RejectBanned(users repo.UserRepo) beego.FilterFunc {
+	return func(ctx *context.Context) {
+		uid, ok := ctx.Input.GetData(constant.ContextUID).(uint)
+		if !ok {
+			return
+		}
+
+		w := ctx.ResponseWriter
+		user, err := users.Get(ctx.Request.Context(), uid)
+		if err != nil {
+			slog.ErrorContext(ctx.Request.Context(), "RejectBanned", "uid", uid, "err", err)
+			w.WriteHeader(401)
+			w.Write([]byte("401 Unauthorized\n"))
+			return
+		}
+		if user.BannedAt != nil {
+			w.WriteHeader(403)
+			w.Write([]byte("403 Forbidden\n"))
+			return
+		}
+
+		role := user.Role
+		if role == "" {
+			role = entity.RoleUser
+		}
+		ctx.Input.SetData(constant.ContextRole, role)
+		if customctx, ok := ctx.Input.GetData(constant.ContextCtx).(goctx.Context); ok {
+			ctx.Input.SetData(constant.ContextCtx, goctx.WithValue(customctx, constant.ContextRole, role))
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package authz
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/beego/beego"
+	beegoctx "github.com/beego/beego/context"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"funny-project-be/domain/entity"
+	"funny-project-be/infra/constant"
+)
+
+func TestHasPermission(t *testing.T) {
+	assert.True(t, HasPermission(entity.RoleUser, VideoShare))
+	assert.True(t, HasPermission(entity.RoleUser, VideoDelete))
+	assert.True(t, HasPermission(entity.RoleUser, ProfileWrite))
+	assert.False(t, HasPermission(entity.RoleUser, VideoModerate))
+	assert.False(t, HasPermission(entity.RoleUser, UserModerate))
+	assert.True(t, HasPermission(entity.RoleAdmin, VideoModerate))
+	assert.True(t, HasPermission(entity.RoleAdmin, UserModerate))
+	assert.False(t, HasPermission("", VideoRead))
+	assert.False(t, HasPermission("root", VideoRead))
+}
+
+func TestRegister(t *testing.T) {
+	// The role normally comes from authn.VerifyToken.
+	beego.InsertFilter("/authz/*", beego.BeforeRouter, func(ctx *beegoctx.Context) {
+		ctx.Input.SetData(constant.ContextRole, ctx.Input.Header("X-Role"))
+	})
+	ok := func(ctx *beegoctx.Context) { ctx.Output.Body([]byte("ok")) }
+	beego.Get("/authz/videos/:id", ok)
+	beego.Delete("/authz/videos/:id", ok)
+	beego.Get("/authz/admin/users", ok)
+	beego.Put("/authz/admin/users/:id/ban", ok)
+
+	Register(
+		Rule{Method: "GET", Pattern: "/authz/videos/:id", Permissions: []Permission{VideoRead}},
+		Rule{Method: "DELETE", Pattern: "/authz/videos/:id", Permissions: []Permission{VideoModerate}},
+		Rule{Pattern: "/authz/admin/*", Permissions: []Permission{UserModerate}},
+	)
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		role   string
+		code   int
+	}{
+		{method: "GET", path: "/authz/videos/1", role: entity.RoleUser, code: http.StatusOK},
+		{method: "GET", path: "/authz/videos/1", role: "", code: http.StatusForbidden},
+		{method: "DELETE", path: "/authz/videos/1", role: entity.RoleUser, code: http.StatusForbidden},
+		{method: "DELETE", path: "/authz/videos/1", role: entity.RoleAdmin, code: http.StatusOK},
+		{method: "GET", path: "/authz/admin/users", role: entity.RoleUser, code: http.StatusForbidden},
+		{method: "GET", path: "/authz/admin/users", role: entity.RoleAdmin, code: http.StatusOK},
+		{method: "PUT", path: "/authz/admin/users/2/ban", role: entity.RoleUser, code: http.StatusForbidden},
+		{method: "PUT", path: "/authz/admin/users/2/ban", role: entity.RoleAdmin, code: http.StatusOK},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("X-Role", tc.role)
+		rec := httptest.NewRecorder()
+
+		beego.BeeApp.Handlers.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.code, rec.Code, "%s %s as %q", tc.method, tc.path, tc.role)
+	}
+}
+
+type bannedUserRepo map[uint]*entity.User
+
+func (m bannedUserRepo) Get(ctx context.Context, id uint) (*entity.User, error) {
+	user, ok := m[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return user, nil
+}
+func (m bannedUserRepo) GetOneByEmail(ctx context.Context, email string) (*entity.User, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+func (m bannedUserRepo) GetRange(ctx context.Context, limit int, page int) ([]*entity.User, error) {
+	return nil, nil
+}
+func (m bannedUserRepo) Count(ctx context.Context) (int64, error) {
+	return int64(len(m)), nil
+}
+func (m bannedUserRepo) Add(ctx context.Context, users ...*entity.User) error    { return nil }
+func (m bannedUserRepo) Remove(ctx context.Context, users ...*entity.User) error { return nil }
+func (m bannedUserRepo) Update(ctx context.Context, users ...*entity.User) error { return nil }
+
+func TestRejectBanned(t *testing.T) {
+	now := time.Now()
+	filter := RejectBanned(bannedUserRepo{
+		1: {ID: 1},
+		2: {ID: 2, BannedAt: &now},
+		4: {ID: 4, Role: entity.RoleAdmin},
+	})
+
+	run := func(uid interface{}) (int, *beegoctx.Context) {
+		rec := httptest.NewRecorder()
+		ctx := beegoctx.NewContext()
+		ctx.Reset(rec, httptest.NewRequest("GET", "/", nil))
+		if uid != nil {
+			ctx.Input.SetData(constant.ContextUID, uid)
+		}
+		filter(ctx)
+		return rec.Code, ctx
+	}
+
+	code, _ := run(nil)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = run(uint(2))
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = run(uint(3))
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// The role comes from the user, users without one are plain users.
+	code, ctx := run(uint(1))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, entity.RoleUser, ctx.Input.GetData(constant.ContextRole))
+	assert.False(t, Can(ctx, VideoModerate))
+	code, ctx = run(uint(4))
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, Can(ctx, VideoModerate))
+}
@@ -0,0 +1,141 @@
+package reqlog
+
+import (
+	"bufio"
+	goctx "context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/beego/beego"
+	"github.com/beego/beego/context"
+
+	"funny-project-be/infra/constant"
+	"funny-project-be/infra/logger"
+)
+
+// HeaderRequestID is the header carrying the request ID, from the client or
+// the reverse proxy, and back to the client.
+const HeaderRequestID = "X-Request-ID"
+
+// maxRequestIDLength is the length of the longest request ID propagated.
+const maxRequestIDLength = 128
+
+// RequestID returns a filter assigning an ID to each request, or propagating
+// the one of its X-Request-ID header. The ID is sent back in X-Request-ID and
+// travels in the context of constant.ContextCtx, so that every record logged
+// with it carries the ID.
+func RequestID() beego.FilterFunc {
+	return func(ctx *context.Context) {
+		r := logger.RequestFrom(ctx.Request.Context())
+		if r == nil {
+			// The request is not served through AccessLog.
+			r = &logger.Request{}
+			ctx.Request = ctx.Request.WithContext(logger.WithRequest(ctx.Request.Context(), r))
+		}
+
+		id := ctx.Input.Header(HeaderRequestID)
+		if !isValidRequestID(id) {
+			id = logger.NewRequestID()
+		}
+		r.SetID(id)
+		ctx.Output.Header(HeaderRequestID, id)
+		ctx.Input.SetData(constant.ContextCtx, logger.WithRequest(goctx.Background(), r))
+	}
+}
+
+// Route returns a filter recording the route pattern of the request, e.g.
+// /funny-project/v1/rest/videos/:id, in the records logged with its context.
+// It must be inserted at beego.BeforeExec, once the request is routed.
+func Route() beego.FilterFunc {
+	return func(ctx *context.Context) {
+		if route, ok := ctx.Input.GetData("RouterPattern").(string); ok {
+			logger.RequestFrom(ctx.Request.Context()).SetRoute(route)
+		}
+	}
+}
+
+// isValidRequestID reports whether id is short and made of printable ASCII
+// characters, so that it can be logged and sent back as is.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AccessLog returns a middleware logging each request to log once it has been
+// served, with its status and duration, including the requests rejected by
+// filters. The filters and controllers fill the request ID, user and route of
+// the record through the logger.Request of the request context.
+func AccessLog(log *slog.Logger) beego.MiddleWare {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			req := &logger.Request{}
+			ctx := logger.WithRequest(r.Context(), req)
+			sw := &statusWriter{ResponseWriter: w}
+
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			log.LogAttrs(ctx, slog.LevelInfo, "Request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", sw.Status()),
+				slog.Duration("duration", time.Since(start)),
+			)
+		})
+	}
+}
+
+// statusWriter records the status of a response. It lets the WebSocket
+// upgrades hijack the connection and the event streams flush.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// Status returns the status of the response, 200 when none was written.
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush implements http.Flusher.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("webserver doesn't support hijacking")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+
+	return hj.Hijack()
+}
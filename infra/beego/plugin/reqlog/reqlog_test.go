@@ -0,0 +1,125 @@
+package reqlog
+
+import (
+	"bytes"
+	goctx "context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/beego/beego"
+	"github.com/beego/beego/context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"funny-project-be/infra/constant"
+	"funny-project-be/infra/logger"
+)
+
+func TestRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := logger.New(&buf, logger.FormatJSON, slog.LevelInfo)
+	require.NoError(t, err)
+
+	beego.InsertFilter("/reqlog/*", beego.BeforeRouter, RequestID())
+	beego.InsertFilter("/reqlog/*", beego.BeforeExec, Route())
+	// The user normally comes from authn.VerifyToken.
+	beego.InsertFilter("/reqlog/*", beego.BeforeExec, func(ctx *context.Context) {
+		if ctx.Input.Header("X-User") != "" {
+			logger.RequestFrom(ctx.Request.Context()).SetUID(7)
+		}
+	})
+	beego.InsertFilter("/reqlog/forbidden", beego.BeforeExec, func(ctx *context.Context) {
+		ctx.Output.SetStatus(http.StatusForbidden)
+		ctx.Output.Body([]byte("403 Forbidden\n"))
+	})
+	beego.Get("/reqlog/videos/:id", func(ctx *context.Context) {
+		c := ctx.Input.GetData(constant.ContextCtx).(goctx.Context)
+		log.ErrorContext(c, "GetVideo")
+		ctx.Output.Body([]byte("ok"))
+	})
+	beego.Get("/reqlog/forbidden", func(ctx *context.Context) {
+		ctx.Output.Body([]byte("ok"))
+	})
+	handler := AccessLog(log)(beego.BeeApp.Handlers)
+
+	do := func(path string, id string, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if id != "" {
+			req.Header.Set(HeaderRequestID, id)
+		}
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	records := func() []map[string]interface{} {
+		defer buf.Reset()
+		var records []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var record map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &record))
+			records = append(records, record)
+		}
+		return records
+	}
+
+	// The ID of the client is propagated to the records and the response.
+	rec := do("/reqlog/videos/1", "client-id", "alice")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "client-id", rec.Header().Get(HeaderRequestID))
+	logged := records()
+	require.Len(t, logged, 2)
+	assert.Equal(t, "GetVideo", logged[0]["msg"])
+	assert.Equal(t, "client-id", logged[0]["request_id"])
+	assert.Equal(t, float64(7), logged[0]["uid"])
+	assert.Equal(t, "Request", logged[1]["msg"])
+	assert.Equal(t, "client-id", logged[1]["request_id"])
+	assert.Equal(t, float64(7), logged[1]["uid"])
+	assert.Equal(t, "/reqlog/videos/:id", logged[1]["route"])
+	assert.Equal(t, "/reqlog/videos/1", logged[1]["path"])
+	assert.Equal(t, float64(http.StatusOK), logged[1]["status"])
+	assert.Contains(t, logged[1], "duration")
+
+	// Requests without a valid ID are assigned one.
+	for _, id := range []string{"", "bad id", strings.Repeat("a", 129)} {
+		rec = do("/reqlog/videos/1", id, "")
+		assigned := rec.Header().Get(HeaderRequestID)
+		assert.Len(t, assigned, 32)
+		logged = records()
+		require.Len(t, logged, 2)
+		assert.Equal(t, assigned, logged[1]["request_id"])
+		assert.NotContains(t, logged[1], "uid")
+	}
+
+	// Requests rejected by a filter are logged with their status.
+	rec = do("/reqlog/forbidden", "", "alice")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	logged = records()
+	require.Len(t, logged, 1)
+	assert.Equal(t, float64(http.StatusForbidden), logged[0]["status"])
+	assert.Equal(t, "/reqlog/forbidden", logged[0]["route"])
+}
+
+func TestRequestID_WithoutAccessLog(t *testing.T) {
+	beego.InsertFilter("/reqlog-bare/*", beego.BeforeRouter, RequestID())
+	beego.InsertFilter("/reqlog-bare/*", beego.BeforeExec, Route())
+
+	var route string
+	beego.Get("/reqlog-bare/:id", func(ctx *context.Context) {
+		c := ctx.Input.GetData(constant.ContextCtx).(goctx.Context)
+		r := logger.RequestFrom(c)
+		require.NotNil(t, r)
+		assert.Equal(t, r, logger.RequestFrom(ctx.Request.Context()))
+		route = ctx.Input.GetData("RouterPattern").(string)
+		ctx.Output.Body([]byte(r.ID()))
+	})
+
+	rec := httptest.NewRecorder()
+	beego.BeeApp.Handlers.ServeHTTP(rec, httptest.NewRequest("GET", "/reqlog-bare/1", nil))
+	assert.Equal(t, rec.Header().Get(HeaderRequestID), rec.Body.String())
+	assert.Equal(t, "/reqlog-bare/:id", route)
+}
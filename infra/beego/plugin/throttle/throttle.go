@@ -0,0 +1,73 @@
+package throttle
+
+import (
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/beego/beego"
+	"github.com/beego/beego/context"
+
+	"funny-project-be/domain/ratelimit"
+	"funny-project-be/infra/constant"
+	"funny-project-be/infra/status"
+)
+
+// Rule limits the requests of Method, or any method when it is empty, to the
+// routes matching Pattern. The rules of the same Name share their buckets.
+type Rule struct {
+	Name    string
+	Method  string
+	Pattern string
+	Limit   ratelimit.Limit
+}
+
+// Register inserts a filter checking each rule whose limit is not zero. The
+// filters run before the controllers, once authn has set the user of the request.
+func Register(store ratelimit.Store, rules ...Rule) {
+	for _, rule := range rules {
+		if rule.Limit.IsZero() {
+			continue
+		}
+		beego.InsertFilter(rule.Pattern, beego.BeforeExec, Limit(store, rule.Name, rule.Method, rule.Limit))
+	}
+}
+
+// Limit returns a filter taking a token of the bucket of name for each request
+// of method, one bucket per user when the request is authenticated, otherwise
+// per client IP. Requests finding their bucket empty are rejected with 429 Too
+// Many Requests and the seconds to wait in Retry-After. Requests are let through
+// when store fails.
+func Limit(store ratelimit.Store, name string, method string, limit ratelimit.Limit) beego.FilterFunc {
+	return func(ctx *context.Context) {
+		if method != "" && !strings.EqualFold(ctx.Input.Method(), method) {
+			return
+		}
+
+		allowed, retryAfter, err := store.Take(ctx.Request.Context(), key(ctx, name), limit)
+		if err != nil {
+			slog.ErrorContext(ctx.Request.Context(), "Limit", "name", name, "err", err)
+			return
+		}
+		if allowed {
+			return
+		}
+
+		ctx.Output.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		ctx.Output.SetStatus(429)
+		ctx.Output.JSON(struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}{status.TooManyRequests, "too many requests"}, false, false)
+	}
+}
+
+// key returns the bucket of name for the user of the request, or its client IP.
+func key(ctx *context.Context, name string) string {
+	if uid, ok := ctx.Input.GetData(constant.ContextUID).(uint); ok {
+		return name + ":user:" + strconv.FormatUint(uint64(uid), 10)
+	}
+
+	return name + ":ip:" + ctx.Input.IP()
+}
@@ -0,0 +1,82 @@
+package throttle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/beego/beego"
+	beegoctx "github.com/beego/beego/context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"funny-project-be/domain/ratelimit"
+	"funny-project-be/infra/constant"
+	"funny-project-be/infra/ratelimit/ratelimitimpl"
+	"funny-project-be/infra/status"
+)
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit ratelimit.Limit) (bool, time.Duration, error) {
+	return false, 0, errors.New("store is down")
+}
+
+func TestRegister(t *testing.T) {
+	// The user normally comes from authn.VerifyToken.
+	beego.InsertFilter("/throttle/*", beego.BeforeRouter, func(ctx *beegoctx.Context) {
+		if uid := ctx.Input.Header("X-User"); uid != "" {
+			ctx.Input.SetData(constant.ContextUID, uint(len(uid)))
+		}
+	})
+	ok := func(ctx *beegoctx.Context) { ctx.Output.Body([]byte("ok")) }
+	beego.Post("/throttle/videos", ok)
+	beego.Get("/throttle/videos", ok)
+	beego.Post("/throttle/login", ok)
+	beego.Post("/throttle/unlimited", ok)
+	beego.Post("/throttle/failing", ok)
+
+	store := ratelimitimpl.NewMemoryStore()
+	Register(store,
+		Rule{Name: "share", Method: "POST", Pattern: "/throttle/videos", Limit: ratelimit.Limit{Burst: 2, Per: time.Hour}},
+		Rule{Name: "login", Method: "POST", Pattern: "/throttle/login", Limit: ratelimit.Limit{Burst: 1, Per: time.Minute}},
+		Rule{Name: "unlimited", Pattern: "/throttle/unlimited"},
+	)
+	Register(failingStore{}, Rule{Name: "failing", Pattern: "/throttle/failing", Limit: ratelimit.Limit{Burst: 1, Per: time.Minute}})
+
+	do := func(method string, path string, user string, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-User", user)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		beego.BeeApp.Handlers.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Authenticated requests are limited per user, whatever their IP.
+	assert.Equal(t, http.StatusOK, do("POST", "/throttle/videos", "a", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusOK, do("POST", "/throttle/videos", "a", "10.0.0.2").Code)
+	rec := do("POST", "/throttle/videos", "a", "10.0.0.3")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1800", rec.Header().Get("Retry-After"))
+	var body struct{ Code int }
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, status.TooManyRequests, body.Code)
+	assert.Equal(t, http.StatusOK, do("POST", "/throttle/videos", "bb", "10.0.0.3").Code)
+	assert.Equal(t, http.StatusOK, do("GET", "/throttle/videos", "a", "10.0.0.3").Code)
+
+	// Anonymous requests are limited per client IP.
+	assert.Equal(t, http.StatusOK, do("POST", "/throttle/login", "", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, do("POST", "/throttle/login", "", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusOK, do("POST", "/throttle/login", "", "10.0.0.2").Code)
+
+	// Zero limits and failing stores let requests through.
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, do("POST", "/throttle/unlimited", "", "10.0.0.1").Code)
+		assert.Equal(t, http.StatusOK, do("POST", "/throttle/failing", "", "10.0.0.1").Code)
+	}
+}
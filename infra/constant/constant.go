@@ -10,4 +10,8 @@ const (
 	ContextEmail
 	// ContextCtx key.
 	ContextCtx
+	// ContextJTI key, the id of the access token.
+	ContextJTI
+	// ContextRole key, the role of the user.
+	ContextRole
 )
@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// slowQueryThreshold is the duration above which queries are logged as slow.
+const slowQueryThreshold = 200 * time.Millisecond
+
+// GormLogger logs the failed and slow queries of gorm to a slog.Logger, with
+// the context of the repository call.
+type GormLogger struct {
+	log   *slog.Logger
+	level gormlogger.LogLevel
+}
+
+// NewGormLogger creates and returns a new instance of GormLogger.
+func NewGormLogger(log *slog.Logger) *GormLogger {
+	return &GormLogger{log: log, level: gormlogger.Warn}
+}
+
+// LogMode implements gormlogger.Interface.
+func (l *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return &GormLogger{log: l.log, level: level}
+}
+
+// Info implements gormlogger.Interface.
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Info {
+		l.log.InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+// Warn implements gormlogger.Interface.
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		l.log.WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+// Error implements gormlogger.Interface.
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Error {
+		l.log.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+// Trace implements gormlogger.Interface. Missing records are not errors, the
+// repositories return them to the controllers.
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= gormlogger.Error:
+		query, rows := fc()
+		l.log.ErrorContext(ctx, "Query failed", "err", err, "sql", query, "rows", rows, "duration", elapsed)
+	case elapsed > slowQueryThreshold && l.level >= gormlogger.Warn:
+		query, rows := fc()
+		l.log.WarnContext(ctx, "Slow query", "sql", query, "rows", rows, "duration", elapsed)
+	case l.level >= gormlogger.Info:
+		query, rows := fc()
+		l.log.DebugContext(ctx, "Query", "sql", query, "rows", rows, "duration", elapsed)
+	}
+}
@@ -0,0 +1,150 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// Formats of the records.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// New returns a logger writing the records of level and above to w in format.
+// The records logged with the context of a request carry its request ID and user.
+func New(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+
+	var h slog.Handler
+	switch format {
+	case FormatJSON:
+		h = slog.NewJSONHandler(w, opts)
+	case FormatText:
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+
+	return slog.New(&contextHandler{Handler: h}), nil
+}
+
+// Request holds the fields of a request added to its records. It is shared by
+// the filters, which fill it as they learn about the request, and the access log.
+type Request struct {
+	mu    sync.Mutex
+	id    string
+	uid   uint
+	route string
+}
+
+// NewRequestID returns a random request ID.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// SetID sets the request ID. It is safe to call on a nil Request, as are the other setters.
+func (r *Request) SetID(id string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.id = id
+}
+
+// SetUID sets the user of the request.
+func (r *Request) SetUID(uid uint) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.uid = uid
+}
+
+// SetRoute sets the route pattern of the request, e.g. /videos/:id.
+func (r *Request) SetRoute(route string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.route = route
+}
+
+// ID returns the request ID.
+func (r *Request) ID() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.id
+}
+
+// attrs returns the fields of r which are set.
+func (r *Request) attrs() []slog.Attr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attrs := make([]slog.Attr, 0, 3)
+	if r.id != "" {
+		attrs = append(attrs, slog.String("request_id", r.id))
+	}
+	if r.uid != 0 {
+		attrs = append(attrs, slog.Uint64("uid", uint64(r.uid)))
+	}
+	if r.route != "" {
+		attrs = append(attrs, slog.String("route", r.route))
+	}
+
+	return attrs
+}
+
+type requestKey struct{}
+
+// WithRequest returns a copy of ctx carrying r.
+func WithRequest(ctx context.Context, r *Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, r)
+}
+
+// RequestFrom returns the Request carried by ctx, or nil.
+func RequestFrom(ctx context.Context) *Request {
+	r, _ := ctx.Value(requestKey{}).(*Request)
+
+	return r
+}
+
+// contextHandler adds the fields of the Request of the context to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle implements slog.Handler.
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if r := RequestFrom(ctx); r != nil {
+		record.AddAttrs(r.attrs()...)
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler.
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,108 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := New(&buf, FormatJSON, slog.LevelInfo)
+	require.NoError(t, err)
+
+	r := &Request{}
+	r.SetID("req-1")
+	r.SetUID(7)
+	r.SetRoute("/videos/:id")
+	ctx := WithRequest(context.Background(), r)
+
+	log.DebugContext(ctx, "hidden")
+	log.With("component", "test").ErrorContext(ctx, "GetVideo", "err", errors.New("boom"))
+	log.Info("no request")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "ERROR", record["level"])
+	assert.Equal(t, "GetVideo", record["msg"])
+	assert.Equal(t, "boom", record["err"])
+	assert.Equal(t, "test", record["component"])
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, float64(7), record["uid"])
+	assert.Equal(t, "/videos/:id", record["route"])
+
+	record = nil
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	assert.NotContains(t, record, "request_id")
+	assert.NotContains(t, record, "uid")
+}
+
+func TestNew_Text(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := New(&buf, FormatText, slog.LevelDebug)
+	require.NoError(t, err)
+
+	r := &Request{}
+	r.SetID("req-1")
+	log.DebugContext(WithRequest(context.Background(), r), "Request", "status", 200)
+	assert.Contains(t, buf.String(), "level=DEBUG msg=Request status=200 request_id=req-1")
+
+	_, err = New(&buf, "xml", slog.LevelInfo)
+	assert.Error(t, err)
+}
+
+func TestRequest(t *testing.T) {
+	// Setters are no-ops on the missing Request of a context.
+	r := RequestFrom(context.Background())
+	assert.Nil(t, r)
+	r.SetID("req-1")
+	r.SetUID(7)
+	r.SetRoute("/videos")
+
+	id := NewRequestID()
+	assert.Len(t, id, 32)
+	assert.NotEqual(t, id, NewRequestID())
+}
+
+func TestGormLogger(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := New(&buf, FormatJSON, slog.LevelDebug)
+	require.NoError(t, err)
+	l := NewGormLogger(log)
+	ctx := context.Background()
+	sql := func() (string, int64) { return "SELECT 1", 1 }
+
+	// Fast queries are only logged at info level, missing records are not errors.
+	l.Trace(ctx, time.Now(), sql, nil)
+	l.Trace(ctx, time.Now(), sql, gorm.ErrRecordNotFound)
+	assert.Empty(t, buf.String())
+
+	l.Trace(ctx, time.Now(), sql, errors.New("boom"))
+	assert.Contains(t, buf.String(), `"level":"ERROR"`)
+	assert.Contains(t, buf.String(), `"sql":"SELECT 1"`)
+
+	buf.Reset()
+	l.Trace(ctx, time.Now().Add(-time.Second), sql, nil)
+	assert.Contains(t, buf.String(), `"level":"WARN"`)
+
+	buf.Reset()
+	l.LogMode(gormlogger.Info).Trace(ctx, time.Now(), sql, nil)
+	assert.Contains(t, buf.String(), `"level":"DEBUG"`)
+
+	buf.Reset()
+	l.LogMode(gormlogger.Silent).Trace(ctx, time.Now(), sql, errors.New("boom"))
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,154 @@
+package metadataimpl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"funny-project-be/domain/metadata"
+	"funny-project-be/domain/youtube"
+	"funny-project-be/infra/options"
+)
+
+// requestTimeout bounds every call to YouTube.
+const requestTimeout = 5 * time.Second
+
+var isoDurationRe = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// YouTubeResolver resolves video metadata with the YouTube oEmbed endpoint.
+// When an API key is configured the duration is fetched from the Data API.
+type YouTubeResolver struct {
+	client    *http.Client
+	oEmbedURL string
+	apiURL    string
+	apiKey    string
+	log       *slog.Logger
+}
+
+// NewYouTubeResolver creates and returns a new instance of YouTubeResolver.
+func NewYouTubeResolver(opts options.Options, log *slog.Logger) *YouTubeResolver {
+	return &YouTubeResolver{
+		client:    &http.Client{Timeout: requestTimeout},
+		oEmbedURL: opts.YouTubeOEmbedURL,
+		apiURL:    strings.TrimSuffix(opts.YouTubeAPIURL, "/"),
+		apiKey:    opts.YouTubeAPIKey,
+		log:       log,
+	}
+}
+
+type oEmbedResponse struct {
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+type videoListResponse struct {
+	Items []struct {
+		ContentDetails struct {
+			Duration string `json:"duration"`
+		} `json:"contentDetails"`
+	} `json:"items"`
+}
+
+// Resolve fetches and returns the metadata of the video at videoURL.
+func (r *YouTubeResolver) Resolve(ctx context.Context, videoURL string) (*metadata.Video, error) {
+	query := url.Values{"url": {videoURL}, "format": {"json"}}
+
+	var oEmbed oEmbedResponse
+	if err := r.getJSON(ctx, r.oEmbedURL+"?"+query.Encode(), &oEmbed); err != nil {
+		return nil, err
+	}
+
+	video := &metadata.Video{
+		Title:        oEmbed.Title,
+		ThumbnailURL: oEmbed.ThumbnailURL,
+		ChannelName:  oEmbed.AuthorName,
+	}
+
+	if r.apiKey == "" {
+		return video, nil
+	}
+	id, err := youtube.ParseURL(videoURL)
+	if err != nil {
+		return video, nil
+	}
+
+	// The duration is a nice to have, so a Data API failure keeps the oEmbed metadata.
+	duration, err := r.fetchDuration(ctx, id)
+	if err != nil {
+		r.log.WarnContext(ctx, "YouTubeResolver fetchDuration", "err", err)
+		return video, nil
+	}
+	video.DurationSeconds = duration
+
+	return video, nil
+}
+
+// fetchDuration returns the duration in seconds of the video id from the Data API.
+func (r *YouTubeResolver) fetchDuration(ctx context.Context, id string) (int, error) {
+	query := url.Values{"id": {id}, "part": {"contentDetails"}, "key": {r.apiKey}}
+
+	var list videoListResponse
+	if err := r.getJSON(ctx, r.apiURL+"/videos?"+query.Encode(), &list); err != nil {
+		return 0, err
+	}
+	if len(list.Items) == 0 {
+		return 0, metadata.ErrVideoUnavailable
+	}
+
+	return parseISODuration(list.Items[0].ContentDetails.Duration)
+}
+
+func (r *YouTubeResolver) getJSON(ctx context.Context, rawURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound ||
+		resp.StatusCode == http.StatusUnauthorized ||
+		resp.StatusCode == http.StatusForbidden:
+		// oEmbed answers 401 for private videos and 404 for unknown ones.
+		return metadata.ErrVideoUnavailable
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("youtube responded %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// parseISODuration converts an ISO 8601 duration such as PT1H2M3S to seconds.
+func parseISODuration(s string) (int, error) {
+	m := isoDurationRe.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	var seconds int
+	for i, unit := range []int{86400, 3600, 60, 1} {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return 0, err
+		}
+		seconds += n * unit
+	}
+
+	return seconds, nil
+}
@@ -0,0 +1,110 @@
+package metadataimpl
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"funny-project-be/domain/metadata"
+	"funny-project-be/infra/options"
+)
+
+func newYouTubeServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oembed", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("url") {
+		case "https://www.youtube.com/watch?v=dQw4w9WgXcQ":
+			w.Write([]byte(`{
+				"title": "Never Gonna Give You Up",
+				"author_name": "Rick Astley",
+				"thumbnail_url": "https://i.ytimg.com/vi/dQw4w9WgXcQ/hqdefault.jpg"
+			}`))
+		case "https://www.youtube.com/watch?v=private":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	mux.HandleFunc("/v3/videos", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "test-key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		assert.Equal(t, "dQw4w9WgXcQ", r.URL.Query().Get("id"))
+		w.Write([]byte(`{"items":[{"contentDetails":{"duration":"PT3M33S"}}]}`))
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestYouTubeResolver_Resolve(t *testing.T) {
+	srv := newYouTubeServer(t)
+	r := NewYouTubeResolver(options.Options{
+		YouTubeOEmbedURL: srv.URL + "/oembed",
+		YouTubeAPIURL:    srv.URL + "/v3",
+		YouTubeAPIKey:    "test-key",
+	}, slog.Default())
+
+	video, err := r.Resolve(context.Background(), "https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	require.NoError(t, err)
+	assert.Equal(t, &metadata.Video{
+		Title:           "Never Gonna Give You Up",
+		ThumbnailURL:    "https://i.ytimg.com/vi/dQw4w9WgXcQ/hqdefault.jpg",
+		ChannelName:     "Rick Astley",
+		DurationSeconds: 213,
+	}, video)
+}
+
+func TestYouTubeResolver_ResolveWithoutAPIKey(t *testing.T) {
+	srv := newYouTubeServer(t)
+	r := NewYouTubeResolver(options.Options{
+		YouTubeOEmbedURL: srv.URL + "/oembed",
+		YouTubeAPIURL:    srv.URL + "/v3",
+	}, slog.Default())
+
+	video, err := r.Resolve(context.Background(), "https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	require.NoError(t, err)
+	assert.Equal(t, "Never Gonna Give You Up", video.Title)
+	assert.Zero(t, video.DurationSeconds)
+}
+
+func TestYouTubeResolver_ResolveUnavailable(t *testing.T) {
+	srv := newYouTubeServer(t)
+	r := NewYouTubeResolver(options.Options{YouTubeOEmbedURL: srv.URL + "/oembed"}, slog.Default())
+
+	_, err := r.Resolve(context.Background(), "https://www.youtube.com/watch?v=private")
+	assert.ErrorIs(t, err, metadata.ErrVideoUnavailable)
+
+	_, err = r.Resolve(context.Background(), "https://www.youtube.com/watch?v=unknown")
+	assert.ErrorIs(t, err, metadata.ErrVideoUnavailable)
+}
+
+func TestParseISODuration(t *testing.T) {
+	cases := map[string]int{
+		"PT0S":      0,
+		"PT45S":     45,
+		"PT3M33S":   213,
+		"PT1H2M3S":  3723,
+		"P1DT1S":    86401,
+		"PT10M":     600,
+		"P0D":       0,
+		"PT2H":      7200,
+		"PT1H0M10S": 3610,
+	}
+	for in, want := range cases {
+		got, err := parseISODuration(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	_, err := parseISODuration("3:33")
+	assert.Error(t, err)
+}
@@ -0,0 +1,97 @@
+package oauthimpl
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+
+	"funny-project-be/domain/oauth"
+	"funny-project-be/infra/options"
+)
+
+// GitHubProvider authenticates users with GitHub OAuth apps.
+type GitHubProvider struct {
+	client *http.Client
+	config oauth2.Config
+	apiURL string
+}
+
+type gitHubUser struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+type gitHubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// NewGitHubProvider creates and returns a new instance of GitHubProvider.
+func NewGitHubProvider(opts options.Options) *GitHubProvider {
+	return newGitHubProvider(opts, github.Endpoint)
+}
+
+func newGitHubProvider(opts options.Options, endpoint oauth2.Endpoint) *GitHubProvider {
+	return &GitHubProvider{
+		client: &http.Client{Timeout: requestTimeout},
+		config: oauth2.Config{
+			ClientID:     opts.GitHubClientID,
+			ClientSecret: opts.GitHubClientSecret,
+			Endpoint:     endpoint,
+			Scopes:       []string{"read:user", "user:email"},
+		},
+		apiURL: strings.TrimSuffix(opts.GitHubAPIURL, "/"),
+	}
+}
+
+// AuthCodeURL returns the URL of the consent page, which redirects to redirectURL with state.
+func (p *GitHubProvider) AuthCodeURL(ctx context.Context, state string, redirectURL string, opts ...oauth2.AuthCodeOption) (string, error) {
+	return authCodeURL(p.config, state, redirectURL, opts...), nil
+}
+
+// Exchange exchanges an authorization code for a token.
+func (p *GitHubProvider) Exchange(ctx context.Context, code string, redirectURL string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	return exchange(ctx, p.client, p.config, code, redirectURL, opts...)
+}
+
+// Profile fetches the profile of the user owning token.
+// The email is the primary address of the account, which may be private.
+// GitHub does not support OpenID Connect, so nonce is ignored.
+func (p *GitHubProvider) Profile(ctx context.Context, token *oauth2.Token, nonce string) (*oauth.Profile, error) {
+	client := authClient(ctx, p.client, p.config, token)
+
+	var user gitHubUser
+	if err := getJSON(ctx, client, p.apiURL+"/user", &user); err != nil {
+		return nil, err
+	}
+
+	var emails []gitHubEmail
+	if err := getJSON(ctx, client, p.apiURL+"/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	profile := &oauth.Profile{
+		Subject: strconv.FormatInt(user.ID, 10),
+		Name:    user.Name,
+		Picture: user.AvatarURL,
+	}
+	if profile.Name == "" {
+		profile.Name = user.Login
+	}
+	for _, email := range emails {
+		if email.Primary {
+			profile.Email = email.Email
+			profile.EmailVerified = email.Verified
+			break
+		}
+	}
+
+	return profile, nil
+}
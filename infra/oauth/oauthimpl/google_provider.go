@@ -0,0 +1,122 @@
+package oauthimpl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"funny-project-be/domain/oauth"
+	"funny-project-be/infra/options"
+)
+
+// googleIssuers are the iss claims of Google id_tokens.
+var googleIssuers = []string{"https://accounts.google.com", "accounts.google.com"}
+
+// GoogleProvider authenticates users with Google OAuth 2.0.
+// The profile is read from the id_token of the exchange, verified against the
+// keys of jwks.
+type GoogleProvider struct {
+	client *http.Client
+	config oauth2.Config
+	jwks   JWKSFetcher
+}
+
+// googleClaims are the claims of a Google id_token.
+type googleClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+	Nonce         string `json:"nonce"`
+	jwt.StandardClaims
+}
+
+// NewGoogleProvider creates and returns a new instance of GoogleProvider.
+func NewGoogleProvider(opts options.Options) *GoogleProvider {
+	return newGoogleProvider(opts, google.Endpoint, NewCachedJWKS(opts.GAuthJWKSURL))
+}
+
+func newGoogleProvider(opts options.Options, endpoint oauth2.Endpoint, jwks JWKSFetcher) *GoogleProvider {
+	return &GoogleProvider{
+		client: &http.Client{Timeout: requestTimeout},
+		config: oauth2.Config{
+			ClientID:     opts.GAuthClientID,
+			ClientSecret: opts.GAuthClientSecret,
+			Endpoint:     endpoint,
+			Scopes:       []string{"openid", "email", "profile"},
+		},
+		jwks: jwks,
+	}
+}
+
+// AuthCodeURL returns the URL of the consent page, which redirects to redirectURL with state.
+func (p *GoogleProvider) AuthCodeURL(ctx context.Context, state string, redirectURL string, opts ...oauth2.AuthCodeOption) (string, error) {
+	return authCodeURL(p.config, state, redirectURL, opts...), nil
+}
+
+// Exchange exchanges an authorization code for a token.
+func (p *GoogleProvider) Exchange(ctx context.Context, code string, redirectURL string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	return exchange(ctx, p.client, p.config, code, redirectURL, opts...)
+}
+
+// Profile verifies the id_token of token and returns the profile it carries.
+// Users whose email is not verified by Google are rejected.
+func (p *GoogleProvider) Profile(ctx context.Context, token *oauth2.Token, nonce string) (*oauth.Profile, error) {
+	idToken, _ := token.Extra("id_token").(string)
+	if idToken == "" {
+		return nil, errors.New("google did not return an id_token")
+	}
+
+	claims, err := p.verify(ctx, idToken)
+	if err != nil {
+		return nil, err
+	}
+	if nonce != "" && claims.Nonce != nonce {
+		return nil, oauth.ErrNonceMismatch
+	}
+	if !claims.EmailVerified {
+		return nil, oauth.ErrEmailNotVerified
+	}
+
+	return &oauth.Profile{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+		Picture:       claims.Picture,
+	}, nil
+}
+
+// verify checks the signature, audience, issuer and expiry of idToken.
+func (p *GoogleProvider) verify(ctx context.Context, idToken string) (*googleClaims, error) {
+	var claims googleClaims
+	_, err := jwt.ParseWithClaims(idToken, &claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		kid, _ := t.Header["kid"].(string)
+		return p.jwks.Key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	if !claims.VerifyAudience(p.config.ClientID, true) {
+		return nil, errors.New("invalid id_token: audience mismatch")
+	}
+	if !claims.VerifyIssuer(googleIssuers[0], true) && !claims.VerifyIssuer(googleIssuers[1], true) {
+		return nil, errors.New("invalid id_token: issuer mismatch")
+	}
+	// ParseWithClaims only checks exp when it is present.
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, errors.New("invalid id_token: token is expired")
+	}
+
+	return &claims, nil
+}
@@ -0,0 +1,162 @@
+package oauthimpl
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultJWKSMaxAge is how long keys are cached when the response has no max-age.
+	defaultJWKSMaxAge = time.Hour
+
+	// minJWKSRefresh is the minimal delay between two fetches triggered by unknown kids.
+	minJWKSRefresh = time.Minute
+)
+
+// JWKSFetcher returns the public keys verifying the id_tokens of an identity provider.
+type JWKSFetcher interface {
+	// Key returns the public key identified by kid.
+	Key(ctx context.Context, kid string) (*rsa.PublicKey, error)
+}
+
+// CachedJWKS fetches the RSA keys of a JSON Web Key Set URL and caches them as
+// long as the response allows. An unknown kid refreshes the cache, since it
+// usually means that the provider rotated its keys.
+type CachedJWKS struct {
+	client *http.Client
+	url    string
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	expiresAt time.Time
+	fetchedAt time.Time
+}
+
+type jwksResponse struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// NewCachedJWKS creates and returns a new instance of CachedJWKS.
+func NewCachedJWKS(url string) *CachedJWKS {
+	return &CachedJWKS{
+		client: &http.Client{Timeout: requestTimeout},
+		url:    url,
+	}
+}
+
+// Key returns the public key identified by kid.
+func (j *CachedJWKS) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	key, ok := j.keys[kid]
+	if ok && now.Before(j.expiresAt) {
+		return key, nil
+	}
+	if !ok && now.Sub(j.fetchedAt) < minJWKSRefresh && now.Before(j.expiresAt) {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+
+	if err := j.fetch(ctx, now); err != nil {
+		return nil, err
+	}
+	if key, ok = j.keys[kid]; !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+
+	return key, nil
+}
+
+// fetch replaces the cached keys with the ones served at the URL.
+func (j *CachedJWKS) fetch(ctx context.Context, now time.Time) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded %s", req.URL.Host, resp.Status)
+	}
+
+	var body jwksResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(body.Keys))
+	for _, k := range body.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		key, err := parseRSAJWK(k.N, k.E)
+		if err != nil {
+			return fmt.Errorf("jwk %s: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+
+	j.keys = keys
+	j.fetchedAt = now
+	j.expiresAt = now.Add(maxAge(resp.Header.Get("Cache-Control")))
+
+	return nil
+}
+
+// parseRSAJWK parses the base64url encoded modulus and exponent of an RSA JWK.
+func parseRSAJWK(n string, e string) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, err
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, err
+	}
+	exponent := new(big.Int).SetBytes(eBytes)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("invalid exponent")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nBytes),
+		E: int(exponent.Int64()),
+	}, nil
+}
+
+// maxAge returns the max-age of a Cache-Control header, or defaultJWKSMaxAge.
+func maxAge(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(directive), "=")
+		if !ok || !strings.EqualFold(name, "max-age") {
+			continue
+		}
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			break
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	return defaultJWKSMaxAge
+}
@@ -0,0 +1,93 @@
+package oauthimpl
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newJWKSServer serves the keys of *keys and counts the requests.
+func newJWKSServer(t *testing.T, keys *map[string]*rsa.PublicKey, cacheControl string) (*httptest.Server, *int32) {
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+
+		var body jwksResponse
+		for kid, key := range *keys {
+			body.Keys = append(body.Keys, struct {
+				Kty string `json:"kty"`
+				Kid string `json:"kid"`
+				N   string `json:"n"`
+				E   string `json:"e"`
+			}{
+				Kty: "RSA",
+				Kid: kid,
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		w.Header().Set("Cache-Control", cacheControl)
+		json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &fetches
+}
+
+func TestCachedJWKS(t *testing.T) {
+	first, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	second, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	keys := map[string]*rsa.PublicKey{"first": &first.PublicKey}
+	srv, fetches := newJWKSServer(t, &keys, "public, max-age=3600")
+	jwks := NewCachedJWKS(srv.URL)
+	ctx := context.Background()
+
+	key, err := jwks.Key(ctx, "first")
+	require.NoError(t, err)
+	assert.True(t, first.PublicKey.Equal(key))
+
+	// Known keys are served from the cache.
+	_, err = jwks.Key(ctx, "first")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(fetches))
+
+	// Unknown kids refresh the cache at most once per minJWKSRefresh.
+	keys["second"] = &second.PublicKey
+	_, err = jwks.Key(ctx, "second")
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(fetches))
+
+	jwks.fetchedAt = time.Now().Add(-minJWKSRefresh)
+	key, err = jwks.Key(ctx, "second")
+	require.NoError(t, err)
+	assert.True(t, second.PublicKey.Equal(key))
+	assert.Equal(t, int32(2), atomic.LoadInt32(fetches))
+
+	// Expired caches are refreshed, dropping the retired keys.
+	delete(keys, "first")
+	jwks.expiresAt = time.Now()
+	_, err = jwks.Key(ctx, "first")
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(fetches))
+}
+
+func TestMaxAge(t *testing.T) {
+	assert.Equal(t, 19*time.Minute, maxAge("public, max-age=1140, must-revalidate, no-transform"))
+	assert.Equal(t, defaultJWKSMaxAge, maxAge(""))
+	assert.Equal(t, defaultJWKSMaxAge, maxAge("no-cache"))
+	assert.Equal(t, defaultJWKSMaxAge, maxAge("max-age=abc"))
+}
@@ -0,0 +1,138 @@
+package oauthimpl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+
+	"funny-project-be/domain/oauth"
+	"funny-project-be/infra/options"
+)
+
+// OIDCProvider authenticates users with any OpenID Connect provider.
+// Its endpoints are discovered from the issuer on first use.
+type OIDCProvider struct {
+	client    *http.Client
+	issuerURL string
+	config    oauth2.Config
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+}
+
+// oidcDiscovery is the subset of the OpenID provider metadata the provider uses.
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcUserinfo struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+}
+
+// NewOIDCProvider creates and returns a new instance of OIDCProvider.
+func NewOIDCProvider(opts options.Options) *OIDCProvider {
+	return &OIDCProvider{
+		client:    &http.Client{Timeout: requestTimeout},
+		issuerURL: strings.TrimSuffix(opts.OIDCIssuerURL, "/"),
+		config: oauth2.Config{
+			ClientID:     opts.OIDCClientID,
+			ClientSecret: opts.OIDCClientSecret,
+			Scopes:       []string{"openid", "email", "profile"},
+		},
+	}
+}
+
+// discover fetches and caches the metadata of the issuer.
+// Failures are not cached, so a provider which was down is retried on the next login.
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := getJSON(ctx, p.client, p.issuerURL+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.issuerURL {
+		return nil, fmt.Errorf("oidc issuer mismatch: got %q, want %q", discovery.Issuer, p.issuerURL)
+	}
+	p.discovery = &discovery
+
+	return p.discovery, nil
+}
+
+// oauth2Config returns the OAuth 2.0 config with the discovered endpoints.
+func (p *OIDCProvider) oauth2Config(ctx context.Context) (oauth2.Config, *oidcDiscovery, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return oauth2.Config{}, nil, err
+	}
+
+	conf := p.config
+	conf.Endpoint = oauth2.Endpoint{
+		AuthURL:  discovery.AuthorizationEndpoint,
+		TokenURL: discovery.TokenEndpoint,
+	}
+
+	return conf, discovery, nil
+}
+
+// AuthCodeURL returns the URL of the consent page, which redirects to redirectURL with state.
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state string, redirectURL string, opts ...oauth2.AuthCodeOption) (string, error) {
+	conf, _, err := p.oauth2Config(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return authCodeURL(conf, state, redirectURL, opts...), nil
+}
+
+// Exchange exchanges an authorization code for a token.
+func (p *OIDCProvider) Exchange(ctx context.Context, code string, redirectURL string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	conf, _, err := p.oauth2Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return exchange(ctx, p.client, conf, code, redirectURL, opts...)
+}
+
+// Profile fetches the profile of the user owning token from the userinfo endpoint.
+func (p *OIDCProvider) Profile(ctx context.Context, token *oauth2.Token, nonce string) (*oauth.Profile, error) {
+	if err := checkNonce(token, nonce); err != nil {
+		return nil, err
+	}
+
+	conf, discovery, err := p.oauth2Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var info oidcUserinfo
+	if err := getJSON(ctx, authClient(ctx, p.client, conf, token), discovery.UserinfoEndpoint, &info); err != nil {
+		return nil, err
+	}
+
+	return &oauth.Profile{
+		Subject:       info.Subject,
+		Email:         info.Email,
+		EmailVerified: info.EmailVerified,
+		Name:          info.Name,
+		Picture:       info.Picture,
+	}, nil
+}
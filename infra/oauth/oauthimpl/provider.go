@@ -0,0 +1,106 @@
+package oauthimpl
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"funny-project-be/domain/oauth"
+	"funny-project-be/infra/options"
+)
+
+// requestTimeout bounds every call to an identity provider.
+const requestTimeout = 10 * time.Second
+
+// NewProviders creates the identity providers configured in opts, keyed by name.
+// Google is always available, GitHub and OIDC once their client id is set.
+func NewProviders(opts options.Options) map[string]oauth.IdentityProvider {
+	providers := map[string]oauth.IdentityProvider{
+		oauth.ProviderGoogle: NewGoogleProvider(opts),
+	}
+	if opts.GitHubClientID != "" {
+		providers[oauth.ProviderGitHub] = NewGitHubProvider(opts)
+	}
+	if opts.OIDCClientID != "" {
+		providers[oauth.ProviderOIDC] = NewOIDCProvider(opts)
+	}
+
+	return providers
+}
+
+// authCodeURL returns the URL of the consent page of conf redirecting to redirectURL.
+func authCodeURL(conf oauth2.Config, state string, redirectURL string, opts ...oauth2.AuthCodeOption) string {
+	conf.RedirectURL = redirectURL
+
+	return conf.AuthCodeURL(state, opts...)
+}
+
+// exchange exchanges code for a token using conf with the http client of the provider.
+func exchange(ctx context.Context, client *http.Client, conf oauth2.Config, code string, redirectURL string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	conf.RedirectURL = redirectURL
+
+	return conf.Exchange(context.WithValue(ctx, oauth2.HTTPClient, client), code, opts...)
+}
+
+// checkNonce checks that the id_token of token carries nonce, unless nonce is empty.
+// The id_token comes straight from the token endpoint over TLS, so its signature
+// does not need to be checked (OpenID Connect Core 3.1.3.7).
+func checkNonce(token *oauth2.Token, nonce string) error {
+	if nonce == "" {
+		return nil
+	}
+
+	idToken, _ := token.Extra("id_token").(string)
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return oauth.ErrNonceMismatch
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return oauth.ErrNonceMismatch
+	}
+
+	var claims struct {
+		Nonce string `json:"nonce"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Nonce != nonce {
+		return oauth.ErrNonceMismatch
+	}
+
+	return nil
+}
+
+// getJSON fetches rawURL with client and decodes the JSON response into v.
+func getJSON(ctx context.Context, client *http.Client, rawURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded %s", req.URL.Host, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// authClient returns a client of conf sending token, built on top of client.
+func authClient(ctx context.Context, client *http.Client, conf oauth2.Config, token *oauth2.Token) *http.Client {
+	authed := conf.Client(context.WithValue(ctx, oauth2.HTTPClient, client), token)
+	authed.Timeout = requestTimeout
+
+	return authed
+}
@@ -0,0 +1,275 @@
+package oauthimpl
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"funny-project-be/domain/oauth"
+	"funny-project-be/infra/options"
+)
+
+const (
+	testCode        = "the-code"
+	testAccessToken = "the-access-token"
+	testRedirectURL = "http://localhost:3000/login"
+	testVerifier    = "the-code-verifier-the-code-verifier-the-code-verifier"
+	testNonce       = "the-nonce"
+)
+
+// testIDToken is an unsigned id_token carrying testNonce.
+var testIDToken = "e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"nonce":"`+testNonce+`"}`)) + ".sig"
+
+// newFakeServer starts an OAuth server which exchanges testCode for testAccessToken
+// and idToken, and serves the JSON documents of routes to requests bearing it.
+func newFakeServer(t *testing.T, idToken string, routes map[string]interface{}) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != testCode ||
+			r.FormValue("redirect_uri") != testRedirectURL ||
+			r.FormValue("code_verifier") != testVerifier {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": testAccessToken,
+			"id_token":     idToken,
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	})
+	for path, doc := range routes {
+		doc := doc
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer "+testAccessToken {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(doc)
+		})
+	}
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// login runs the consent URL, code exchange and profile fetch of p.
+// checksNonce tells whether p supports OpenID Connect.
+func login(t *testing.T, p oauth.IdentityProvider, checksNonce bool) *oauth.Profile {
+	ctx := context.Background()
+
+	rawURL, err := p.AuthCodeURL(ctx, "the-state", testRedirectURL, oauth2.S256ChallengeOption(testVerifier))
+	require.NoError(t, err)
+	authURL, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	assert.Equal(t, "the-state", authURL.Query().Get("state"))
+	assert.Equal(t, testRedirectURL, authURL.Query().Get("redirect_uri"))
+	assert.Equal(t, oauth2.S256ChallengeFromVerifier(testVerifier), authURL.Query().Get("code_challenge"))
+
+	_, err = p.Exchange(ctx, "wrong-code", testRedirectURL, oauth2.VerifierOption(testVerifier))
+	assert.Error(t, err)
+	_, err = p.Exchange(ctx, testCode, testRedirectURL, oauth2.VerifierOption("wrong-verifier"))
+	assert.Error(t, err)
+
+	token, err := p.Exchange(ctx, testCode, testRedirectURL, oauth2.VerifierOption(testVerifier))
+	require.NoError(t, err)
+	assert.Equal(t, testAccessToken, token.AccessToken)
+
+	_, err = p.Profile(ctx, token, "another-nonce")
+	if checksNonce {
+		assert.ErrorIs(t, err, oauth.ErrNonceMismatch)
+	} else {
+		assert.NoError(t, err)
+	}
+
+	profile, err := p.Profile(ctx, token, testNonce)
+	require.NoError(t, err)
+
+	return profile
+}
+
+// staticJWKS serves a fixed set of keys.
+type staticJWKS map[string]*rsa.PublicKey
+
+func (s staticJWKS) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	key, ok := s[kid]
+	if !ok {
+		return nil, errors.New("unknown kid")
+	}
+	return key, nil
+}
+
+// googleIDToken signs claims with key as Google would, overriding the valid defaults.
+func googleIDToken(t *testing.T, key *rsa.PrivateKey, overrides jwt.MapClaims) string {
+	claims := jwt.MapClaims{
+		"iss":            "https://accounts.google.com",
+		"aud":            "google-client",
+		"sub":            "1234",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"name":           "Alice",
+		"picture":        "https://example.com/alice.png",
+		"nonce":          testNonce,
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(claims, k)
+			continue
+		}
+		claims[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "google-kid"
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+
+	return signed
+}
+
+func TestGoogleProvider(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	jwks := staticJWKS{"google-kid": &key.PublicKey}
+	opts := options.Options{GAuthClientID: "google-client"}
+
+	srv := newFakeServer(t, googleIDToken(t, key, nil), nil)
+	p := newGoogleProvider(opts, oauth2.Endpoint{TokenURL: srv.URL + "/token"}, jwks)
+	profile := login(t, p, true)
+
+	assert.Equal(t, &oauth.Profile{
+		Subject:       "1234",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		Name:          "Alice",
+		Picture:       "https://example.com/alice.png",
+	}, profile)
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	for name, tc := range map[string]struct {
+		idToken string
+		err     error
+	}{
+		"other key":         {idToken: googleIDToken(t, other, nil)},
+		"other audience":    {idToken: googleIDToken(t, key, jwt.MapClaims{"aud": "another-client"})},
+		"other issuer":      {idToken: googleIDToken(t, key, jwt.MapClaims{"iss": "https://evil.example.com"})},
+		"expired":           {idToken: googleIDToken(t, key, jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()})},
+		"no expiry":         {idToken: googleIDToken(t, key, jwt.MapClaims{"exp": nil})},
+		"unverified email":  {idToken: googleIDToken(t, key, jwt.MapClaims{"email_verified": false}), err: oauth.ErrEmailNotVerified},
+		"other nonce":       {idToken: googleIDToken(t, key, jwt.MapClaims{"nonce": "another-nonce"}), err: oauth.ErrNonceMismatch},
+		"missing id_token":  {idToken: ""},
+		"unsigned id_token": {idToken: testIDToken},
+	} {
+		t.Run(name, func(t *testing.T) {
+			token := (&oauth2.Token{AccessToken: testAccessToken}).WithExtra(map[string]interface{}{"id_token": tc.idToken})
+			_, err := p.Profile(context.Background(), token, testNonce)
+			require.Error(t, err)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestGitHubProvider(t *testing.T) {
+	srv := newFakeServer(t, testIDToken, map[string]interface{}{
+		"/api/user": map[string]interface{}{
+			"id":         42,
+			"login":      "bob",
+			"avatar_url": "https://example.com/bob.png",
+		},
+		"/api/user/emails": []map[string]interface{}{
+			{"email": "bob@old.example.com", "primary": false, "verified": true},
+			{"email": "bob@example.com", "primary": true, "verified": true},
+		},
+	})
+
+	p := newGitHubProvider(options.Options{GitHubAPIURL: srv.URL + "/api/"}, oauth2.Endpoint{TokenURL: srv.URL + "/token"})
+	profile := login(t, p, false)
+
+	assert.Equal(t, &oauth.Profile{
+		Subject:       "42",
+		Email:         "bob@example.com",
+		EmailVerified: true,
+		Name:          "bob",
+		Picture:       "https://example.com/bob.png",
+	}, profile)
+}
+
+func TestOIDCProvider(t *testing.T) {
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	srv = httptest.NewServer(mux)
+	defer srv.Close()
+
+	fake := newFakeServer(t, testIDToken, map[string]interface{}{
+		"/userinfo": map[string]interface{}{
+			"sub":            "carol-sub",
+			"email":          "carol@example.com",
+			"email_verified": false,
+			"name":           "Carol",
+		},
+	})
+	discoveries := 0
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		discoveries++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 srv.URL,
+			"authorization_endpoint": fake.URL + "/authorize",
+			"token_endpoint":         fake.URL + "/token",
+			"userinfo_endpoint":      fake.URL + "/userinfo",
+		})
+	})
+
+	p := NewOIDCProvider(options.Options{OIDCIssuerURL: srv.URL + "/", OIDCClientID: "client"})
+	profile := login(t, p, true)
+
+	assert.Equal(t, &oauth.Profile{
+		Subject: "carol-sub",
+		Email:   "carol@example.com",
+		Name:    "Carol",
+	}, profile)
+	assert.Equal(t, 1, discoveries)
+}
+
+func TestOIDCProvider_IssuerMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":         "https://evil.example.com",
+			"token_endpoint": "https://evil.example.com/token",
+		})
+	}))
+	defer srv.Close()
+
+	p := NewOIDCProvider(options.Options{OIDCIssuerURL: srv.URL, OIDCClientID: "client"})
+	_, err := p.Exchange(context.Background(), testCode, testRedirectURL)
+	assert.ErrorContains(t, err, "issuer mismatch")
+}
+
+func TestNewProviders(t *testing.T) {
+	providers := NewProviders(options.Options{})
+	assert.Len(t, providers, 1)
+	assert.Contains(t, providers, oauth.ProviderGoogle)
+
+	providers = NewProviders(options.Options{GitHubClientID: "gh", OIDCClientID: "oidc"})
+	assert.Len(t, providers, 3)
+}
@@ -1,28 +1,81 @@
 package options
 
 import (
+	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/beego/beego"
 	"github.com/mitchellh/mapstructure"
+
+	"funny-project-be/domain/ratelimit"
 )
 
 // Options represents the options of service.
 type Options struct {
 	FEURL string `mapstructure:"fe_url"`
 
+	// LogFormat is either "text" or "json".
+	LogFormat string `mapstructure:"log_format"`
+	// LogLevel is the lowest level logged: "debug", "info", "warn" or "error".
+	LogLevel slog.Level `mapstructure:"log_level"`
+
 	DBUser string `mapstructure:"db_user"`
 	DBPass string `mapstructure:"db_pass"`
 	DBHost string `mapstructure:"db_host"`
 	DBPort string `mapstructure:"db_port"`
 	DBName string `mapstructure:"db_name"`
 
+	// PubSubBackend is either "memory" (single replica) or "postgres".
+	PubSubBackend string `mapstructure:"pubsub_backend"`
+
+	// RateLimitBackend is either "memory" (single replica) or "postgres".
+	RateLimitBackend string `mapstructure:"rate_limit_backend"`
+	// RateLimitShare limits the videos shared per user, e.g. "10/1h".
+	RateLimitShare ratelimit.Limit `mapstructure:"rate_limit_share"`
+	// RateLimitLogin limits the logins per client IP.
+	RateLimitLogin ratelimit.Limit `mapstructure:"rate_limit_login"`
+	// RateLimitJoin limits the WebSocket and event stream joins per client IP.
+	RateLimitJoin ratelimit.Limit `mapstructure:"rate_limit_join"`
+
 	AccessTokenExpiresIn time.Duration `mapstructure:"auth_access_token_expires_in"`
 	AccessTokenSecret    string        `mapstructure:"auth_access_token_secret"`
+	// AccessTokenKeyFiles are PEM encoded RSA or P-256 private keys. The first one
+	// signs new access tokens, the others only verify them. Without keys, tokens
+	// are signed with AccessTokenSecret.
+	AccessTokenKeyFiles []string `mapstructure:"auth_access_token_key_files"`
+	// AccessTokenAcceptHS512 keeps accepting the tokens signed with AccessTokenSecret
+	// once AccessTokenKeyFiles are configured, while migrating to them.
+	AccessTokenAcceptHS512 bool `mapstructure:"auth_access_token_accept_hs512"`
+
+	RefreshTokenExpiresIn time.Duration `mapstructure:"auth_refresh_token_expires_in"`
+
+	// AuthRedirectURLs are the only redirect URLs a login may use, compared exactly.
+	AuthRedirectURLs []string `mapstructure:"auth_redirect_urls"`
+	// AuthStateExpiresIn is how long a login started with rpc/auth/start can be completed.
+	AuthStateExpiresIn time.Duration `mapstructure:"auth_state_expires_in"`
+
+	// AdminEmails are given the admin role when they log in with a verified email.
+	AdminEmails []string `mapstructure:"admin_emails"`
 
 	GAuthClientID     string `mapstructure:"gauth_client_id"`
 	GAuthClientSecret string `mapstructure:"gauth_client_secret"`
-	GAuthProfileURL   string `mapstructure:"gauth_profile_url"`
+	// GAuthJWKSURL serves the keys verifying Google id_tokens.
+	GAuthJWKSURL string `mapstructure:"gauth_jwks_url"`
+
+	// GitHub login is enabled once GitHubClientID is set.
+	GitHubClientID     string `mapstructure:"github_client_id"`
+	GitHubClientSecret string `mapstructure:"github_client_secret"`
+	GitHubAPIURL       string `mapstructure:"github_api_url"`
+
+	// OpenID Connect login is enabled once OIDCClientID is set.
+	OIDCIssuerURL    string `mapstructure:"oidc_issuer_url"`
+	OIDCClientID     string `mapstructure:"oidc_client_id"`
+	OIDCClientSecret string `mapstructure:"oidc_client_secret"`
+
+	YouTubeOEmbedURL string `mapstructure:"youtube_oembed_url"`
+	YouTubeAPIURL    string `mapstructure:"youtube_api_url"`
+	YouTubeAPIKey    string `mapstructure:"youtube_api_key"`
 }
 
 // Load loads Options from Viper and returns them.
@@ -37,9 +90,12 @@ func Load() (Options, error) {
 		Metadata: nil,
 		DecodeHook: mapstructure.ComposeDecodeHookFunc(
 			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.TextUnmarshallerHookFunc(),
 			mapstructure.StringToSliceHookFunc(","),
 		),
-		Result: &opts,
+		// Booleans are read from strings.
+		WeaklyTypedInput: true,
+		Result:           &opts,
 	})
 	if err != nil {
 		return Options{}, err
@@ -50,3 +106,8 @@ func Load() (Options, error) {
 
 	return opts, nil
 }
+
+// DatabaseURL returns the connection string of the database.
+func (o Options) DatabaseURL() string {
+	return fmt.Sprintf("postgresql://%s:%s@%s:%s/%s", o.DBUser, o.DBPass, o.DBHost, o.DBPort, o.DBName)
+}
@@ -0,0 +1,80 @@
+package pubsubimpl
+
+import (
+	"context"
+	"sync"
+
+	"funny-project-be/domain/pubsub"
+)
+
+// subscriptionBufferSize is the number of payloads buffered per subscription.
+const subscriptionBufferSize = 64
+
+type subscription struct {
+	ch   chan []byte
+	done <-chan struct{}
+}
+
+// MemoryBroker implements an in-process broker. It only reaches subscribers of the same replica.
+type MemoryBroker struct {
+	mu   sync.RWMutex
+	subs map[string]map[*subscription]struct{}
+}
+
+// NewMemoryBroker creates and returns a new instance of MemoryBroker.
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{subs: make(map[string]map[*subscription]struct{})}
+}
+
+// Publish sends a payload to every subscriber of channel.
+func (b *MemoryBroker) Publish(ctx context.Context, channel string, payload []byte) error {
+	// The limit of the Postgres broker applies too, so that a single replica
+	// fails like a cluster does.
+	if len(payload) > pubsub.MaxPayloadSize {
+		return pubsub.ErrPayloadTooLarge
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subs[channel] {
+		select {
+		case sub.ch <- payload:
+		case <-sub.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// Subscribe returns the payloads published to channel until ctx is done.
+func (b *MemoryBroker) Subscribe(ctx context.Context, channel string) (<-chan []byte, error) {
+	sub := &subscription{
+		ch:   make(chan []byte, subscriptionBufferSize),
+		done: ctx.Done(),
+	}
+
+	b.mu.Lock()
+	if b.subs[channel] == nil {
+		b.subs[channel] = make(map[*subscription]struct{})
+	}
+	b.subs[channel][sub] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		b.mu.Lock()
+		delete(b.subs[channel], sub)
+		if len(b.subs[channel]) == 0 {
+			delete(b.subs, channel)
+		}
+		b.mu.Unlock()
+
+		close(sub.ch)
+	}()
+
+	return sub.ch, nil
+}
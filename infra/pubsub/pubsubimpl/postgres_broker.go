@@ -0,0 +1,110 @@
+package pubsubimpl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+
+	"funny-project-be/domain/pubsub"
+)
+
+// reconnectDelay is the time to wait before listening again after a connection failure.
+const reconnectDelay = time.Second
+
+// PostgresBroker implements a broker on top of Postgres LISTEN/NOTIFY so that
+// every replica connected to the same database receives the payloads.
+type PostgresBroker struct {
+	db  *gorm.DB
+	log *slog.Logger
+}
+
+// NewPostgresBroker creates and returns a new instances of PostgresBroker.
+func NewPostgresBroker(db *gorm.DB, log *slog.Logger) *PostgresBroker {
+	return &PostgresBroker{db: db, log: log}
+}
+
+// Publish sends a payload to every subscriber of channel.
+func (b *PostgresBroker) Publish(ctx context.Context, channel string, payload []byte) error {
+	if len(payload) > pubsub.MaxPayloadSize {
+		return pubsub.ErrPayloadTooLarge
+	}
+
+	return b.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", channel, string(payload)).Error
+}
+
+// Subscribe returns the payloads published to channel until ctx is done.
+// The subscription holds a dedicated connection and reconnects when it is lost.
+func (b *PostgresBroker) Subscribe(ctx context.Context, channel string) (<-chan []byte, error) {
+	sqlDB, err := b.db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	// Fail fast if the first connection cannot be established.
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan []byte, subscriptionBufferSize)
+	go func() {
+		defer close(ch)
+
+		for {
+			err := b.listen(ctx, conn, channel, ch)
+			conn.Close()
+			if ctx.Err() != nil {
+				return
+			}
+			b.log.ErrorContext(ctx, "PostgresBroker listen", "channel", channel, "err", err)
+
+			for {
+				select {
+				case <-time.After(reconnectDelay):
+				case <-ctx.Done():
+					return
+				}
+				if conn, err = sqlDB.Conn(ctx); err == nil {
+					break
+				}
+				b.log.ErrorContext(ctx, "PostgresBroker reconnect", "channel", channel, "err", err)
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// listen runs LISTEN on conn and forwards notifications to ch until an error occurs.
+func (b *PostgresBroker) listen(ctx context.Context, conn *sql.Conn, channel string, ch chan<- []byte) error {
+	return conn.Raw(func(driverConn any) error {
+		stdConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.New("postgres broker requires the pgx driver")
+		}
+		pgConn := stdConn.Conn()
+
+		if _, err := pgConn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return err
+		}
+		defer pgConn.Exec(context.Background(), "UNLISTEN "+pgx.Identifier{channel}.Sanitize())
+
+		for {
+			n, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			select {
+			case ch <- []byte(n.Payload):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package ratelimitimpl
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"funny-project-be/domain/ratelimit"
+)
+
+// sweepInterval is the time between two removals of the full buckets.
+const sweepInterval = time.Minute
+
+type memoryBucket struct {
+	ratelimit.Bucket
+	fullAt time.Time
+}
+
+// MemoryStore implements an in-process store. Each replica limits its own requests.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	sweptAt time.Time
+	now     func() time.Time
+}
+
+// NewMemoryStore creates and returns a new instance of MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket), now: time.Now}
+}
+
+// Take takes a token from the bucket of key for limit. When the bucket is
+// empty, it reports false with the time to wait for the next token.
+func (s *MemoryStore) Take(ctx context.Context, key string, limit ratelimit.Limit) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{Bucket: limit.NewBucket(now)}
+		s.buckets[key] = b
+	}
+	allowed, retryAfter := limit.Take(&b.Bucket, now)
+	b.fullAt = limit.FullAt(b.Bucket)
+
+	return allowed, retryAfter, nil
+}
+
+// sweep removes the buckets which are full again, at most once per sweepInterval.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.sweptAt) < sweepInterval {
+		return
+	}
+	s.sweptAt = now
+
+	for key, b := range s.buckets {
+		if !b.fullAt.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
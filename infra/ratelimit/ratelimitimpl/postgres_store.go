@@ -0,0 +1,101 @@
+package ratelimitimpl
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"funny-project-be/domain/ratelimit"
+)
+
+// rateLimit is a row of the rate_limit table.
+type rateLimit struct {
+	Key       string `gorm:"primaryKey"`
+	Tokens    float64
+	UpdatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// TableName overrides the table name used by rateLimit to `rate_limit`.
+func (rateLimit) TableName() string {
+	return "rate_limit"
+}
+
+// PostgresStore implements a store on top of Postgres so that every replica
+// shares the same buckets.
+type PostgresStore struct {
+	db  *gorm.DB
+	log *slog.Logger
+
+	mu      sync.Mutex
+	sweptAt time.Time
+}
+
+// NewPostgresStore creates and returns a new instances of PostgresStore.
+func NewPostgresStore(db *gorm.DB, log *slog.Logger) *PostgresStore {
+	return &PostgresStore{db: db, log: log}
+}
+
+// Take takes a token from the bucket of key for limit. When the bucket is
+// empty, it reports false with the time to wait for the next token.
+// Concurrent takes of a key are serialized by the lock of its row.
+func (s *PostgresStore) Take(ctx context.Context, key string, limit ratelimit.Limit) (bool, time.Duration, error) {
+	var (
+		allowed    bool
+		retryAfter time.Duration
+	)
+
+	now := time.Now()
+	s.sweep(ctx, now)
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Insert the missing row of a full bucket so that it can be locked.
+		full := limit.NewBucket(now)
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&rateLimit{Key: key, Tokens: full.Tokens, UpdatedAt: now, ExpiresAt: now}).Error; err != nil {
+			return err
+		}
+
+		var row rateLimit
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&row, "key = ?", key).Error; err != nil {
+			return err
+		}
+		bucket := ratelimit.Bucket{Tokens: row.Tokens, UpdatedAt: row.UpdatedAt}
+		if !row.ExpiresAt.After(now) {
+			bucket = full
+		}
+
+		allowed, retryAfter = limit.Take(&bucket, now)
+
+		return tx.Model(&row).Updates(map[string]interface{}{
+			"tokens":     bucket.Tokens,
+			"updated_at": bucket.UpdatedAt,
+			"expires_at": limit.FullAt(bucket),
+		}).Error
+	})
+	if err != nil {
+		return false, 0, err
+	}
+
+	return allowed, retryAfter, nil
+}
+
+// sweep removes the buckets which are full again, at most once per sweepInterval
+// and replica. Errors are only logged, the rows are removed by the next sweep.
+func (s *PostgresStore) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.sweptAt) < sweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.sweptAt = now
+	s.mu.Unlock()
+
+	if err := s.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&rateLimit{}).Error; err != nil {
+		s.log.ErrorContext(ctx, "PostgresStore sweep", "err", err)
+	}
+}
@@ -0,0 +1,8 @@
+DROP TABLE auth_state;
+DROP TABLE revoked_token;
+DROP TABLE refresh_token;
+DROP TABLE comment;
+DROP TABLE reaction;
+DROP TABLE video;
+DROP TABLE user_identity;
+DROP TABLE "user";
@@ -0,0 +1,124 @@
+-- The idempotent base schema: every table and column of the releases which ran
+-- AutoMigrate before migrations were versioned. Their databases have the tables
+-- of users and videos but no recorded version, so every statement completes them.
+-- This version is recorded as video_search_vector, see recordedNames.
+CREATE TABLE IF NOT EXISTS "user" (
+	id bigserial PRIMARY KEY,
+	name varchar(100),
+	email varchar(100),
+	role varchar(20) NOT NULL DEFAULT 'user',
+	avatar varchar(255),
+	banned_at timestamptz,
+	created_at timestamptz,
+	updated_at timestamptz
+);
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS role varchar(20) NOT NULL DEFAULT 'user';
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS avatar varchar(255);
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS banned_at timestamptz;
+CREATE INDEX IF NOT EXISTS idx_user_email ON "user" (email);
+
+CREATE TABLE IF NOT EXISTS user_identity (
+	id bigserial PRIMARY KEY,
+	user_id bigint,
+	provider varchar(20),
+	subject varchar(255),
+	email varchar(100),
+	created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_user_identity_user_id ON user_identity (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_identity_provider_subject ON user_identity (provider, subject);
+
+-- The sharer of the videos of older databases is linked by 0002, which adds
+-- the foreign key of user_id.
+CREATE TABLE IF NOT EXISTS video (
+	id bigserial PRIMARY KEY,
+	url varchar(200),
+	youtube_id varchar(11),
+	description text,
+	user_id bigint CONSTRAINT fk_video_user REFERENCES "user" (id) ON DELETE SET NULL,
+	title varchar(255),
+	thumbnail_url varchar(255),
+	channel_name varchar(100),
+	duration_seconds bigint,
+	hidden_at timestamptz,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz
+);
+ALTER TABLE video ADD COLUMN IF NOT EXISTS youtube_id varchar(11);
+ALTER TABLE video ADD COLUMN IF NOT EXISTS user_id bigint;
+ALTER TABLE video ADD COLUMN IF NOT EXISTS title varchar(255);
+ALTER TABLE video ADD COLUMN IF NOT EXISTS thumbnail_url varchar(255);
+ALTER TABLE video ADD COLUMN IF NOT EXISTS channel_name varchar(100);
+ALTER TABLE video ADD COLUMN IF NOT EXISTS duration_seconds bigint;
+ALTER TABLE video ADD COLUMN IF NOT EXISTS hidden_at timestamptz;
+ALTER TABLE video ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+CREATE INDEX IF NOT EXISTS idx_video_created_at_id ON video (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_video_youtube_id ON video (youtube_id);
+CREATE INDEX IF NOT EXISTS idx_video_user_id ON video (user_id);
+CREATE INDEX IF NOT EXISTS idx_video_deleted_at ON video (deleted_at);
+
+-- The simple configuration does no stemming, which suits descriptions
+-- written in any language. Titles rank above descriptions.
+ALTER TABLE video ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+	setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+	setweight(to_tsvector('simple', coalesce(description, '')), 'B')
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_video_search_vector ON video USING GIN (search_vector);
+
+CREATE TABLE IF NOT EXISTS reaction (
+	id bigserial PRIMARY KEY,
+	user_id bigint,
+	video_id bigint,
+	kind varchar(10),
+	created_at timestamptz,
+	updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reaction_user_video ON reaction (user_id, video_id);
+CREATE INDEX IF NOT EXISTS idx_reaction_video_id ON reaction (video_id);
+
+CREATE TABLE IF NOT EXISTS comment (
+	id bigserial PRIMARY KEY,
+	video_id bigint,
+	user_id bigint,
+	parent_id bigint,
+	body text,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_comment_video_id ON comment (video_id);
+CREATE INDEX IF NOT EXISTS idx_comment_user_id ON comment (user_id);
+CREATE INDEX IF NOT EXISTS idx_comment_parent_id ON comment (parent_id);
+CREATE INDEX IF NOT EXISTS idx_comment_deleted_at ON comment (deleted_at);
+
+CREATE TABLE IF NOT EXISTS refresh_token (
+	id bigserial PRIMARY KEY,
+	user_id bigint,
+	family_id varchar(32),
+	token_hash varchar(64),
+	expires_at timestamptz,
+	revoked_at timestamptz,
+	created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_token_user_id ON refresh_token (user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_token_family_id ON refresh_token (family_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_token_token_hash ON refresh_token (token_hash);
+
+CREATE TABLE IF NOT EXISTS revoked_token (
+	jti varchar(32) PRIMARY KEY,
+	expires_at timestamptz,
+	created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_revoked_token_expires_at ON revoked_token (expires_at);
+
+CREATE TABLE IF NOT EXISTS auth_state (
+	state varchar(32) PRIMARY KEY,
+	provider varchar(20),
+	redirect_url varchar(255),
+	code_verifier varchar(128),
+	nonce varchar(32),
+	expires_at timestamptz,
+	created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_auth_state_expires_at ON auth_state (expires_at);
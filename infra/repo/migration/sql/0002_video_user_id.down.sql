@@ -0,0 +1,5 @@
+-- This rollback is lossy. It restores shared_by from user_id, but the emails
+-- of the sharers who matched no user were dropped with the column. user_id is
+-- kept since 0001 creates it.
+ALTER TABLE video ADD COLUMN shared_by varchar(100);
+UPDATE video SET shared_by = "user".email FROM "user" WHERE video.user_id = "user".id;
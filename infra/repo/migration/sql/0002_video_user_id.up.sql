@@ -0,0 +1,18 @@
+-- Videos used to store the email of their sharer in shared_by. The statements
+-- are no-ops on databases created by 0001, which never had the column.
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS avatar varchar(255);
+ALTER TABLE video ADD COLUMN IF NOT EXISTS user_id bigint;
+CREATE INDEX IF NOT EXISTS idx_video_user_id ON video (user_id);
+
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_video_user') THEN
+		ALTER TABLE video ADD CONSTRAINT fk_video_user FOREIGN KEY (user_id) REFERENCES "user" (id) ON DELETE SET NULL;
+	END IF;
+
+	-- Videos whose sharer is unknown keep a NULL user_id.
+	IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'video' AND column_name = 'shared_by') THEN
+		UPDATE video SET user_id = "user".id FROM "user" WHERE video.user_id IS NULL AND video.shared_by = "user".email;
+		ALTER TABLE video DROP COLUMN shared_by;
+	END IF;
+END $$;
@@ -0,0 +1 @@
+ALTER TABLE "user" ADD COLUMN bio varchar(500);
@@ -0,0 +1,8 @@
+CREATE TABLE follow (
+	id bigserial PRIMARY KEY,
+	follower_id bigint NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+	followee_id bigint NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+	created_at timestamptz
+);
+CREATE UNIQUE INDEX idx_follow_follower_followee ON follow (follower_id, followee_id);
+CREATE INDEX idx_follow_followee_id ON follow (followee_id);
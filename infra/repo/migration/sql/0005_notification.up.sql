@@ -0,0 +1,14 @@
+CREATE TABLE notification (
+	id bigserial PRIMARY KEY,
+	user_id bigint NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+	actor_id bigint,
+	kind varchar(30),
+	video_id bigint,
+	comment_id bigint,
+	reaction_kind varchar(10),
+	read_at timestamptz,
+	created_at timestamptz
+);
+-- Serves the inbox, newest first, and the replay of the WebSocket.
+CREATE INDEX idx_notification_user_id ON notification (user_id, id);
+CREATE INDEX idx_notification_unread ON notification (user_id) WHERE read_at IS NULL;
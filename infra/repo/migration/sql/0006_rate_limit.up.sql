@@ -0,0 +1,8 @@
+-- Token buckets of the rate limiters, a missing row is a full bucket.
+CREATE TABLE rate_limit (
+	key varchar(255) PRIMARY KEY,
+	tokens double precision NOT NULL,
+	updated_at timestamptz NOT NULL,
+	expires_at timestamptz NOT NULL
+);
+CREATE INDEX idx_rate_limit_expires_at ON rate_limit (expires_at);
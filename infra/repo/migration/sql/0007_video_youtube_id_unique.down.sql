@@ -0,0 +1,2 @@
+DROP INDEX idx_video_youtube_id;
+CREATE INDEX idx_video_youtube_id ON video (youtube_id);
@@ -0,0 +1,11 @@
+-- Shares used to be checked for duplicates before their insert only, so that
+-- concurrent shares of a video could both be kept. The first share of each
+-- video is kept and the others are deleted, as their sharers could.
+UPDATE video SET deleted_at = now()
+WHERE deleted_at IS NULL AND EXISTS (
+	SELECT 1 FROM video first
+	WHERE first.youtube_id = video.youtube_id AND first.deleted_at IS NULL AND first.id < video.id
+);
+
+DROP INDEX IF EXISTS idx_video_youtube_id;
+CREATE UNIQUE INDEX idx_video_youtube_id ON video (youtube_id) WHERE deleted_at IS NULL;
@@ -0,0 +1,56 @@
+package repoimpl
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"funny-project-be/domain/entity"
+)
+
+// AuthStateRepo implements methods of auth state's repository.
+type AuthStateRepo struct {
+	db *gorm.DB
+}
+
+// NewAuthStateRepo creates and returns a new instances of AuthStateRepo.
+func NewAuthStateRepo(db *gorm.DB) *AuthStateRepo {
+	return &AuthStateRepo{db: db}
+}
+
+// Add adds new auth states to repo.
+func (r *AuthStateRepo) Add(ctx context.Context, states ...*entity.AuthState) error {
+	for _, state := range states {
+		if err := r.db.WithContext(ctx).Create(state).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Take finds, removes and returns an auth state, so that it can not be used twice.
+// It is a single DELETE ... RETURNING, so two concurrent logins can not both take it.
+func (r *AuthStateRepo) Take(ctx context.Context, state string) (*entity.AuthState, error) {
+	var states []entity.AuthState
+
+	result := r.db.WithContext(ctx).
+		Clauses(clause.Returning{}).
+		Where("state = ?", state).
+		Delete(&states)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(states) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &states[0], nil
+}
+
+// RemoveExpired removes the auth states which expired before t.
+func (r *AuthStateRepo) RemoveExpired(ctx context.Context, t time.Time) error {
+	return r.db.WithContext(ctx).Where("expires_at < ?", t).Delete(&entity.AuthState{}).Error
+}
@@ -0,0 +1,79 @@
+package repoimpl
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"funny-project-be/domain/entity"
+)
+
+// CommentRepo implements methods of comment's repository.
+type CommentRepo struct {
+	db *gorm.DB
+}
+
+// NewCommentRepo creates and returns a new instances of CommentRepo.
+func NewCommentRepo(db *gorm.DB) *CommentRepo {
+	return &CommentRepo{db: db}
+}
+
+// Get finds and returns a comment by id.
+func (r *CommentRepo) Get(ctx context.Context, id uint) (*entity.Comment, error) {
+	var comment entity.Comment
+
+	query := r.db.WithContext(ctx)
+
+	if err := query.First(&comment, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	return &comment, nil
+}
+
+// GetRangeByVideo finds and returns at most limit comments of a video
+// with an id greater than afterID, oldest first.
+func (r *CommentRepo) GetRangeByVideo(ctx context.Context, videoID uint, afterID uint, limit int) ([]*entity.Comment, error) {
+	var comments []*entity.Comment
+
+	q := r.db.WithContext(ctx).Where("video_id = ? AND id > ?", videoID, afterID)
+
+	if err := q.Order("id asc").Limit(limit).Find(&comments).Error; err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// Add adds new comments to repo.
+func (r *CommentRepo) Add(ctx context.Context, comments ...*entity.Comment) error {
+	for _, comment := range comments {
+		if err := r.db.WithContext(ctx).Create(comment).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Remove removes comments from repo.
+func (r *CommentRepo) Remove(ctx context.Context, comments ...*entity.Comment) error {
+	for _, comment := range comments {
+		if err := r.db.WithContext(ctx).Delete(comment).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Update updates comments in repo.
+func (r *CommentRepo) Update(ctx context.Context, comments ...*entity.Comment) error {
+	for _, comment := range comments {
+		if err := r.db.WithContext(ctx).Save(comment).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
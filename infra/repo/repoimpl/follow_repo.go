@@ -0,0 +1,90 @@
+package repoimpl
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"funny-project-be/domain/entity"
+)
+
+// FollowRepo implements methods of follow's repository.
+type FollowRepo struct {
+	db *gorm.DB
+}
+
+// NewFollowRepo creates and returns a new instances of FollowRepo.
+func NewFollowRepo(db *gorm.DB) *FollowRepo {
+	return &FollowRepo{db: db}
+}
+
+// Get finds and returns the follow of followee by follower.
+func (r *FollowRepo) Get(ctx context.Context, followerID uint, followeeID uint) (*entity.Follow, error) {
+	var follow entity.Follow
+
+	query := r.db.WithContext(ctx)
+
+	if err := query.First(&follow, "follower_id = ? AND followee_id = ?", followerID, followeeID).Error; err != nil {
+		return nil, err
+	}
+
+	return &follow, nil
+}
+
+// GetFollowerIDs finds and returns the ids of the followers of a user.
+func (r *FollowRepo) GetFollowerIDs(ctx context.Context, followeeID uint) ([]uint, error) {
+	var ids []uint
+
+	if err := r.db.WithContext(ctx).
+		Model(&entity.Follow{}).
+		Where("followee_id = ?", followeeID).
+		Pluck("follower_id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// CountFollowers counts and returns the followers of a user.
+func (r *FollowRepo) CountFollowers(ctx context.Context, followeeID uint) (int64, error) {
+	var count int64
+
+	if err := r.db.WithContext(ctx).Model(&entity.Follow{}).Where("followee_id = ?", followeeID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// CountFollowing counts and returns the users followed by a user.
+func (r *FollowRepo) CountFollowing(ctx context.Context, followerID uint) (int64, error) {
+	var count int64
+
+	if err := r.db.WithContext(ctx).Model(&entity.Follow{}).Where("follower_id = ?", followerID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Add adds a follow, unless the follower already follows the followee.
+func (r *FollowRepo) Add(ctx context.Context, follow *entity.Follow) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "follower_id"}, {Name: "followee_id"}},
+		DoNothing: true,
+	}).Create(follow).Error
+}
+
+// Remove removes follows from repo.
+func (r *FollowRepo) Remove(ctx context.Context, follows ...*entity.Follow) error {
+	for _, follow := range follows {
+		if err := r.db.WithContext(ctx).
+			Where("follower_id = ? AND followee_id = ?", follow.FollowerID, follow.FolloweeID).
+			Delete(&entity.Follow{}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
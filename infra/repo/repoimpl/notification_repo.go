@@ -0,0 +1,78 @@
+package repoimpl
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"funny-project-be/domain/entity"
+)
+
+// notificationBatchSize is the number of notifications inserted per statement by Add,
+// far below the 65535 parameters Postgres accepts in one.
+const notificationBatchSize = 500
+
+// NotificationRepo implements methods of notification's repository.
+type NotificationRepo struct {
+	db *gorm.DB
+}
+
+// NewNotificationRepo creates and returns a new instances of NotificationRepo.
+func NewNotificationRepo(db *gorm.DB) *NotificationRepo {
+	return &NotificationRepo{db: db}
+}
+
+// GetRangeByUser finds and returns at most limit notifications of a user, newest first,
+// with an id lower than beforeID and greater than afterID. Zero ids are not applied.
+func (r *NotificationRepo) GetRangeByUser(ctx context.Context, userID uint, beforeID uint, afterID uint, limit int) ([]*entity.Notification, error) {
+	var notifications []*entity.Notification
+
+	q := r.db.WithContext(ctx).Preload("Actor").Where("user_id = ?", userID)
+	if beforeID != 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	if afterID != 0 {
+		q = q.Where("id > ?", afterID)
+	}
+
+	if err := q.Order("id desc").Limit(limit).Find(&notifications).Error; err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+// CountUnread counts and returns the notifications of a user which have not been read.
+func (r *NotificationRepo) CountUnread(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+
+	if err := r.db.WithContext(ctx).
+		Model(&entity.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// MarkRead marks the notifications ids of a user as read, or all of them when ids is empty.
+func (r *NotificationRepo) MarkRead(ctx context.Context, userID uint, ids []uint) error {
+	q := r.db.WithContext(ctx).Model(&entity.Notification{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if len(ids) > 0 {
+		q = q.Where("id IN ?", ids)
+	}
+
+	return q.Update("read_at", time.Now()).Error
+}
+
+// Add adds new notifications to repo. Their Actor is only referenced, never saved.
+func (r *NotificationRepo) Add(ctx context.Context, notifications ...*entity.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	return r.db.WithContext(ctx).Omit(clause.Associations).CreateInBatches(notifications, notificationBatchSize).Error
+}
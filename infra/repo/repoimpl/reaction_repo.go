@@ -0,0 +1,110 @@
+package repoimpl
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"funny-project-be/domain/entity"
+)
+
+// ReactionRepo implements methods of reaction's repository.
+type ReactionRepo struct {
+	db *gorm.DB
+}
+
+// NewReactionRepo creates and returns a new instances of ReactionRepo.
+func NewReactionRepo(db *gorm.DB) *ReactionRepo {
+	return &ReactionRepo{db: db}
+}
+
+// Get finds and returns the reaction of a user to a video.
+func (r *ReactionRepo) Get(ctx context.Context, userID uint, videoID uint) (*entity.Reaction, error) {
+	var reaction entity.Reaction
+
+	query := r.db.WithContext(ctx)
+
+	if err := query.First(&reaction, "user_id = ? AND video_id = ?", userID, videoID).Error; err != nil {
+		return nil, err
+	}
+
+	return &reaction, nil
+}
+
+// GetByVideoIDs finds and returns the reactions of a user to the given videos.
+func (r *ReactionRepo) GetByVideoIDs(ctx context.Context, userID uint, videoIDs []uint) ([]*entity.Reaction, error) {
+	var reactions []*entity.Reaction
+
+	if len(videoIDs) == 0 {
+		return reactions, nil
+	}
+
+	query := r.db.WithContext(ctx)
+
+	if err := query.Find(&reactions, "user_id = ? AND video_id IN ?", userID, videoIDs).Error; err != nil {
+		return nil, err
+	}
+
+	return reactions, nil
+}
+
+// CountByVideoIDs counts and returns likes and dislikes keyed by video id.
+func (r *ReactionRepo) CountByVideoIDs(ctx context.Context, videoIDs []uint) (map[uint]*entity.ReactionCount, error) {
+	counts := make(map[uint]*entity.ReactionCount, len(videoIDs))
+
+	if len(videoIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		VideoID uint
+		Kind    string
+		Count   int64
+	}
+	if err := r.db.WithContext(ctx).
+		Model(&entity.Reaction{}).
+		Select("video_id, kind, count(*) AS count").
+		Where("video_id IN ?", videoIDs).
+		Group("video_id, kind").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		count, ok := counts[row.VideoID]
+		if !ok {
+			count = &entity.ReactionCount{VideoID: row.VideoID}
+			counts[row.VideoID] = count
+		}
+		switch row.Kind {
+		case entity.ReactionLike:
+			count.Likes = row.Count
+		case entity.ReactionDislike:
+			count.Dislikes = row.Count
+		}
+	}
+
+	return counts, nil
+}
+
+// Upsert adds a reaction or replaces the kind of the existing one.
+func (r *ReactionRepo) Upsert(ctx context.Context, reaction *entity.Reaction) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "video_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"kind", "updated_at"}),
+	}).Create(reaction).Error
+}
+
+// Remove removes reactions from repo.
+func (r *ReactionRepo) Remove(ctx context.Context, reactions ...*entity.Reaction) error {
+	for _, reaction := range reactions {
+		if err := r.db.WithContext(ctx).
+			Where("user_id = ? AND video_id = ?", reaction.UserID, reaction.VideoID).
+			Delete(&entity.Reaction{}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
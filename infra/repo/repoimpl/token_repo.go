@@ -0,0 +1,110 @@
+package repoimpl
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"funny-project-be/domain/entity"
+)
+
+// RefreshTokenRepo implements methods of refresh token's repository.
+type RefreshTokenRepo struct {
+	db *gorm.DB
+}
+
+// NewRefreshTokenRepo creates and returns a new instances of RefreshTokenRepo.
+func NewRefreshTokenRepo(db *gorm.DB) *RefreshTokenRepo {
+	return &RefreshTokenRepo{db: db}
+}
+
+// GetOneByHash finds and returns a refresh token by the hash of its value.
+func (r *RefreshTokenRepo) GetOneByHash(ctx context.Context, hash string) (*entity.RefreshToken, error) {
+	var token entity.RefreshToken
+
+	query := r.db.WithContext(ctx)
+
+	if err := query.First(&token, "token_hash = ?", hash).Error; err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// Add adds new refresh tokens to repo.
+func (r *RefreshTokenRepo) Add(ctx context.Context, tokens ...*entity.RefreshToken) error {
+	for _, token := range tokens {
+		if err := r.db.WithContext(ctx).Create(token).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Revoke revokes a refresh token by id. It returns false when the token was already revoked.
+// The check and the update are a single statement, so two concurrent refreshes can not both win.
+func (r *RefreshTokenRepo) Revoke(ctx context.Context, id uint) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&entity.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// RevokeFamily revokes every refresh token of a family.
+func (r *RefreshTokenRepo) RevokeFamily(ctx context.Context, familyID string) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeByUser revokes every refresh token of a user.
+func (r *RefreshTokenRepo) RevokeByUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokedTokenRepo implements methods of revoked access token's repository.
+type RevokedTokenRepo struct {
+	db *gorm.DB
+}
+
+// NewRevokedTokenRepo creates and returns a new instances of RevokedTokenRepo.
+func NewRevokedTokenRepo(db *gorm.DB) *RevokedTokenRepo {
+	return &RevokedTokenRepo{db: db}
+}
+
+// IsRevoked reports whether the access token with jti has been revoked.
+func (r *RevokedTokenRepo) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var count int64
+
+	if err := r.db.WithContext(ctx).
+		Model(&entity.RevokedToken{}).
+		Where("jti = ?", jti).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// Add adds new revoked tokens to repo. Revoking a token twice is not an error.
+func (r *RevokedTokenRepo) Add(ctx context.Context, tokens ...*entity.RevokedToken) error {
+	for _, token := range tokens {
+		if err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(token).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package repoimpl
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"funny-project-be/domain/entity"
+)
+
+// UserIdentityRepo implements methods of user identity's repository.
+type UserIdentityRepo struct {
+	db *gorm.DB
+}
+
+// NewUserIdentityRepo creates and returns a new instances of UserIdentityRepo.
+func NewUserIdentityRepo(db *gorm.DB) *UserIdentityRepo {
+	return &UserIdentityRepo{db: db}
+}
+
+// GetOneBySubject finds and returns the identity of subject at provider.
+func (r *UserIdentityRepo) GetOneBySubject(ctx context.Context, provider string, subject string) (*entity.UserIdentity, error) {
+	var identity entity.UserIdentity
+
+	query := r.db.WithContext(ctx)
+
+	if err := query.First(&identity, "provider = ? AND subject = ?", provider, subject).Error; err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+// Add adds new identities to repo.
+func (r *UserIdentityRepo) Add(ctx context.Context, identities ...*entity.UserIdentity) error {
+	for _, identity := range identities {
+		if err := r.db.WithContext(ctx).Create(identity).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -22,7 +22,7 @@ func NewUserRepo(db *gorm.DB) *UserRepo {
 func (r *UserRepo) Get(ctx context.Context, id uint) (*entity.User, error) {
 	var user entity.User
 
-	query := r.db
+	query := r.db.WithContext(ctx)
 
 	if err := query.First(&user, "id = ?", id).Error; err != nil {
 		return nil, err
@@ -35,7 +35,7 @@ func (r *UserRepo) Get(ctx context.Context, id uint) (*entity.User, error) {
 func (r *UserRepo) GetOneByEmail(ctx context.Context, email string) (*entity.User, error) {
 	var user entity.User
 
-	query := r.db
+	query := r.db.WithContext(ctx)
 
 	if err := query.First(&user, "email = ?", email).Error; err != nil {
 		return nil, err
@@ -44,6 +44,30 @@ func (r *UserRepo) GetOneByEmail(ctx context.Context, email string) (*entity.Use
 	return &user, nil
 }
 
+// GetRange finds and returns a range of users, oldest first.
+func (r *UserRepo) GetRange(ctx context.Context, limit int, page int) ([]*entity.User, error) {
+	var users []*entity.User
+
+	query := r.db.WithContext(ctx)
+
+	if err := query.Order("id").Limit(limit).Offset(limit * (page - 1)).Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// Count counts and returns the number of users.
+func (r *UserRepo) Count(ctx context.Context) (int64, error) {
+	var count int64
+
+	if err := r.db.WithContext(ctx).Model(&entity.User{}).Count(&count).Error; err != nil {
+		return -1, err
+	}
+
+	return count, nil
+}
+
 // Add adds new users to repo.
 func (r *UserRepo) Add(ctx context.Context, users ...*entity.User) error {
 	for _, user := range users {
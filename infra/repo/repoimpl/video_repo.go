@@ -3,10 +3,13 @@ package repoimpl
 import (
 	"context"
 	"errors"
-
-	"funny-project-be/domain/entity"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"funny-project-be/domain/entity"
+	"funny-project-be/domain/repo"
 )
 
 // VideoRepo implements methods of video's repository.
@@ -23,7 +26,7 @@ func NewVideoRepo(db *gorm.DB) *VideoRepo {
 func (r *VideoRepo) Get(ctx context.Context, id uint) (*entity.Video, error) {
 	var video entity.Video
 
-	query := r.db
+	query := r.db.WithContext(ctx).Preload("User")
 
 	if err := query.First(&video, "id = ?", id).Error; err != nil {
 		return nil, err
@@ -32,16 +35,34 @@ func (r *VideoRepo) Get(ctx context.Context, id uint) (*entity.Video, error) {
 	return &video, nil
 }
 
-// GetRangeByQuery finds and returns a range of videos.
-func (r *VideoRepo) GetRangeByQuery(ctx context.Context, sort string, limit int, page int) ([]*entity.Video, error) {
+// GetOneByYouTubeID finds and returns a video by its YouTube video id.
+func (r *VideoRepo) GetOneByYouTubeID(ctx context.Context, youtubeID string) (*entity.Video, error) {
+	var video entity.Video
+
+	query := r.db.WithContext(ctx).Preload("User")
+
+	if err := query.First(&video, "youtube_id = ?", youtubeID).Error; err != nil {
+		return nil, err
+	}
+
+	return &video, nil
+}
+
+// GetRangeByVideoQuery finds and returns a range of videos matching query.
+func (r *VideoRepo) GetRangeByVideoQuery(ctx context.Context, query repo.VideoQuery, limit int, page int) ([]*entity.Video, error) {
 	var videos []*entity.Video
 
-	q := r.db
+	q := applyVideoFilter(r.db.WithContext(ctx).Preload("User"), query.Filter)
 
-	if sort != "" {
-		q = q.Order(sort)
-	} else {
-		q = q.Order("id desc")
+	// Columns are quoted by the clause, they never reach the SQL as raw strings.
+	hasID := false
+	for _, s := range query.Sort {
+		q = q.Order(clause.OrderByColumn{Column: clause.Column{Name: string(s.Column)}, Desc: s.Desc})
+		hasID = hasID || s.Column == repo.VideoSortID
+	}
+	if !hasID {
+		// Keep the order stable between pages.
+		q = q.Order(clause.OrderByColumn{Column: clause.Column{Name: string(repo.VideoSortID)}, Desc: true})
 	}
 
 	if err := q.Limit(limit).Offset(limit * (page - 1)).Find(&videos).Error; err != nil {
@@ -54,26 +75,124 @@ func (r *VideoRepo) GetRangeByQuery(ctx context.Context, sort string, limit int,
 	return videos, nil
 }
 
-// Count counts and returns the number of videos.
-func (r *VideoRepo) Count(ctx context.Context) (int64, error) {
+// GetRangeByCursor finds and returns at most limit videos matching filter, newest first,
+// which come after the video created at createdAt with id. A zero id starts from the newest video.
+func (r *VideoRepo) GetRangeByCursor(ctx context.Context, filter repo.VideoFilter, createdAt time.Time, id uint, limit int) ([]*entity.Video, error) {
 	var videos []*entity.Video
-	var count int64
-	q := r.db
-	if err := q.Find(&videos).Count(&count).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return 0, nil
+
+	q := applyVideoFilter(r.db.WithContext(ctx).Preload("User"), filter)
+
+	if id != 0 {
+		q = q.Where("(created_at, id) < (?, ?)", createdAt, id)
+	}
+
+	if err := q.Order("created_at desc, id desc").Limit(limit).Find(&videos).Error; err != nil {
+		return nil, err
+	}
+
+	return videos, nil
+}
+
+// headlineOptions configures the snippets of SearchVideos.
+var headlineOptions = `StartSel="` + repo.HighlightStart + `", StopSel="` + repo.HighlightStop + `", MaxFragments=2, MaxWords=30, MinWords=10`
+
+// SearchVideos finds and returns a range of videos matching the full-text search text,
+// most relevant first, along with the total number of matching videos.
+// The query is raw SQL, so unlike the other methods it excludes deleted videos itself.
+func (r *VideoRepo) SearchVideos(ctx context.Context, text string, limit int, page int) ([]*repo.VideoSearchResult, int64, error) {
+	var total int64
+	if err := r.db.WithContext(ctx).
+		Model(&entity.Video{}).
+		Where("search_vector @@ websearch_to_tsquery('simple', ?) AND hidden_at IS NULL", text).
+		Count(&total).Error; err != nil {
+		return nil, -1, err
+	}
+
+	var rows []struct {
+		entity.Video
+		Rank    float64
+		Snippet string
+	}
+	if err := r.db.WithContext(ctx).Raw(`
+SELECT video.*,
+	ts_rank(search_vector, query) AS rank,
+	ts_headline('simple', coalesce(title, '') || ' ' || coalesce(description, ''), query, ?) AS snippet
+FROM video, websearch_to_tsquery('simple', ?) query
+WHERE search_vector @@ query AND hidden_at IS NULL AND deleted_at IS NULL
+ORDER BY rank DESC, id DESC
+LIMIT ? OFFSET ?`, headlineOptions, text, limit, limit*(page-1)).Scan(&rows).Error; err != nil {
+		return nil, -1, err
+	}
+
+	// Raw queries can not preload, the sharers are loaded by a second query.
+	var userIDs []uint
+	for _, row := range rows {
+		if row.UserID != nil {
+			userIDs = append(userIDs, *row.UserID)
 		}
+	}
+	users := map[uint]*entity.User{}
+	if len(userIDs) > 0 {
+		var found []*entity.User
+		if err := r.db.WithContext(ctx).Find(&found, userIDs).Error; err != nil {
+			return nil, -1, err
+		}
+		for _, user := range found {
+			users[user.ID] = user
+		}
+	}
+
+	results := make([]*repo.VideoSearchResult, 0, len(rows))
+	for i := range rows {
+		if rows[i].UserID != nil {
+			rows[i].User = users[*rows[i].UserID]
+		}
+		results = append(results, &repo.VideoSearchResult{
+			Video:   &rows[i].Video,
+			Rank:    rows[i].Rank,
+			Snippet: rows[i].Snippet,
+		})
+	}
 
+	return results, total, nil
+}
+
+// Count counts and returns the number of videos matching filter.
+func (r *VideoRepo) Count(ctx context.Context, filter repo.VideoFilter) (int64, error) {
+	var count int64
+
+	q := applyVideoFilter(r.db.WithContext(ctx).Model(&entity.Video{}), filter)
+
+	if err := q.Count(&count).Error; err != nil {
 		return -1, err
 	}
 
 	return count, nil
 }
 
-// Add adds new videos to repo.
+// applyVideoFilter adds the conditions of filter to q. Hidden videos are never listed.
+func applyVideoFilter(q *gorm.DB, filter repo.VideoFilter) *gorm.DB {
+	q = q.Where("hidden_at IS NULL")
+	if filter.UserID != 0 {
+		q = q.Where("user_id = ?", filter.UserID)
+	}
+	if filter.FollowedBy != 0 {
+		q = q.Where("user_id IN (SELECT followee_id FROM follow WHERE follower_id = ?)", filter.FollowedBy)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		q = q.Where("created_at > ?", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		q = q.Where("created_at < ?", filter.CreatedBefore)
+	}
+
+	return q
+}
+
+// Add adds new videos to repo. Their User is only referenced, never saved.
 func (r *VideoRepo) Add(ctx context.Context, videos ...*entity.Video) error {
 	for _, video := range videos {
-		if err := r.db.WithContext(ctx).Create(video).Error; err != nil {
+		if err := r.db.WithContext(ctx).Omit(clause.Associations).Create(video).Error; err != nil {
 			return err
 		}
 	}
@@ -92,10 +211,10 @@ func (r *VideoRepo) Remove(ctx context.Context, videos ...*entity.Video) error {
 	return nil
 }
 
-// Update updates videos in repo.
+// Update updates videos in repo. Their User is only referenced, never saved.
 func (r *VideoRepo) Update(ctx context.Context, videos ...*entity.Video) error {
 	for _, video := range videos {
-		if err := r.db.WithContext(ctx).Save(video).Error; err != nil {
+		if err := r.db.WithContext(ctx).Omit(clause.Associations).Save(video).Error; err != nil {
 			return err
 		}
 	}
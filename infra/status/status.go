@@ -13,6 +13,14 @@ const (
 const (
 	// BadRequest error.
 	BadRequest = iota + 400000
+	// BadRequestVideoUnavailable error.
+	BadRequestVideoUnavailable
+	// BadRequestInvalidYouTubeURL error.
+	BadRequestInvalidYouTubeURL
+	// BadRequestInvalidQuery error.
+	BadRequestInvalidQuery
+	// BadRequestInvalidRedirectURL error.
+	BadRequestInvalidRedirectURL
 )
 
 const (
@@ -23,6 +31,8 @@ const (
 const (
 	// Forbidden error.
 	Forbidden = iota + 403000
+	// ForbiddenUserBanned error.
+	ForbiddenUserBanned
 )
 
 const (
@@ -35,6 +45,11 @@ const (
 	Conflict = iota + 409000
 )
 
+const (
+	// TooManyRequests error.
+	TooManyRequests = iota + 429000
+)
+
 const (
 	// InternalServerError error.
 	InternalServerError = iota + 500000
@@ -0,0 +1,244 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Client is a WebSocket connection or a Server-Sent Events stream registered to a Hub.
+type Client struct {
+	IP      string
+	Version int
+
+	hub  *Hub
+	conn *websocket.Conn
+	auth Authenticator
+
+	// Buffered channel of outbound messages.
+	send chan []byte
+
+	mu sync.Mutex
+	// uid is the authenticated user, zero until then.
+	uid    uint
+	topics map[string]bool
+}
+
+// UserID returns the user of the client, or zero while it is not authenticated.
+func (c *Client) UserID() uint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.uid
+}
+
+// isAudience reports whether the client belongs to audience.
+// Clients which are not authenticated belong to none.
+func (c *Client) isAudience(audience *Audience) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.uid == 0 || c.uid == audience.Except {
+		return false
+	}
+	if len(audience.To) == 0 && audience.FollowersOf == 0 && len(audience.Topics) == 0 {
+		return true
+	}
+	for _, uid := range audience.To {
+		if uid == c.uid {
+			return true
+		}
+	}
+	if audience.followers[c.uid] {
+		return true
+	}
+	for _, topic := range audience.Topics {
+		if c.topics[topic] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readPump keeps the read deadline alive with pongs and unregisters the client
+// once the peer goes away. Inbound messages of Version1 clients are commands,
+// the ones of legacy clients are discarded.
+func (c *Client) readPump() {
+	defer func() {
+		c.hub.Unregister(c)
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(c.hub.pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(c.hub.pongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if c.Version == Version1 {
+			c.handle(data)
+		}
+	}
+}
+
+// handle runs the command of a frame and queues its answer.
+func (c *Client) handle(data []byte) {
+	var cmd Envelope
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		c.replyError("", ErrorBadRequest, "frame is not a JSON envelope")
+		return
+	}
+	if cmd.Version != Version1 {
+		c.replyError(cmd.ID, ErrorUnsupportedVersion, fmt.Sprintf("version %d is not supported, use %d", cmd.Version, Version1))
+		return
+	}
+
+	switch cmd.Type {
+	case CommandPing:
+		c.reply(FrameAck, cmd.ID, struct{}{})
+	case CommandAuth:
+		c.authenticate(&cmd)
+	case CommandSubscribe, CommandUnsubscribe:
+		c.subscribe(&cmd)
+	default:
+		c.replyError(cmd.ID, ErrorUnknownCommand, fmt.Sprintf("unknown command %q", cmd.Type))
+	}
+}
+
+// authenticate runs CommandAuth.
+func (c *Client) authenticate(cmd *Envelope) {
+	var payload AuthPayload
+	if err := json.Unmarshal(cmd.Payload, &payload); err != nil || payload.Token == "" {
+		c.replyError(cmd.ID, ErrorBadRequest, "payload must contain the token")
+		return
+	}
+	if c.UserID() != 0 {
+		c.replyError(cmd.ID, ErrorBadRequest, "connection is already authenticated")
+		return
+	}
+
+	ctx := context.Background()
+	uid, err := c.auth.Authenticate(ctx, payload.Token)
+	if err != nil || uid == 0 {
+		c.replyError(cmd.ID, ErrorUnauthorized, "token is invalid")
+		return
+	}
+
+	c.mu.Lock()
+	c.uid = uid
+	c.mu.Unlock()
+
+	if c.reply(FrameAck, cmd.ID, AuthAck{UserID: uid}) {
+		c.auth.Authenticated(ctx, c, payload)
+	}
+}
+
+// subscribe runs CommandSubscribe and CommandUnsubscribe.
+func (c *Client) subscribe(cmd *Envelope) {
+	var payload TopicsPayload
+	if err := json.Unmarshal(cmd.Payload, &payload); err != nil || len(payload.Topics) == 0 {
+		c.replyError(cmd.ID, ErrorBadRequest, "payload must contain the topics")
+		return
+	}
+	for _, topic := range payload.Topics {
+		if !isValidTopic(topic) {
+			c.replyError(cmd.ID, ErrorBadRequest, fmt.Sprintf("topic %q is invalid", topic))
+			return
+		}
+	}
+
+	c.mu.Lock()
+	if c.uid == 0 {
+		c.mu.Unlock()
+		c.replyError(cmd.ID, ErrorUnauthorized, "auth is required first")
+		return
+	}
+	subscribed := cmd.Type == CommandSubscribe
+	topics := make(map[string]bool, len(c.topics)+len(payload.Topics))
+	for topic := range c.topics {
+		topics[topic] = true
+	}
+	for _, topic := range payload.Topics {
+		if subscribed {
+			topics[topic] = true
+		} else {
+			delete(topics, topic)
+		}
+	}
+	if len(topics) > maxTopics {
+		c.mu.Unlock()
+		c.replyError(cmd.ID, ErrorTooManyTopics, fmt.Sprintf("a connection may subscribe to %d topics", maxTopics))
+		return
+	}
+	c.topics = topics
+	c.mu.Unlock()
+
+	ack := TopicsPayload{Topics: make([]string, 0, len(topics))}
+	for topic := range topics {
+		ack.Topics = append(ack.Topics, topic)
+	}
+	sort.Strings(ack.Topics)
+	c.reply(FrameAck, cmd.ID, ack)
+}
+
+// replyError queues the error frame answering the command id.
+func (c *Client) replyError(id string, code string, message string) {
+	c.reply(FrameError, id, ErrorPayload{Code: code, Message: message})
+}
+
+// reply queues the frame of type answering the command id. It reports false
+// when the frame could not be queued, and drops the client whose queue is full.
+func (c *Client) reply(frameType string, id string, payload interface{}) bool {
+	frame, err := reply(frameType, id, payload)
+	if err != nil {
+		return false
+	}
+	if !c.hub.sendFrame(c, frame) {
+		c.hub.Unregister(c)
+		return false
+	}
+
+	return true
+}
+
+// writePump is the only goroutine writing to the connection. It sends queued
+// messages and pings the peer periodically.
+func (c *Client) writePump() {
+	ticker := time.NewTicker(c.hub.pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.hub.Unregister(c)
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case data, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
+			if !ok {
+				// The hub closed the channel.
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package ws
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Time allowed to write a message to the peer.
+	defaultWriteWait = 10 * time.Second
+
+	// Time allowed to read the next pong message from the peer.
+	defaultPongWait = 60 * time.Second
+
+	// Time allowed to a Version1 client to authenticate.
+	defaultAuthWait = 10 * time.Second
+
+	// Maximum message size allowed from peer, enough for an auth command.
+	maxMessageSize = 4096
+
+	// Number of messages queued per client before it is considered dead.
+	sendQueueSize = 32
+)
+
+// Authenticator authenticates the Version1 clients of a hub.
+type Authenticator interface {
+	// Authenticate returns the user of the access token.
+	Authenticate(ctx context.Context, token string) (uint, error)
+
+	// Authenticated is called once the auth command of c has been acked,
+	// e.g. to send the events it missed.
+	Authenticated(ctx context.Context, c *Client, auth AuthPayload)
+}
+
+// Hub maintains the set of active WebSocket and Server-Sent Events clients and
+// delivers messages to them.
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[*Client]struct{}
+	log     *slog.Logger
+
+	writeWait  time.Duration
+	pongWait   time.Duration
+	pingPeriod time.Duration
+	authWait   time.Duration
+}
+
+// NewHub creates and returns a new instance of Hub.
+func NewHub(log *slog.Logger) *Hub {
+	return &Hub{
+		clients:    make(map[*Client]struct{}),
+		log:        log,
+		writeWait:  defaultWriteWait,
+		pongWait:   defaultPongWait,
+		pingPeriod: (defaultPongWait * 9) / 10,
+		authWait:   defaultAuthWait,
+	}
+}
+
+// Register adds a VersionLegacy connection of user uid to the hub and starts
+// its read and write goroutines. The client is subscribed to TopicVideos.
+func (h *Hub) Register(uid uint, ip string, conn *websocket.Conn) *Client {
+	c := h.newClient(VersionLegacy, ip, conn)
+	c.uid = uid
+	c.topics[TopicVideos] = true
+	h.start(c)
+
+	return c
+}
+
+// Serve adds a Version1 connection to the hub and starts its read and write
+// goroutines. The client receives nothing until auth authenticates it, and is
+// dropped when it does not in time.
+func (h *Hub) Serve(ip string, conn *websocket.Conn, auth Authenticator) *Client {
+	c := h.newClient(Version1, ip, conn)
+	c.auth = auth
+	h.start(c)
+
+	time.AfterFunc(h.authWait, func() {
+		if c.UserID() == 0 {
+			h.Unregister(c)
+		}
+	})
+
+	return c
+}
+
+// newClient returns a client of version for conn.
+func (h *Hub) newClient(version int, ip string, conn *websocket.Conn) *Client {
+	return &Client{
+		IP:      ip,
+		Version: version,
+		hub:     h,
+		conn:    conn,
+		send:    make(chan []byte, sendQueueSize),
+		topics:  make(map[string]bool),
+	}
+}
+
+// add registers c.
+func (h *Hub) add(c *Client) {
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+}
+
+// start registers c and starts its goroutines.
+func (h *Hub) start(c *Client) {
+	h.add(c)
+
+	go c.writePump()
+	go c.readPump()
+}
+
+// Unregister removes a client from the hub. It is safe to call more than once.
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.clients[c]; ok {
+		delete(h.clients, c)
+		close(c.send)
+	}
+}
+
+// Deliver queues the event of msg for every client of its audience, encoded
+// for the version of each client.
+func (h *Hub) Deliver(msg *Message) {
+	frames := map[int][]byte{}
+	h.deliver(func(c *Client) []byte {
+		if !c.isAudience(&msg.Audience) {
+			return nil
+		}
+		frame, ok := frames[c.Version]
+		if !ok {
+			var err error
+			if frame, err = encode(c.Version, msg); err != nil {
+				h.log.Error("Deliver", "type", msg.Type, "err", err)
+			}
+			frames[c.Version] = frame
+		}
+		return frame
+	})
+}
+
+// SendEvent queues the event of msg for the client c only, whatever its audience,
+// e.g. to replay what it missed. It reports false when c is no longer registered
+// or its send queue is full.
+func (h *Hub) SendEvent(c *Client, msg *Message) bool {
+	frame, err := encode(c.Version, msg)
+	if err != nil {
+		h.log.Error("SendEvent", "type", msg.Type, "err", err)
+		return false
+	}
+
+	return h.sendFrame(c, frame)
+}
+
+// sendFrame queues frame for c. It reports false when c is no longer
+// registered or its send queue is full.
+func (h *Hub) sendFrame(c *Client, frame []byte) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if _, ok := h.clients[c]; !ok {
+		return false
+	}
+	select {
+	case c.send <- frame:
+		return true
+	default:
+		return false
+	}
+}
+
+// deliver queues the frame returned by frameOf for every client, unless it is nil.
+// Clients whose send queue is full are treated as dead and removed.
+func (h *Hub) deliver(frameOf func(c *Client) []byte) {
+	var dead []*Client
+
+	h.mu.RLock()
+	for c := range h.clients {
+		frame := frameOf(c)
+		if frame == nil {
+			continue
+		}
+		select {
+		case c.send <- frame:
+		default:
+			dead = append(dead, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, c := range dead {
+		h.Unregister(c)
+	}
+}
+
+// Len returns the number of registered clients.
+func (h *Hub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.clients)
+}
@@ -0,0 +1,268 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, h *Hub) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uid, _ := strconv.Atoi(r.URL.Query().Get("uid"))
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		h.Register(uint(uid), r.RemoteAddr, conn)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func dial(t *testing.T, srv *httptest.Server, uid int) *websocket.Conn {
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "?uid=" + strconv.Itoa(uid)
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+
+	return conn
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// bare returns a message sent as is to legacy clients, except to the user except.
+func bare(payload string, except uint) *Message {
+	return &Message{Audience: Audience{Except: except}, Payload: json.RawMessage(payload), LegacyBare: true}
+}
+
+func TestHub_BroadcastManyClients(t *testing.T) {
+	const numClients = 50
+	const numMessages = 10
+
+	h := NewHub(slog.Default())
+	srv := newTestServer(t, h)
+
+	conns := make([]*websocket.Conn, numClients)
+	for i := range conns {
+		conns[i] = dial(t, srv, i+1)
+	}
+	waitFor(t, func() bool { return h.Len() == numClients })
+
+	// Publish concurrently from several goroutines, skipping user 1.
+	var wg sync.WaitGroup
+	for i := 0; i < numMessages; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			h.Deliver(bare(fmt.Sprintf(`"msg-%d"`, i), 1))
+		}(i)
+	}
+
+	var received sync.WaitGroup
+	for i, conn := range conns[1:] {
+		received.Add(1)
+		go func(i int, conn *websocket.Conn) {
+			defer received.Done()
+			seen := map[string]bool{}
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			for len(seen) < numMessages {
+				_, data, err := conn.ReadMessage()
+				if !assert.NoError(t, err, "client %d", i+2) {
+					return
+				}
+				seen[string(data)] = true
+			}
+		}(i, conn)
+	}
+	wg.Wait()
+	received.Wait()
+
+	// The sharer must not receive its own notification.
+	conns[0].SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _, err := conns[0].ReadMessage()
+	assert.Error(t, err)
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+	waitFor(t, func() bool { return h.Len() == 0 })
+}
+
+func TestHub_ConcurrentRegisterAndBroadcast(t *testing.T) {
+	const numClients = 30
+
+	h := NewHub(slog.Default())
+	srv := newTestServer(t, h)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				h.Deliver(bare(`"tick"`, 0))
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < numClients; i++ {
+		wg.Add(1)
+		go func(uid int) {
+			defer wg.Done()
+			conn := dial(t, srv, uid)
+			conn.ReadMessage()
+			conn.Close()
+		}(i + 1)
+	}
+	wg.Wait()
+	close(done)
+
+	waitFor(t, func() bool { return h.Len() == 0 })
+}
+
+func TestHub_RemovesDeadConnections(t *testing.T) {
+	h := NewHub(slog.Default())
+	h.pongWait = 200 * time.Millisecond
+	h.pingPeriod = 50 * time.Millisecond
+	srv := newTestServer(t, h)
+
+	// A client that reads answers pings and stays registered.
+	alive := dial(t, srv, 1)
+	go func() {
+		for {
+			if _, _, err := alive.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	defer alive.Close()
+
+	// A client that never reads never answers pings.
+	dead := dial(t, srv, 2)
+	defer dead.Close()
+
+	waitFor(t, func() bool { return h.Len() == 2 })
+	waitFor(t, func() bool { return h.Len() == 1 })
+
+	time.Sleep(3 * h.pongWait)
+	assert.Equal(t, 1, h.Len())
+}
+
+func TestHub_DropsSlowClients(t *testing.T) {
+	h := NewHub(slog.Default())
+	srv := newTestServer(t, h)
+
+	conn := dial(t, srv, 1)
+	defer conn.Close()
+	waitFor(t, func() bool { return h.Len() == 1 })
+
+	// Never read: once the socket buffers and the send queue fill up the client is dropped.
+	msg := bare(`"`+strings.Repeat("x", 64*1024)+`"`, 0)
+	waitFor(t, func() bool {
+		h.Deliver(msg)
+		return h.Len() == 0
+	})
+}
+
+func TestHub_DeliverTo(t *testing.T) {
+	h := NewHub(slog.Default())
+	srv := newTestServer(t, h)
+
+	conns := make([]*websocket.Conn, 4)
+	for i := range conns {
+		conns[i] = dial(t, srv, i+1)
+		defer conns[i].Close()
+	}
+	waitFor(t, func() bool { return h.Len() == len(conns) })
+
+	h.Deliver(&Message{Audience: Audience{To: []uint{2, 4}}, Type: "test", Payload: json.RawMessage(`"hello"`)})
+
+	for i, conn := range conns {
+		uid := i + 1
+		if uid == 2 || uid == 4 {
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			_, data, err := conn.ReadMessage()
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"type":"test","payload":"hello"}`, string(data))
+			continue
+		}
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		_, _, err := conn.ReadMessage()
+		assert.Error(t, err, "user %d", uid)
+	}
+}
+
+func TestHub_UnregisterTwice(t *testing.T) {
+	h := NewHub(slog.Default())
+	srv := newTestServer(t, h)
+
+	conn := dial(t, srv, 1)
+	defer conn.Close()
+	waitFor(t, func() bool { return h.Len() == 1 })
+
+	h.mu.RLock()
+	var c *Client
+	for c = range h.clients {
+	}
+	h.mu.RUnlock()
+
+	h.Unregister(c)
+	h.Unregister(c)
+	assert.Equal(t, 0, h.Len())
+}
+
+func TestHub_SendEvent(t *testing.T) {
+	h := NewHub(slog.Default())
+	clients := make(chan *Client, 2)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		clients <- h.Register(1, r.RemoteAddr, conn)
+	}))
+	t.Cleanup(srv.Close)
+
+	first := dial(t, srv, 1)
+	c := <-clients
+	second := dial(t, srv, 1)
+	<-clients
+
+	msg := &Message{Type: "test", Payload: json.RawMessage(`"replay"`)}
+	require.True(t, h.SendEvent(c, msg))
+	first.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := first.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"test","payload":"replay"}`, string(data))
+
+	// The other connection of the same user does not get it.
+	second.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _, err = second.ReadMessage()
+	assert.Error(t, err)
+
+	h.Unregister(c)
+	assert.False(t, h.SendEvent(c, msg))
+}
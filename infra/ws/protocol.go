@@ -0,0 +1,146 @@
+package ws
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Versions of the protocol spoken by a client.
+const (
+	// VersionLegacy clients send their bearer token in the first frame, then
+	// only receive events: new videos as a bare video, the others as
+	// {"type": ..., "payload": ...}.
+	VersionLegacy = 0
+	// Version1 clients exchange Envelopes. They select it with Subprotocol.
+	Version1 = 1
+)
+
+// Subprotocol is the WebSocket subprotocol of Version1.
+const Subprotocol = "funny-project.v1"
+
+// Envelope is a frame of Version1, sent by the server or the client.
+type Envelope struct {
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+	// ID is chosen by the client for its commands, and repeated by the ack
+	// or error frame answering each of them.
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Commands sent by clients. Every command is answered by an ack or an error frame.
+const (
+	// CommandAuth authenticates the connection, its payload is an AuthPayload.
+	// Other commands but ping are refused until it succeeds.
+	CommandAuth = "auth"
+	// CommandSubscribe subscribes to the topics of a TopicsPayload.
+	CommandSubscribe = "subscribe"
+	// CommandUnsubscribe unsubscribes from the topics of a TopicsPayload.
+	CommandUnsubscribe = "unsubscribe"
+	// CommandPing checks that the connection is alive.
+	CommandPing = "ping"
+)
+
+// Frames answering commands.
+const (
+	// FrameAck tells that a command succeeded. Its payload depends on the command.
+	FrameAck = "ack"
+	// FrameError tells that a command failed, its payload is an ErrorPayload.
+	FrameError = "error"
+)
+
+// Codes of ErrorPayload.
+const (
+	ErrorBadRequest         = "bad_request"
+	ErrorUnauthorized       = "unauthorized"
+	ErrorUnknownCommand     = "unknown_command"
+	ErrorUnsupportedVersion = "unsupported_version"
+	ErrorTooManyTopics      = "too_many_topics"
+)
+
+// AuthPayload is the payload of CommandAuth.
+type AuthPayload struct {
+	// Token is the access token, with or without its Bearer prefix.
+	Token string `json:"token"`
+	// LastSeenID is the id of the last notification received by the client,
+	// the newer ones are sent after the ack.
+	LastSeenID uint `json:"lastSeenId,omitempty"`
+}
+
+// AuthAck is the payload of the ack of CommandAuth.
+type AuthAck struct {
+	UserID uint `json:"userId"`
+}
+
+// TopicsPayload is the payload of CommandSubscribe and CommandUnsubscribe, and
+// of their ack, which lists every topic the client is subscribed to.
+type TopicsPayload struct {
+	Topics []string `json:"topics"`
+}
+
+// ErrorPayload is the payload of FrameError.
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// maxTopics is the number of topics a client may subscribe to.
+const maxTopics = 50
+
+// TopicVideos carries the changes of every video: updates, deletions and reactions.
+// New videos are only sent to the followers of their sharer and the subscribers
+// of TopicUser. Legacy clients are always subscribed to it.
+const TopicVideos = "videos"
+
+// TopicVideo returns the topic carrying the changes and comments of video id.
+func TopicVideo(id uint) string {
+	return "video:" + strconv.FormatUint(uint64(id), 10)
+}
+
+// TopicUser returns the topic carrying the videos shared by user id.
+func TopicUser(id uint) string {
+	return "user:" + strconv.FormatUint(uint64(id), 10)
+}
+
+// isValidTopic reports whether topic is TopicVideos, a TopicVideo or a TopicUser.
+func isValidTopic(topic string) bool {
+	if topic == TopicVideos {
+		return true
+	}
+	kind, id, ok := strings.Cut(topic, ":")
+	if !ok || (kind != "video" && kind != "user") {
+		return false
+	}
+	n, err := strconv.ParseUint(id, 10, 64)
+
+	return err == nil && n > 0 && strconv.FormatUint(n, 10) == id
+}
+
+// encode returns the frame of the event of msg for the clients of version.
+func encode(version int, msg *Message) ([]byte, error) {
+	if version == VersionEventStream {
+		return encodeEventStream(msg), nil
+	}
+	if version == VersionLegacy {
+		if msg.LegacyBare {
+			return msg.Payload, nil
+		}
+		return json.Marshal(struct {
+			Type    string          `json:"type"`
+			Payload json.RawMessage `json:"payload"`
+		}{msg.Type, msg.Payload})
+	}
+
+	return json.Marshal(Envelope{Type: msg.Type, Version: version, Payload: msg.Payload})
+}
+
+// reply returns the ack or error frame of type answering the command id.
+func reply(frameType string, id string, payload interface{}) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(Envelope{Type: frameType, Version: Version1, ID: id, Payload: data})
+}
@@ -0,0 +1,289 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files of testdata")
+
+// golden compares data with testdata/name.golden, or writes it with -update.
+func golden(t *testing.T, name string, data []byte) {
+	var indented bytes.Buffer
+	require.NoError(t, json.Indent(&indented, data, "", "  "))
+	indented.WriteByte('\n')
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		require.NoError(t, os.WriteFile(path, indented.Bytes(), 0o644))
+		return
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), indented.String(), "run go test -update to accept the new schema")
+}
+
+func TestProtocol_Golden(t *testing.T) {
+	video := &Message{
+		Audience:   Audience{Topics: []string{TopicUser(2)}},
+		Type:       "video.created",
+		Payload:    json.RawMessage(`{"id":1,"youtubeId":"dQw4w9WgXcQ"}`),
+		LegacyBare: true,
+	}
+	deleted := &Message{Type: "video.deleted", Payload: json.RawMessage(`{"id":1}`)}
+
+	frames := map[string]func() ([]byte, error){
+		"event_v1":     func() ([]byte, error) { return encode(Version1, video) },
+		"event_legacy": func() ([]byte, error) { return encode(VersionLegacy, video) },
+		"event_legacy_typed": func() ([]byte, error) {
+			return encode(VersionLegacy, deleted)
+		},
+		"ack_auth": func() ([]byte, error) { return reply(FrameAck, "1", AuthAck{UserID: 2}) },
+		"ack_topics": func() ([]byte, error) {
+			return reply(FrameAck, "2", TopicsPayload{Topics: []string{TopicUser(2), TopicVideo(1)}})
+		},
+		"ack_ping": func() ([]byte, error) { return reply(FrameAck, "3", struct{}{}) },
+		"error": func() ([]byte, error) {
+			return reply(FrameError, "4", ErrorPayload{Code: ErrorUnauthorized, Message: "token is invalid"})
+		},
+	}
+	for name, frame := range frames {
+		t.Run(name, func(t *testing.T) {
+			data, err := frame()
+			require.NoError(t, err)
+			golden(t, name, data)
+		})
+	}
+
+	// Commands are decoded from their documented form.
+	commands := map[string]interface{}{
+		"command_auth":      &AuthPayload{},
+		"command_subscribe": &TopicsPayload{},
+	}
+	for name, payload := range commands {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", name+".golden"))
+			require.NoError(t, err)
+			var cmd Envelope
+			require.NoError(t, json.Unmarshal(data, &cmd))
+			assert.Equal(t, Version1, cmd.Version)
+			require.NoError(t, json.Unmarshal(cmd.Payload, payload))
+			reencoded, err := json.Marshal(Envelope{Type: cmd.Type, Version: cmd.Version, ID: cmd.ID, Payload: mustMarshal(t, payload)})
+			require.NoError(t, err)
+			golden(t, name, reencoded)
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+
+	return data
+}
+
+func TestIsValidTopic(t *testing.T) {
+	for topic, valid := range map[string]bool{
+		"videos":    true,
+		"video:1":   true,
+		"user:42":   true,
+		"video:0":   false,
+		"video:01":  false,
+		"video:-1":  false,
+		"video:":    false,
+		"user":      false,
+		"comment:1": false,
+		"":          false,
+	} {
+		assert.Equal(t, valid, isValidTopic(topic), topic)
+	}
+}
+
+// fakeAuthenticator accepts the tokens "Bearer <uid>".
+type fakeAuthenticator struct {
+	authenticated chan AuthPayload
+}
+
+func (a *fakeAuthenticator) Authenticate(ctx context.Context, token string) (uint, error) {
+	uid, err := strconv.Atoi(strings.TrimPrefix(token, "Bearer "))
+	if err != nil {
+		return 0, errors.New("token is invalid")
+	}
+
+	return uint(uid), nil
+}
+
+func (a *fakeAuthenticator) Authenticated(ctx context.Context, c *Client, auth AuthPayload) {
+	a.authenticated <- auth
+}
+
+func newV1Server(t *testing.T, h *Hub, auth Authenticator) *httptest.Server {
+	upgrader := websocket.Upgrader{Subprotocols: []string{Subprotocol}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		h.Serve(r.RemoteAddr, conn, auth)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func dialV1(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	dialer := websocket.Dialer{Subprotocols: []string{Subprotocol}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	require.Equal(t, Subprotocol, conn.Subprotocol())
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// command sends a command and returns the frame answering it.
+func command(t *testing.T, conn *websocket.Conn, cmdType string, id string, payload string) Envelope {
+	frame := `{"type":"` + cmdType + `","version":1,"id":"` + id + `","payload":` + payload + `}`
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(frame)))
+
+	return next(t, conn)
+}
+
+func next(t *testing.T, conn *websocket.Conn) Envelope {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var env Envelope
+	require.NoError(t, json.Unmarshal(data, &env))
+
+	return env
+}
+
+func assertError(t *testing.T, env Envelope, id string, code string) {
+	assert.Equal(t, FrameError, env.Type)
+	assert.Equal(t, id, env.ID)
+	var payload ErrorPayload
+	require.NoError(t, json.Unmarshal(env.Payload, &payload))
+	assert.Equal(t, code, payload.Code)
+}
+
+// assertNothing checks that conn was sent nothing before the ack of a ping.
+func assertNothing(t *testing.T, conn *websocket.Conn) {
+	env := command(t, conn, CommandPing, "nothing", `{}`)
+	assert.Equal(t, FrameAck, env.Type)
+	assert.Equal(t, "nothing", env.ID)
+}
+
+func TestProtocol_Commands(t *testing.T) {
+	h := NewHub(slog.Default())
+	auth := &fakeAuthenticator{authenticated: make(chan AuthPayload, 1)}
+	conn := dialV1(t, newV1Server(t, h, auth))
+	waitFor(t, func() bool { return h.Len() == 1 })
+
+	// Ping is answered before auth, the other commands are not.
+	env := command(t, conn, CommandPing, "1", `{}`)
+	assert.Equal(t, FrameAck, env.Type)
+	assert.Equal(t, "1", env.ID)
+	assertError(t, command(t, conn, CommandSubscribe, "2", `{"topics":["video:1"]}`), "2", ErrorUnauthorized)
+
+	// Events are not sent before auth either.
+	h.Deliver(&Message{Type: "video.deleted", Payload: json.RawMessage(`{"id":1}`)})
+	assertNothing(t, conn)
+
+	assertError(t, command(t, conn, CommandAuth, "3", `{"token":"nope"}`), "3", ErrorUnauthorized)
+	assertError(t, command(t, conn, CommandAuth, "4", `{}`), "4", ErrorBadRequest)
+
+	env = command(t, conn, CommandAuth, "5", `{"token":"Bearer 7","lastSeenId":3}`)
+	assert.Equal(t, FrameAck, env.Type)
+	assert.JSONEq(t, `{"userId":7}`, string(env.Payload))
+	assert.Equal(t, AuthPayload{Token: "Bearer 7", LastSeenID: 3}, <-auth.authenticated)
+	assertError(t, command(t, conn, CommandAuth, "6", `{"token":"7"}`), "6", ErrorBadRequest)
+
+	env = command(t, conn, CommandSubscribe, "7", `{"topics":["video:1","user:2"]}`)
+	assert.Equal(t, FrameAck, env.Type)
+	assert.JSONEq(t, `{"topics":["user:2","video:1"]}`, string(env.Payload))
+	assertError(t, command(t, conn, CommandSubscribe, "8", `{"topics":["comment:1"]}`), "8", ErrorBadRequest)
+
+	// Only the events of the subscribed topics and of the user are delivered.
+	h.Deliver(&Message{Audience: Audience{Topics: []string{TopicVideo(2)}}, Type: "video.deleted", Payload: json.RawMessage(`{"id":2}`)})
+	h.Deliver(&Message{Audience: Audience{Topics: []string{TopicVideo(1)}}, Type: "video.deleted", Payload: json.RawMessage(`{"id":1}`)})
+	env = next(t, conn)
+	assert.Equal(t, Envelope{Type: "video.deleted", Version: Version1, Payload: json.RawMessage(`{"id":1}`)}, env)
+	h.Deliver(&Message{Audience: Audience{To: []uint{7}}, Type: "notification", Payload: json.RawMessage(`{"id":4}`)})
+	assert.Equal(t, "notification", next(t, conn).Type)
+
+	env = command(t, conn, CommandUnsubscribe, "9", `{"topics":["video:1"]}`)
+	assert.Equal(t, FrameAck, env.Type)
+	assert.JSONEq(t, `{"topics":["user:2"]}`, string(env.Payload))
+	h.Deliver(&Message{Audience: Audience{Topics: []string{TopicVideo(1)}}, Type: "video.deleted", Payload: json.RawMessage(`{"id":1}`)})
+	assertNothing(t, conn)
+
+	// Malformed and unknown frames.
+	topics := make([]string, maxTopics)
+	for i := range topics {
+		topics[i] = TopicVideo(uint(i + 10))
+	}
+	data, err := json.Marshal(TopicsPayload{Topics: topics})
+	require.NoError(t, err)
+	assertError(t, command(t, conn, CommandSubscribe, "10", string(data)), "10", ErrorTooManyTopics)
+	assertError(t, command(t, conn, "shout", "11", `{}`), "11", ErrorUnknownCommand)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"ping","version":2,"id":"12"}`)))
+	assertError(t, next(t, conn), "12", ErrorUnsupportedVersion)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`ping`)))
+	assertError(t, next(t, conn), "", ErrorBadRequest)
+}
+
+func TestProtocol_AuthTimeout(t *testing.T) {
+	h := NewHub(slog.Default())
+	h.authWait = 100 * time.Millisecond
+	srv := newV1Server(t, h, &fakeAuthenticator{authenticated: make(chan AuthPayload, 1)})
+
+	authenticated := dialV1(t, srv)
+	assert.Equal(t, FrameAck, command(t, authenticated, CommandAuth, "1", `{"token":"1"}`).Type)
+	dialV1(t, srv)
+	waitFor(t, func() bool { return h.Len() == 2 })
+
+	// Only the client which did not authenticate is dropped.
+	waitFor(t, func() bool { return h.Len() == 1 })
+	time.Sleep(2 * h.authWait)
+	assert.Equal(t, 1, h.Len())
+}
+
+func TestProtocol_LegacyAndV1(t *testing.T) {
+	h := NewHub(slog.Default())
+	legacy := dial(t, newTestServer(t, h), 1)
+	defer legacy.Close()
+	v1 := dialV1(t, newV1Server(t, h, &fakeAuthenticator{authenticated: make(chan AuthPayload, 1)}))
+	command(t, v1, CommandAuth, "1", `{"token":"2"}`)
+	waitFor(t, func() bool { return h.Len() == 2 })
+
+	// Legacy clients are subscribed to TopicVideos, and get new videos bare.
+	h.Deliver(&Message{Audience: Audience{Topics: []string{TopicVideos}}, Type: "video.deleted", Payload: json.RawMessage(`{"id":1}`)})
+	legacy.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := legacy.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"video.deleted","payload":{"id":1}}`, string(data))
+	assertNothing(t, v1)
+
+	h.Deliver(&Message{Audience: Audience{To: []uint{1, 2}}, Type: "video.created", Payload: json.RawMessage(`{"id":2}`), LegacyBare: true})
+	_, data, err = legacy.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":2}`, string(data))
+	assert.Equal(t, Envelope{Type: "video.created", Version: Version1, Payload: json.RawMessage(`{"id":2}`)}, next(t, v1))
+}
@@ -0,0 +1,95 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+
+	"funny-project-be/domain/pubsub"
+)
+
+// BroadcastChannel is the broker channel carrying notifications for WebSocket clients.
+const BroadcastChannel = "ws_broadcast"
+
+// Audience selects the clients receiving a Message. Every authenticated client
+// receives it when To, FollowersOf and Topics are empty, otherwise the users To,
+// the followers of FollowersOf and the subscribers of Topics do.
+type Audience struct {
+	To []uint `json:"to,omitempty"`
+	// FollowersOf is the user whose followers receive the message. They are resolved
+	// by the hub of each replica, so the message does not grow with their number.
+	FollowersOf uint `json:"followersOf,omitempty"`
+	// Except is the user who must not receive the notification, e.g. the sharer.
+	Except uint     `json:"except,omitempty"`
+	Topics []string `json:"topics,omitempty"`
+
+	// followers are the followers of FollowersOf, resolved by Relay.
+	followers map[uint]bool
+}
+
+// Followers finds the followers of a user for Audience.FollowersOf.
+type Followers interface {
+	GetFollowerIDs(ctx context.Context, followeeID uint) ([]uint, error)
+}
+
+// Message is an event routed through the broker to the hubs of every replica,
+// which encode it for the version of each client.
+type Message struct {
+	Audience
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+	// ID identifies the event to resume a Stream from, e.g. the id of a notification.
+	ID uint `json:"id,omitempty"`
+	// LegacyBare sends the payload alone to VersionLegacy clients, as new videos used to be.
+	LegacyBare bool `json:"legacyBare,omitempty"`
+}
+
+// Publish sends msg to the hubs of every replica through b.
+func Publish(ctx context.Context, b pubsub.Broker, msg Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return b.Publish(ctx, BroadcastChannel, payload)
+}
+
+// Relay subscribes to the broadcast channel of b and fans out every message
+// to the local clients of the hub until ctx is done. The followers of
+// Audience.FollowersOf are looked up in followers.
+func (h *Hub) Relay(ctx context.Context, b pubsub.Broker, followers Followers) error {
+	ch, err := b.Subscribe(ctx, BroadcastChannel)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for payload := range ch {
+			var msg Message
+			if err := json.Unmarshal(payload, &msg); err != nil {
+				h.log.ErrorContext(ctx, "Relay", "err", err)
+				continue
+			}
+			if msg.FollowersOf != 0 && h.Len() > 0 {
+				h.resolveFollowers(ctx, &msg.Audience, followers)
+			}
+			h.Deliver(&msg)
+		}
+	}()
+
+	return nil
+}
+
+// resolveFollowers fills the followers of audience. On error, only the other
+// members of the audience receive the message.
+func (h *Hub) resolveFollowers(ctx context.Context, audience *Audience, followers Followers) {
+	ids, err := followers.GetFollowerIDs(ctx, audience.FollowersOf)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Relay followers", "uid", audience.FollowersOf, "err", err)
+		return
+	}
+
+	audience.followers = make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		audience.followers[id] = true
+	}
+}
@@ -0,0 +1,78 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"funny-project-be/infra/pubsub/pubsubimpl"
+)
+
+// followers maps users to their followers.
+type followers map[uint][]uint
+
+func (f followers) GetFollowerIDs(ctx context.Context, followeeID uint) ([]uint, error) {
+	return f[followeeID], nil
+}
+
+func TestHub_RelayAcrossReplicas(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Two hubs sharing one broker behave like two API replicas.
+	b := pubsubimpl.NewMemoryBroker()
+	h1, h2 := NewHub(slog.Default()), NewHub(slog.Default())
+	require.NoError(t, h1.Relay(ctx, b, followers{}))
+	require.NoError(t, h2.Relay(ctx, b, followers{}))
+
+	c1 := dial(t, newTestServer(t, h1), 1)
+	defer c1.Close()
+	c2 := dial(t, newTestServer(t, h2), 2)
+	defer c2.Close()
+	waitFor(t, func() bool { return h1.Len() == 1 && h2.Len() == 1 })
+
+	// User 1 shares on replica 1; only user 2, connected to replica 2, is notified.
+	require.NoError(t, Publish(ctx, b, Message{Audience: Audience{Except: 1}, Type: "video.created", Payload: json.RawMessage(`{"id":1}`), LegacyBare: true}))
+
+	c2.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := c2.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":1}`, string(data))
+
+	c1.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _, err = c1.ReadMessage()
+	assert.Error(t, err)
+}
+
+func TestHub_RelayFollowers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := pubsubimpl.NewMemoryBroker()
+	h := NewHub(slog.Default())
+	require.NoError(t, h.Relay(ctx, b, followers{1: {2}}))
+
+	srv := newTestServer(t, h)
+	c2 := dial(t, srv, 2)
+	defer c2.Close()
+	c3 := dial(t, srv, 3)
+	defer c3.Close()
+	waitFor(t, func() bool { return h.Len() == 2 })
+
+	// The followers of user 1 are resolved by the hub, user 3 does not follow them.
+	require.NoError(t, Publish(ctx, b, Message{Audience: Audience{FollowersOf: 1, Except: 1}, Type: "video.created", Payload: json.RawMessage(`{"id":1}`), LegacyBare: true}))
+
+	c2.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := c2.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":1}`, string(data))
+
+	c3.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _, err = c3.ReadMessage()
+	assert.Error(t, err)
+}
@@ -0,0 +1,48 @@
+package ws
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// VersionEventStream is not a WebSocket protocol: the clients of Stream receive
+// Server-Sent Events, whose event field is the type of the event and data its payload.
+const VersionEventStream = -1
+
+// Stream adds a Server-Sent Events client of user uid to the hub, subscribed to
+// TopicVideos like the legacy WebSocket clients. The caller writes the frames of
+// Events to the response until the channel is closed, and unregisters the client
+// once the request is done.
+func (h *Hub) Stream(uid uint, ip string) *Client {
+	c := h.newClient(VersionEventStream, ip, nil)
+	c.uid = uid
+	c.topics[TopicVideos] = true
+
+	h.add(c)
+
+	return c
+}
+
+// Events returns the frames queued for a client of Stream. The channel is closed
+// when the client is unregistered, e.g. because it was too slow.
+func (c *Client) Events() <-chan []byte {
+	return c.send
+}
+
+// encodeEventStream returns the Server-Sent Event of msg. Its id, when set, is
+// sent back by the client in the Last-Event-ID header once it reconnects.
+func encodeEventStream(msg *Message) []byte {
+	var b bytes.Buffer
+	if msg.ID != 0 {
+		b.WriteString("id: " + strconv.FormatUint(uint64(msg.ID), 10) + "\n")
+	}
+	b.WriteString("event: " + msg.Type + "\n")
+	for _, line := range bytes.Split(msg.Payload, []byte("\n")) {
+		b.WriteString("data: ")
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+
+	return b.Bytes()
+}
@@ -0,0 +1,40 @@
+package ws
+
+import (
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub_Stream(t *testing.T) {
+	h := NewHub(slog.Default())
+	c := h.Stream(1, "127.0.0.1")
+	other := h.Stream(2, "127.0.0.1")
+	require.Equal(t, 2, h.Len())
+
+	// Streams get the events of TopicVideos and of their user, like legacy WebSockets.
+	h.Deliver(&Message{Audience: Audience{Topics: []string{TopicVideos}}, Type: "video.deleted", Payload: json.RawMessage(`{"id":1}`)})
+	h.Deliver(&Message{Audience: Audience{To: []uint{1}}, Type: "notification", Payload: json.RawMessage(`{"id":4}`), ID: 4})
+	h.Deliver(&Message{Audience: Audience{Topics: []string{TopicVideo(1)}}, Type: "comment.created", Payload: json.RawMessage(`{"id":5}`)})
+
+	assert.Equal(t, "event: video.deleted\ndata: {\"id\":1}\n\n", string(<-c.Events()))
+	assert.Equal(t, "id: 4\nevent: notification\ndata: {\"id\":4}\n\n", string(<-c.Events()))
+	assert.Equal(t, "event: video.deleted\ndata: {\"id\":1}\n\n", string(<-other.Events()))
+	assert.Empty(t, c.Events())
+	assert.Empty(t, other.Events())
+
+	// The events channel is closed once the stream is unregistered.
+	h.Unregister(c)
+	_, ok := <-c.Events()
+	assert.False(t, ok)
+	assert.Equal(t, 1, h.Len())
+}
+
+func TestEncodeEventStream(t *testing.T) {
+	// Every line of a payload is a data line.
+	frame := encodeEventStream(&Message{Type: "test", Payload: json.RawMessage("{\n\"a\":1\n}")})
+	assert.Equal(t, "event: test\ndata: {\ndata: \"a\":1\ndata: }\n\n", string(frame))
+}